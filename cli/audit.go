@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	storeAudit "github.com/fabienbellanger/echo-boilerplate/store/audit"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditFormat string
+	auditOutput string
+	auditSince  string
+	auditUntil  string
+	auditFilter entities.AuditFilter
+)
+
+func init() {
+	auditExportCmd.Flags().StringVarP(&auditFormat, "format", "f", "json", "output format (json | csv)")
+	auditExportCmd.Flags().StringVarP(&auditOutput, "output", "o", "", "output file (default: stdout)")
+	auditExportCmd.Flags().StringVar(&auditSince, "since", "", "only logs created since this RFC 3339 date")
+	auditExportCmd.Flags().StringVar(&auditUntil, "until", "", "only logs created before this RFC 3339 date")
+	auditExportCmd.Flags().StringVar(&auditFilter.ActorID, "actor", "", "only logs of this actor ID")
+	auditExportCmd.Flags().StringVar(&auditFilter.TargetID, "target", "", "only logs of this target ID")
+	auditExportCmd.Flags().StringVar(&auditFilter.Action, "action", "", "only logs of this action (create | update | delete | restore | purge | impersonate)")
+
+	auditCmd.AddCommand(auditExportCmd)
+	rootCmd.AddCommand(auditCmd)
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Audit trail",
+	Long:  `Audit trail`,
+}
+
+var auditExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the audit trail",
+	Long:  `Export the audit trail, oldest first, as JSON lines or CSV (Ex.: audit export -f csv --since 2022-01-01T00:00:00Z)`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if auditFormat != "json" && auditFormat != "csv" {
+			log.Fatalln("unknown format " + auditFormat)
+		}
+
+		var err error
+		if auditFilter.Since, err = parseDateFlag(auditSince); err != nil {
+			log.Fatalln(err)
+		}
+		if auditFilter.Until, err = parseDateFlag(auditUntil); err != nil {
+			log.Fatalln(err)
+		}
+
+		_, db, err := initConfigLoggerDatabase(false, true)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if auditOutput == "" {
+			if _, err := exportAudit(os.Stdout, auditFormat, storeAudit.New(db), auditFilter); err != nil {
+				log.Fatalln(err)
+			}
+			return
+		}
+
+		f, err := os.Create(auditOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		n, err := exportAudit(f, auditFormat, storeAudit.New(db), auditFilter)
+		if errClose := f.Close(); err == nil {
+			err = errClose
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Printf("%d audit logs exported to %s\n", n, auditOutput)
+	},
+}
+
+// exportAudit writes the audit logs matching the filter in w and returns the number of exported logs.
+// The CSV writer is flushed even if the export fails, so that the exported logs are complete rows.
+func exportAudit(w io.Writer, format string, s store.AuditStorer, filter entities.AuditFilter) (n int, err error) {
+	write := auditJSONWriter(w)
+	if format == "csv" {
+		csvWriter := csv.NewWriter(w)
+		defer func() {
+			csvWriter.Flush()
+			if err == nil {
+				err = csvWriter.Error()
+			}
+		}()
+		write = auditCSVWriter(csvWriter)
+	}
+
+	err = s.Export(filter, func(l entities.AuditLog) error {
+		n++
+		return write(l)
+	})
+	return n, err
+}
+
+// auditJSONWriter writes one JSON audit log per line.
+func auditJSONWriter(w io.Writer) func(entities.AuditLog) error {
+	enc := json.NewEncoder(w)
+	return func(l entities.AuditLog) error {
+		return enc.Encode(l)
+	}
+}
+
+// auditCSVWriter writes audit logs as CSV rows, changes are JSON encoded.
+func auditCSVWriter(w *csv.Writer) func(entities.AuditLog) error {
+	header := false
+	return func(l entities.AuditLog) error {
+		if !header {
+			header = true
+			if err := w.Write([]string{"id", "created_at", "actor_id", "action", "target_type", "target_id", "ip", "request_id", "changes"}); err != nil {
+				return err
+			}
+		}
+
+		changes, err := json.Marshal(l.Changes)
+		if err != nil {
+			return err
+		}
+		return w.Write([]string{
+			l.ID,
+			l.CreatedAt.Format(time.RFC3339),
+			l.ActorID,
+			l.Action,
+			l.TargetType,
+			l.TargetID,
+			l.IP,
+			l.RequestID,
+			string(changes),
+		})
+	}
+}
+
+// parseDateFlag parses an optional RFC 3339 date.
+func parseDateFlag(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q: %w", s, err)
+	}
+	return &t, nil
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/utils/query"
+	"github.com/stretchr/testify/assert"
+)
+
+// auditStoreStub is a store.AuditStorer exporting its logs, then err.
+type auditStoreStub struct {
+	logs []entities.AuditLog
+	err  error
+}
+
+func (s auditStoreStub) Create(log entities.AuditLog) error { return nil }
+
+func (s auditStoreStub) GetAuditLogs(filter entities.AuditFilter, params query.Params) ([]entities.AuditLog, int64, error) {
+	return nil, 0, nil
+}
+
+func (s auditStoreStub) Export(filter entities.AuditFilter, fn func(entities.AuditLog) error) error {
+	for _, l := range s.logs {
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return s.err
+}
+
+func TestExportAudit(t *testing.T) {
+	s := auditStoreStub{logs: []entities.AuditLog{
+		{ID: "log-1", Action: entities.AuditActionCreate},
+		{ID: "log-2", Action: entities.AuditActionDelete},
+	}}
+
+	var buf bytes.Buffer
+	n, err := exportAudit(&buf, "json", s, entities.AuditFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Len(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), 2)
+
+	buf.Reset()
+	n, err = exportAudit(&buf, "csv", s, entities.AuditFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Len(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), 3)
+
+	// The rows exported before an error are written
+	s.err = errors.New("database error")
+	buf.Reset()
+	n, err = exportAudit(&buf, "csv", s, entities.AuditFilter{})
+	assert.Equal(t, s.err, err)
+	assert.Equal(t, 2, n)
+	assert.Contains(t, buf.String(), "log-2")
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/migrations"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	migrateSteps int
+	migrateDir   string
+)
+
+func init() {
+	migrateUpCmd.Flags().IntVarP(&migrateSteps, "steps", "n", 0, "number of migrations to apply (default: all)")
+	migrateDownCmd.Flags().IntVarP(&migrateSteps, "steps", "n", 1, "number of migrations to roll back")
+	migrateCreateCmd.Flags().StringVarP(&migrateDir, "dir", "d", "migrations", "migrations directory, containing a directory per driver")
+
+	migrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd, migrateCreateCmd)
+	rootCmd.AddCommand(migrateCmd)
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Database migrations",
+	Long:  `Database migrations`,
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply pending migrations",
+	Long:  `Apply pending migrations (Ex.: migrate up -n 1)`,
+	Run: func(cmd *cobra.Command, args []string) {
+		applied, err := newMigrator().Up(migrateSteps)
+		for _, m := range applied {
+			fmt.Printf("Applied   %s_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migration")
+		}
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Roll back the last migrations",
+	Long:  `Roll back the last migrations (Ex.: migrate down -n 2)`,
+	Run: func(cmd *cobra.Command, args []string) {
+		rolledBack, err := newMigrator().Down(migrateSteps)
+		for _, m := range rolledBack {
+			fmt.Printf("Rolled back %s_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List migrations and their state",
+	Long:  `List migrations and their state`,
+	Run: func(cmd *cobra.Command, args []string) {
+		list, err := newMigrator().Status()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		for _, m := range list {
+			state := "pending"
+			if m.AppliedAt != nil {
+				state = "applied at " + m.AppliedAt.Format(time.RFC3339)
+			}
+			if m.Missing {
+				state += " (file missing)"
+			}
+			fmt.Printf("%s_%-40s %s\n", m.Version, m.Name, state)
+		}
+	},
+}
+
+var migrateCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a new migration",
+	Long:  `Create empty up and down files for the configured driver (Ex.: migrate create add_users_phone)`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := initConfig(); err != nil {
+			log.Fatalln(err)
+		}
+
+		up, down, err := db.CreateMigration(path.Join(migrateDir, viper.GetString("DB_DRIVER")), args[0], time.Now())
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		fmt.Printf("Created %s\nCreated %s\n", up, down)
+	},
+}
+
+// newMigrator connects to the database and returns a migrator with the embedded migrations.
+func newMigrator() *db.Migrator {
+	_, database, err := initConfigLoggerDatabase(false, true)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	migrator, err := db.NewMigrator(database, migrations.FS)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return migrator
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	storeRole "github.com/fabienbellanger/echo-boilerplate/store/role"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rolesCmd.AddCommand(rolesAssignCmd)
+	rootCmd.AddCommand(rolesCmd)
+}
+
+var rolesCmd = &cobra.Command{
+	Use:   "roles",
+	Short: "Roles management",
+	Long:  `Roles management`,
+}
+
+var rolesAssignCmd = &cobra.Command{
+	Use:   "assign <username> <role> [role...]",
+	Short: "Replace the roles of a user",
+	Long:  `Replace the roles of a user (Ex.: roles assign test@gmail.com admin)`,
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, db, err := initConfigLoggerDatabase(false, true)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		var user entities.User
+		if result := db.Where(&entities.User{Username: args[0]}).First(&user); result.Error != nil {
+			log.Fatalln(result.Error)
+		}
+
+		roleStore := storeRole.New(db)
+		if err := roleStore.InitDefaultRoles(); err != nil {
+			log.Fatalln(err)
+		}
+		if err := roleStore.AssignRoles(user.ID, args[1:]); err != nil {
+			log.Fatalln(err)
+		}
+
+		fmt.Printf("Roles %v assigned to %s\n", args[1:], user.Username)
+	},
+}
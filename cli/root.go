@@ -61,6 +61,7 @@ func initConfigLoggerDatabase(initLogger, initDB bool) (logger *zap.Logger, data
 			Charset:         viper.GetString("DB_CHARSET"),
 			Collation:       viper.GetString("DB_COLLATION"),
 			Location:        viper.GetString("DB_LOCATION"),
+			SSLMode:         viper.GetString("DB_SSL_MODE"),
 			MaxIdleConns:    viper.GetInt("DB_MAX_IDLE_CONNS"),
 			MaxOpenConns:    viper.GetInt("DB_MAX_OPEN_CONNS"),
 			ConnMaxLifetime: viper.GetDuration("DB_CONN_MAX_LIFETIME") * time.Hour,
@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	server "github.com/fabienbellanger/echo-boilerplate"
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	storeRole "github.com/fabienbellanger/echo-boilerplate/store/role"
+	storeUser "github.com/fabienbellanger/echo-boilerplate/store/user"
+	"github.com/fabienbellanger/echo-boilerplate/utils/password"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+var (
+	seedFake     int
+	seedPassword string
+	seedRoles    []string
+	seedForce    bool
+)
+
+func init() {
+	seedCmd.Flags().IntVar(&seedFake, "fake", 0, "number of fake users to generate")
+	seedCmd.Flags().StringVar(&seedPassword, "password", "", "password of the fake users (required with --fake)")
+	seedCmd.Flags().StringSliceVar(&seedRoles, "role", []string{entities.RoleUser}, "roles of the fake users")
+	seedCmd.Flags().BoolVar(&seedForce, "force", false, "seed even if APP_ENV is production")
+
+	rootCmd.AddCommand(seedCmd)
+}
+
+var seedCmd = &cobra.Command{
+	Use:   "seed [fixtures...]",
+	Short: "Load fixtures and generate fake users",
+	Long: `Load YAML or JSON fixtures and generate fake users (Ex.: seed fixtures/dev.yaml --fake 100 --password secret).
+Existing users and records are left unchanged, so seeding can be run several times.
+Seeding is refused when APP_ENV is production, unless --force is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 && seedFake <= 0 {
+			log.Fatalln("no fixtures file and no fake users to generate")
+		}
+		if seedFake > 0 && seedPassword == "" {
+			log.Fatalln("the password of the fake users must be given with --password")
+		}
+
+		_, database, err := initConfigLoggerDatabase(false, true)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if viper.GetString("APP_ENV") == "production" && !seedForce {
+			log.Fatalln("seeding is refused in production, use --force to seed anyway")
+		}
+
+		hasher, err := server.NewPasswordHasher()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if err := storeRole.New(database).InitDefaultRoles(); err != nil {
+			log.Fatalln(err)
+		}
+
+		for _, file := range args {
+			if err := seedFixtures(database, hasher, file); err != nil {
+				log.Fatalf("%s: %v\n", file, err)
+			}
+		}
+
+		if seedFake > 0 {
+			created := 0
+			for _, u := range fakeUsers(seedFake, seedPassword, seedRoles) {
+				ok, err := seedUser(database, hasher, u)
+				if err != nil {
+					log.Fatalln(err)
+				}
+				if ok {
+					created++
+				}
+			}
+			fmt.Printf("%d fake users created\n", created)
+		}
+	},
+}
+
+// fixtureUser is a user of the fixtures.
+type fixtureUser struct {
+	Username   string   `json:"username"`
+	Password   string   `json:"password"`
+	Lastname   string   `json:"lastname"`
+	Firstname  string   `json:"firstname"`
+	Roles      []string `json:"roles"`
+	Unverified bool     `json:"unverified"`
+}
+
+// seedFixtures loads a fixtures file.
+// Users are created with the user store, so that their passwords are hashed.
+func seedFixtures(database *db.DB, hasher password.PasswordHasher, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fixtures, err := db.LoadFixtures(f)
+	if err != nil {
+		return err
+	}
+
+	users := fixtures["users"]
+	delete(fixtures, "users")
+
+	created := 0
+	for _, record := range users {
+		b, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		var u fixtureUser
+		if err := json.Unmarshal(b, &u); err != nil {
+			return err
+		}
+
+		ok, err := seedUser(database, hasher, u)
+		if err != nil {
+			return err
+		}
+		if ok {
+			created++
+		}
+	}
+	fmt.Printf("%s: %d users created\n", file, created)
+
+	inserted, err := database.Seed(fixtures)
+	if err != nil {
+		return err
+	}
+	for table, n := range inserted {
+		fmt.Printf("%s: %d %s inserted\n", file, n, table)
+	}
+
+	return nil
+}
+
+// seedUser creates a user if its username does not exist yet.
+// The user is created, verified and assigned their roles in a single transaction,
+// so that a failed seeding can be run again.
+func seedUser(database *db.DB, hasher password.PasswordHasher, u fixtureUser) (created bool, err error) {
+	if u.Username == "" || u.Password == "" {
+		return false, fmt.Errorf("user %q without username or password", u.Username)
+	}
+
+	err = database.Transaction(func(tx *gorm.DB) error {
+		txDB := &db.DB{DB: tx}
+		userStore := storeUser.New(txDB, hasher, storeUser.Options{}).WithContext(context.Background())
+		roleStore := storeRole.New(txDB)
+
+		existing, err := userStore.GetUserByUsername(u.Username)
+		if err != nil {
+			return err
+		}
+		if existing.ID != "" {
+			return nil
+		}
+
+		user := entities.User{
+			Username:  u.Username,
+			Password:  u.Password,
+			Lastname:  u.Lastname,
+			Firstname: u.Firstname,
+		}
+		if err := userStore.Register(&user); err != nil {
+			return err
+		}
+		if !u.Unverified {
+			if err := userStore.VerifyUser(user.ID); err != nil {
+				return err
+			}
+		}
+		if len(u.Roles) > 0 {
+			if err := roleStore.AssignRoles(user.ID, u.Roles); err != nil {
+				return err
+			}
+		}
+
+		created = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return created, nil
+}
+
+var (
+	fakeFirstnames = []string{"Emma", "Louis", "Jade", "Gabriel", "Louise", "Raphael", "Alice", "Arthur", "Chloe", "Jules",
+		"Lina", "Adam", "Mia", "Hugo", "Rose", "Paul", "Anna", "Lucas", "Lea", "Nathan"}
+	fakeLastnames = []string{"Martin", "Bernard", "Dubois", "Thomas", "Robert", "Richard", "Petit", "Durand", "Leroy", "Moreau",
+		"Simon", "Laurent", "Lefebvre", "Michel", "Garcia", "David", "Bertrand", "Roux", "Vincent", "Fournier"}
+)
+
+// fakeUsers generates n users. The same users are always generated, so that seeding can be run several times.
+func fakeUsers(n int, password string, roles []string) []fixtureUser {
+	users := make([]fixtureUser, n)
+	for i := range users {
+		firstname := fakeFirstnames[i%len(fakeFirstnames)]
+		lastname := fakeLastnames[(i/len(fakeFirstnames)+i)%len(fakeLastnames)]
+		users[i] = fixtureUser{
+			Username:  fmt.Sprintf("%s.%s.%d@example.com", strings.ToLower(firstname), strings.ToLower(lastname), i+1),
+			Password:  password,
+			Lastname:  lastname,
+			Firstname: firstname,
+			Roles:     roles,
+		}
+	}
+	return users
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/migrations"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	storeRole "github.com/fabienbellanger/echo-boilerplate/store/role"
+	"github.com/fabienbellanger/echo-boilerplate/utils/password"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeedUser(t *testing.T) {
+	database, err := db.New(&db.DatabaseConfig{Driver: db.DriverSQLite, Database: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := db.NewMigrator(database, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = migrator.Up(0)
+	assert.Nil(t, err)
+	assert.Nil(t, storeRole.New(database).InitDefaultRoles())
+	hasher := password.NewBcrypt(4)
+
+	// A failed role assignment does not leave a user without roles
+	u := fixtureUser{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John", Roles: []string{"unknown"}}
+	_, err = seedUser(database, hasher, u)
+	assert.ErrorIs(t, err, store.ErrUnknownRole)
+	var count int64
+	assert.Nil(t, database.Unscoped().Model(&entities.User{}).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+
+	u.Roles = []string{entities.RoleAdmin}
+	created, err := seedUser(database, hasher, u)
+	assert.Nil(t, err)
+	assert.True(t, created)
+
+	var user entities.User
+	assert.Nil(t, database.Preload("Roles").First(&user, "username = ?", u.Username).Error)
+	assert.NotNil(t, user.VerifiedAt)
+	if assert.Len(t, user.Roles, 1) {
+		assert.Equal(t, entities.RoleAdmin, user.Roles[0].Name)
+	}
+
+	// Existing users are left unchanged
+	created, err = seedUser(database, hasher, u)
+	assert.Nil(t, err)
+	assert.False(t, created)
+}
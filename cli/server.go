@@ -4,8 +4,11 @@ import (
 	"log"
 
 	server "github.com/fabienbellanger/echo-boilerplate"
+	database "github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/migrations"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
 )
 
 func init() {
@@ -31,6 +34,19 @@ func startServer() {
 
 	// Database migrations
 	// -------------------
+	if viper.GetBool("DB_MIGRATE_ON_START") {
+		migrator, err := database.NewMigrator(db, migrations.FS)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		applied, err := migrator.Up(0)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for _, m := range applied {
+			logger.Info("Migration applied", zap.String("version", m.Version), zap.String("name", m.Name))
+		}
+	}
 	if viper.GetBool("GORM_AUTOMIGRATIONS") {
 		db.MakeMigrations()
 	}
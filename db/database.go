@@ -8,10 +8,13 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"gorm.io/plugin/prometheus"
@@ -25,11 +28,22 @@ const (
 	DefaultSlowThreshold time.Duration = 200 * time.Millisecond
 )
 
+// Supported database drivers
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// ErrInvalidConfig is returned when a required database configuration value is missing.
+var ErrInvalidConfig = errors.New("error in database configuration")
+
 // TODO: Add a custom logger for GORM : https://www.soberkoder.com/go-gorm-logging/
 
 // DatabaseConfig represents the database configuration.
+// With SQLite, Database is the path of the database file (or :memory:) and the server settings are ignored.
 type DatabaseConfig struct {
-	Driver          string // Not used
+	Driver          string // mysql | postgres | sqlite
 	Host            string
 	Username        string
 	Password        string
@@ -38,6 +52,7 @@ type DatabaseConfig struct {
 	Charset         string
 	Collation       string
 	Location        string
+	SSLMode         string        // PostgreSQL only (Default: disable)
 	MaxIdleConns    int           // Sets the maximum number of connections in the idle connection pool
 	MaxOpenConns    int           // Sets the maximum number of open connections to the database
 	ConnMaxLifetime time.Duration // Sets the maximum amount of time a connection may be reused
@@ -51,7 +66,7 @@ type DB struct {
 
 // New makes the connection to the database.
 func New(config *DatabaseConfig) (*DB, error) {
-	dsn, err := config.dsn()
+	dialector, err := config.dialector()
 	if err != nil {
 		return nil, err
 	}
@@ -83,7 +98,7 @@ func New(config *DatabaseConfig) (*DB, error) {
 		},
 	)
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: customLogger,
 	})
 	if err != nil {
@@ -92,29 +107,36 @@ func New(config *DatabaseConfig) (*DB, error) {
 
 	// Options
 	// -------
-	db.Set("gorm:table_options", "ENGINE=InnoDB")
+	if config.Driver == DriverMySQL {
+		db.Set("gorm:table_options", "ENGINE=InnoDB")
+	}
 
 	// Prometheus
 	// ----------
 	db.Use(prometheus.New(prometheus.Config{
-		DBName:          viper.GetString("DB_DATABASE"), // Use `DBName` as metrics label
-		RefreshInterval: 60,                             // Refresh metrics interval (default 15 seconds)
-		StartServer:     false,                          // Start http server to expose metrics
-		MetricsCollector: []prometheus.MetricsCollector{
-			&prometheus.MySQL{
-				VariableNames: []string{"Threads_running"},
-			},
-		}, // user defined metrics
+		DBName:           config.Database,            // Use `DBName` as metrics label
+		RefreshInterval:  60,                         // Refresh metrics interval (default 15 seconds)
+		StartServer:      false,                      // Start http server to expose metrics
+		MetricsCollector: config.metricsCollectors(), // user defined metrics
 	}))
 
 	// Connection Pool
 	// ---------------
 	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
 	sqlDB.SetMaxIdleConns(config.MaxIdleConns)
 	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
 	sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+	if config.Driver == DriverSQLite && config.Database == ":memory:" {
+		// Each connection would open a new empty in-memory database
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+	}
 
-	return &DB{db}, err
+	return &DB{db}, nil
 }
 
 // MakeMigrations runs GORM migrations.
@@ -163,10 +185,70 @@ func getGormLogOutput(output, filePath, env string) (file io.Writer, err error)
 	}
 }
 
+// dialector returns the GORM dialector of the configured driver.
+func (c *DatabaseConfig) dialector() (gorm.Dialector, error) {
+	dsn, err := c.dsn()
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Driver {
+	case DriverPostgres:
+		return postgres.Open(dsn), nil
+	case DriverSQLite:
+		return sqlite.Open(dsn), nil
+	default:
+		return mysql.Open(dsn), nil
+	}
+}
+
+// metricsCollectors returns the Prometheus collectors of the configured driver.
+// SQLite has no server status, only the connection pool stats are exposed.
+func (c *DatabaseConfig) metricsCollectors() []prometheus.MetricsCollector {
+	switch c.Driver {
+	case DriverMySQL:
+		return []prometheus.MetricsCollector{
+			&prometheus.MySQL{
+				VariableNames: []string{"Threads_running"},
+			},
+		}
+	case DriverPostgres:
+		return []prometheus.MetricsCollector{
+			&prometheus.Postgres{},
+		}
+	default:
+		return nil
+	}
+}
+
 // dsn returns the DSN if the configuration is OK or an error in other case.
-func (c *DatabaseConfig) dsn() (dsn string, err error) {
-	if c.Driver == "" || c.Host == "" || c.Database == "" || c.Port == 0 || c.Username == "" || c.Password == "" {
-		return dsn, errors.New("error in database configuration")
+func (c *DatabaseConfig) dsn() (string, error) {
+	switch c.Driver {
+	case DriverMySQL:
+		return c.mysqlDSN()
+	case DriverPostgres:
+		return c.postgresDSN()
+	case DriverSQLite:
+		return c.sqliteDSN()
+	case "":
+		return "", ErrInvalidConfig
+	default:
+		return "", fmt.Errorf("unsupported database driver %q", c.Driver)
+	}
+}
+
+// validateServer checks the settings needed to connect to a database server.
+func (c *DatabaseConfig) validateServer() error {
+	if c.Host == "" || c.Database == "" || c.Port == 0 || c.Username == "" || c.Password == "" {
+		return ErrInvalidConfig
+	}
+	return nil
+}
+
+// mysqlDSN returns the DSN of a MySQL database.
+func (c *DatabaseConfig) mysqlDSN() (dsn string, err error) {
+	if err = c.validateServer(); err != nil {
+		return
 	}
 
 	dsn = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=True",
@@ -187,6 +269,50 @@ func (c *DatabaseConfig) dsn() (dsn string, err error) {
 	return
 }
 
+// postgresDSN returns the DSN of a PostgreSQL database.
+// The charset and the collation are the ones of the database.
+// The Local location is not a PostgreSQL time zone: the time zone of the server is then used.
+func (c *DatabaseConfig) postgresDSN() (dsn string, err error) {
+	if err = c.validateServer(); err != nil {
+		return
+	}
+
+	sslMode := c.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+
+	dsn = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		postgresValue(c.Host),
+		c.Port,
+		postgresValue(c.Username),
+		postgresValue(c.Password),
+		postgresValue(c.Database),
+		postgresValue(sslMode))
+	if c.Location != "" && c.Location != "Local" {
+		dsn += fmt.Sprintf(" TimeZone=%s", postgresValue(c.Location))
+	}
+	return
+}
+
+// postgresValue quotes a value of a PostgreSQL keyword/value DSN if needed.
+// Quoted values are enclosed in single quotes, with single quotes and backslashes escaped by a backslash.
+func postgresValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\r\v\f'\\") {
+		return value
+	}
+
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// sqliteDSN returns the DSN of a SQLite database.
+func (c *DatabaseConfig) sqliteDSN() (string, error) {
+	if c.Database == "" {
+		return "", ErrInvalidConfig
+	}
+	return c.Database + "?_foreign_keys=on&_busy_timeout=5000", nil
+}
+
 // Paginate creates a GORM scope to paginate queries.
 // TODO: Continue and Optimize
 func Paginate(page, limit string) func(db *gorm.DB) *gorm.DB {
@@ -63,3 +63,70 @@ func TestDsn(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.EqualError(t, err, "error in database configuration")
 }
+
+func TestPostgresDsn(t *testing.T) {
+	c := DatabaseConfig{
+		Driver:   "postgres",
+		Host:     "localhost",
+		Username: "postgres",
+		Password: "root",
+		Port:     5432,
+		Database: "fiber",
+		Location: "UTC",
+	}
+	expected := "host=localhost port=5432 user=postgres password=root dbname=fiber sslmode=disable TimeZone=UTC"
+	wanted, err := c.dsn()
+	assert.Equal(t, expected, wanted)
+	assert.Nil(t, err)
+
+	c.SSLMode = "require"
+	c.Location = ""
+	expected = "host=localhost port=5432 user=postgres password=root dbname=fiber sslmode=require"
+	wanted, err = c.dsn()
+	assert.Equal(t, expected, wanted)
+	assert.Nil(t, err)
+
+	c.Password = `my p'a\ss`
+	c.Location = "Local"
+	expected = `host=localhost port=5432 user=postgres password='my p\'a\\ss' dbname=fiber sslmode=require`
+	wanted, err = c.dsn()
+	assert.Equal(t, expected, wanted)
+	assert.Nil(t, err)
+
+	c.Port = 0
+	_, err = c.dsn()
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestSQLiteDsn(t *testing.T) {
+	c := DatabaseConfig{
+		Driver:   "sqlite",
+		Database: "/tmp/fiber.db",
+	}
+	wanted, err := c.dsn()
+	assert.Equal(t, "/tmp/fiber.db?_foreign_keys=on&_busy_timeout=5000", wanted)
+	assert.Nil(t, err)
+
+	c.Database = ""
+	_, err = c.dsn()
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestDsnDriver(t *testing.T) {
+	_, err := (&DatabaseConfig{}).dsn()
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+
+	_, err = (&DatabaseConfig{Driver: "oracle", Database: "fiber"}).dsn()
+	assert.EqualError(t, err, `unsupported database driver "oracle"`)
+}
+
+func TestNewSQLite(t *testing.T) {
+	database, err := New(&DatabaseConfig{
+		Driver:   "sqlite",
+		Database: ":memory:",
+	})
+	assert.Nil(t, err)
+
+	assert.Nil(t, database.AutoMigrate(entitiesList...))
+	assert.True(t, database.Migrator().HasTable("users"))
+}
@@ -5,4 +5,18 @@ import "github.com/fabienbellanger/echo-boilerplate/entities"
 // entitiesList lists all entities to automigrate.
 var entitiesList = []interface{}{
 	&entities.User{},
+	&entities.Role{},
+	&entities.Permission{},
+	&entities.RefreshToken{},
+	&entities.RevokedToken{},
+	&entities.UserTokensRevocation{},
+	&entities.OneTimeToken{},
+	&entities.TOTPFactor{},
+	&entities.RecoveryCode{},
+	&entities.LoginThrottle{},
+	&entities.APIKey{},
+	&entities.UserIdentity{},
+	&entities.AuditLog{},
+	&entities.Session{},
+	&entities.PasswordHistory{},
 }
@@ -0,0 +1,401 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MigrationVersionLayout is the layout of the migration versions.
+	MigrationVersionLayout = "20060102150405"
+
+	// DefaultMigrationLockTimeout is the default time to wait for the migration lock.
+	DefaultMigrationLockTimeout = time.Minute
+
+	// DefaultMigrationLockExpiration is the default time after which a lock is considered abandoned.
+	DefaultMigrationLockExpiration = 15 * time.Minute
+)
+
+var (
+	// ErrMigrationLocked is returned when the migration lock cannot be acquired before the timeout.
+	ErrMigrationLocked = errors.New("migrations are locked by another process")
+
+	// ErrIrreversibleMigration is returned when rolling back a migration without down file.
+	ErrIrreversibleMigration = errors.New("irreversible migration")
+
+	migrationFilename = regexp.MustCompile(`^(\d{14})_(\w+)\.(up|down)\.sql$`)
+	migrationName     = regexp.MustCompile(`^\w+$`)
+)
+
+// SchemaMigration is an applied migration.
+type SchemaMigration struct {
+	Version   string    `gorm:"primaryKey;size:14"`
+	Name      string    `gorm:"size:255"`
+	AppliedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// SchemaMigrationLock is held by the process applying migrations,
+// so that only one replica migrates the database.
+type SchemaMigrationLock struct {
+	ID       uint      `gorm:"primaryKey;autoIncrement:false"`
+	LockedBy string    `gorm:"size:255"`
+	LockedAt time.Time `gorm:"index"`
+}
+
+// Migration is a versioned SQL migration.
+type Migration struct {
+	Version string
+	Name    string
+	Up      string
+	Down    string // Empty if the migration is irreversible
+}
+
+// MigrationStatus is a migration with its state in database.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+	Missing   bool // Applied but no longer in the migrations files
+}
+
+// Migrator applies the migrations of a driver.
+type Migrator struct {
+	db             *DB
+	migrations     []Migration
+	LockTimeout    time.Duration
+	LockExpiration time.Duration
+}
+
+// NewMigrator returns a new Migrator with the migrations of the database driver found in fsys.
+func NewMigrator(db *DB, fsys fs.FS) (*Migrator, error) {
+	driverFS, err := fs.Sub(fsys, db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := LoadMigrations(driverFS)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:             db,
+		migrations:     migrations,
+		LockTimeout:    DefaultMigrationLockTimeout,
+		LockExpiration: DefaultMigrationLockExpiration,
+	}, nil
+}
+
+// LoadMigrations reads the migrations files of a directory, sorted by version.
+// Files which are not migrations are ignored.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	migrations := make(map[string]*Migration)
+	for _, entry := range entries {
+		matches := migrationFilename.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil {
+			continue
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		version, name, direction := matches[1], matches[2], matches[3]
+		m, ok := migrations[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			migrations[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %s has several names (%s and %s)", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	list := make([]Migration, 0, len(migrations))
+	for _, m := range migrations {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %s_%s has no up file", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+
+	return list, nil
+}
+
+// CreateMigration creates empty up and down files in dir and returns their paths.
+func CreateMigration(dir, name string, now time.Time) (up, down string, err error) {
+	if !migrationName.MatchString(name) {
+		return "", "", fmt.Errorf("invalid migration name %q, only letters, digits and _ are allowed", name)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", err
+	}
+
+	base := path.Join(dir, now.UTC().Format(MigrationVersionLayout)+"_"+name)
+	up, down = base+".up.sql", base+".down.sql"
+	if err := os.WriteFile(up, []byte("-- "+name+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte("-- Rollback of "+name+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
+
+// Up applies the pending migrations, at most steps migrations if steps is positive.
+// It returns the applied migrations.
+func (m *Migrator) Up(steps int) (applied []Migration, err error) {
+	err = m.withLock(func() error {
+		done, err := m.appliedVersions()
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if steps > 0 && len(applied) == steps {
+				break
+			}
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+
+			if err := m.apply(migration, migration.Up, true); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return
+}
+
+// Down rolls back the last steps applied migrations (at least one) and returns them.
+func (m *Migrator) Down(steps int) (rolledBack []Migration, err error) {
+	if steps < 1 {
+		steps = 1
+	}
+
+	err = m.withLock(func() error {
+		var versions []SchemaMigration
+		if result := m.db.Order("version DESC").Limit(steps).Find(&versions); result.Error != nil {
+			return result.Error
+		}
+
+		for _, v := range versions {
+			migration, ok := m.find(v.Version)
+			if !ok {
+				return fmt.Errorf("migration %s_%s not found", v.Version, v.Name)
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("%w %s_%s", ErrIrreversibleMigration, migration.Version, migration.Name)
+			}
+
+			if err := m.apply(migration, migration.Down, false); err != nil {
+				return err
+			}
+			rolledBack = append(rolledBack, migration)
+		}
+		return nil
+	})
+	return
+}
+
+// Status lists the migrations, sorted by version, with their applied date.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	if err := m.db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+
+	done, err := m.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Migration: migration}
+		if v, ok := done[migration.Version]; ok {
+			status.AppliedAt = &v.AppliedAt
+			delete(done, migration.Version)
+		}
+		list = append(list, status)
+	}
+	for _, v := range done {
+		appliedAt := v.AppliedAt
+		list = append(list, MigrationStatus{
+			Migration: Migration{Version: v.Version, Name: v.Name},
+			AppliedAt: &appliedAt,
+			Missing:   true,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+
+	return list, nil
+}
+
+// apply executes the statements of a migration and records it.
+// Statements are run in a transaction, but MySQL commits DDL statements implicitly.
+func (m *Migrator) apply(migration Migration, sql string, up bool) error {
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range splitStatements(sql) {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+
+		if up {
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name}).Error
+		}
+		return tx.Delete(&SchemaMigration{}, "version = ?", migration.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %s_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// appliedVersions returns the applied migrations indexed by version.
+func (m *Migrator) appliedVersions() (map[string]SchemaMigration, error) {
+	var versions []SchemaMigration
+	if result := m.db.Find(&versions); result.Error != nil {
+		return nil, result.Error
+	}
+
+	done := make(map[string]SchemaMigration, len(versions))
+	for _, v := range versions {
+		done[v.Version] = v
+	}
+	return done, nil
+}
+
+// find returns the migration of a version.
+func (m *Migrator) find(version string) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// withLock runs fn while holding the migration lock.
+// The lock is a single row: inserting it fails while another process holds it.
+// A lock older than LockExpiration is considered abandoned (Ex.: crashed process) and is released,
+// so the lock date is renewed while fn runs.
+func (m *Migrator) withLock(fn func() error) error {
+	if err := m.db.AutoMigrate(&SchemaMigration{}, &SchemaMigrationLock{}); err != nil {
+		return err
+	}
+
+	hostname, _ := os.Hostname()
+	lock := SchemaMigrationLock{
+		ID:       1,
+		LockedBy: fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+	}
+
+	deadline := time.Now().Add(m.LockTimeout)
+	for {
+		if result := m.db.Where("locked_at < ?", time.Now().Add(-m.LockExpiration)).Delete(&SchemaMigrationLock{}); result.Error != nil {
+			return result.Error
+		}
+
+		var held int64
+		if result := m.db.Model(&SchemaMigrationLock{}).Count(&held); result.Error != nil {
+			return result.Error
+		}
+		if held == 0 {
+			lock.LockedAt = time.Now()
+			if result := m.db.Create(&lock); result.Error == nil {
+				break
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return ErrMigrationLocked
+		}
+		time.Sleep(time.Second)
+	}
+
+	stop := make(chan struct{})
+	renewed := make(chan struct{})
+	go m.renewLock(lock, stop, renewed)
+	defer func() {
+		close(stop)
+		<-renewed
+
+		// Only our own lock is released
+		m.db.Where("locked_by = ?", lock.LockedBy).Delete(&SchemaMigrationLock{})
+	}()
+
+	return fn()
+}
+
+// renewLock updates the lock date every third of LockExpiration until stop is closed.
+// done is closed when the renewal is stopped.
+func (m *Migrator) renewLock(lock SchemaMigrationLock, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(m.LockExpiration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.db.Model(&SchemaMigrationLock{}).
+				Where("id = ? AND locked_by = ?", lock.ID, lock.LockedBy).
+				Update("locked_at", time.Now())
+		}
+	}
+}
+
+// splitStatements splits a SQL file into statements.
+// Statements end with a semicolon at the end of a line. Comment lines are removed.
+func splitStatements(sql string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(sql, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if s := strings.TrimSpace(current.String()); s != "" {
+		statements = append(statements, s)
+	}
+
+	return statements
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/migrations"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func newTestMigrator(t *testing.T) (*DB, *Migrator) {
+	database, err := New(&DatabaseConfig{
+		Driver:   DriverSQLite,
+		Database: ":memory:",
+	})
+	assert.Nil(t, err)
+
+	migrator, err := NewMigrator(database, migrations.FS)
+	assert.Nil(t, err)
+	migrator.LockTimeout = 0
+
+	return database, migrator
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	database, migrator := newTestMigrator(t)
+
+	applied, err := migrator.Up(0)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, applied)
+	assert.True(t, database.Migrator().HasTable("users"))
+
+	// The schema contains the tables and columns of the entities
+	for _, model := range entitiesList {
+		stmt := &gorm.Statement{DB: database.DB}
+		assert.Nil(t, stmt.Parse(model))
+		assert.True(t, database.Migrator().HasTable(model), stmt.Schema.Table)
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" {
+				assert.True(t, database.Migrator().HasColumn(model, field.DBName), stmt.Schema.Table+"."+field.DBName)
+			}
+		}
+	}
+
+	applied, err = migrator.Up(0)
+	assert.Nil(t, err)
+	assert.Empty(t, applied)
+
+	status, err := migrator.Status()
+	assert.Nil(t, err)
+	for _, s := range status {
+		assert.NotNil(t, s.AppliedAt)
+		assert.False(t, s.Missing)
+	}
+
+	rolledBack, err := migrator.Down(len(status))
+	assert.Nil(t, err)
+	assert.Len(t, rolledBack, len(status))
+	assert.False(t, database.Migrator().HasTable("users"))
+}
+
+func TestMigratorUpgradeAutomigrated(t *testing.T) {
+	database, migrator := newTestMigrator(t)
+
+	// A database automigrated by a release without migrations has the schema of the initial migration
+	for _, stmt := range splitStatements(migrator.migrations[0].Up) {
+		assert.Nil(t, database.Exec(stmt).Error)
+	}
+	assert.Nil(t, database.Exec("INSERT INTO users (id, username) VALUES (?, ?)", "user-id", "john@test.com").Error)
+
+	applied, err := migrator.Up(0)
+	assert.Nil(t, err)
+	assert.Len(t, applied, len(migrator.migrations))
+
+	var version uint
+	assert.Nil(t, database.Raw("SELECT version FROM users WHERE id = ?", "user-id").Scan(&version).Error)
+	assert.Equal(t, uint(1), version, "existing users are at their first version")
+}
+
+func TestMigratorLock(t *testing.T) {
+	database, migrator := newTestMigrator(t)
+
+	assert.Nil(t, database.AutoMigrate(&SchemaMigrationLock{}))
+	assert.Nil(t, database.Create(&SchemaMigrationLock{ID: 1, LockedBy: "other", LockedAt: time.Now()}).Error)
+
+	_, err := migrator.Up(0)
+	assert.ErrorIs(t, err, ErrMigrationLocked)
+	assert.False(t, database.Migrator().HasTable("users"))
+
+	// An abandoned lock is released
+	assert.Nil(t, database.Model(&SchemaMigrationLock{}).Where("id = 1").Update("locked_at", time.Now().Add(-time.Hour)).Error)
+	_, err = migrator.Up(0)
+	assert.Nil(t, err)
+	assert.True(t, database.Migrator().HasTable("users"))
+}
+
+func TestMigratorLockRenewal(t *testing.T) {
+	database, migrator := newTestMigrator(t)
+	migrator.LockExpiration = 150 * time.Millisecond
+
+	err := migrator.withLock(func() error {
+		time.Sleep(400 * time.Millisecond)
+
+		// The lock is not considered abandoned by another process
+		result := database.Where("locked_at < ?", time.Now().Add(-migrator.LockExpiration)).Delete(&SchemaMigrationLock{})
+		assert.Nil(t, result.Error)
+		assert.Equal(t, int64(0), result.RowsAffected)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	var held int64
+	assert.Nil(t, database.Model(&SchemaMigrationLock{}).Count(&held).Error)
+	assert.Equal(t, int64(0), held)
+}
+
+func TestMigratorLockOwner(t *testing.T) {
+	database, migrator := newTestMigrator(t)
+
+	err := migrator.withLock(func() error {
+		// The lock is taken over by another process
+		if err := database.Where("id = 1").Delete(&SchemaMigrationLock{}).Error; err != nil {
+			return err
+		}
+		return database.Create(&SchemaMigrationLock{ID: 1, LockedBy: "other", LockedAt: time.Now()}).Error
+	})
+	assert.Nil(t, err)
+
+	var lock SchemaMigrationLock
+	assert.Nil(t, database.First(&lock).Error)
+	assert.Equal(t, "other", lock.LockedBy)
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"20221018120000_b.up.sql":   {Data: []byte("CREATE TABLE b (id int);")},
+		"20221018120000_b.down.sql": {Data: []byte("DROP TABLE b;")},
+		"20221017120000_a.up.sql":   {Data: []byte("CREATE TABLE a (id int);")},
+		"README.md":                 {Data: []byte("Not a migration")},
+	}
+	list, err := LoadMigrations(fsys)
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, "a", list[0].Name)
+	assert.Empty(t, list[0].Down)
+	assert.Equal(t, "b", list[1].Name)
+	assert.Equal(t, "DROP TABLE b;", list[1].Down)
+
+	_, err = LoadMigrations(fstest.MapFS{
+		"20221018120000_b.down.sql": {Data: []byte("DROP TABLE b;")},
+	})
+	assert.NotNil(t, err)
+}
+
+func TestSplitStatements(t *testing.T) {
+	sql := `-- Comment
+CREATE TABLE a (
+	id int
+);
+
+INSERT INTO a VALUES (1); -- Not a separator
+INSERT INTO a VALUES (2);
+UPDATE a SET id = 3`
+
+	assert.Equal(t, []string{
+		"CREATE TABLE a (\n\tid int\n);",
+		"INSERT INTO a VALUES (1); -- Not a separator\nINSERT INTO a VALUES (2);",
+		"UPDATE a SET id = 3",
+	}, splitStatements(sql))
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"github.com/fabienbellanger/echo-boilerplate/utils/query"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderBy creates a GORM scope to sort queries.
+// Fields must have been validated, they are used as column names.
+func OrderBy(sort []query.Sort) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, s := range sort {
+			db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: s.Field}, Desc: s.Desc})
+		}
+		return db
+	}
+}
+
+// After creates a GORM scope selecting the rows after the given sort values (keyset pagination).
+// With the sort a, -b, the condition is: a > va OR (a = va AND b < vb).
+// Sorted columns must not be nullable.
+func After(sort []query.Sort, values []interface{}) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(values) == 0 || len(values) != len(sort) {
+			return db
+		}
+
+		conditions := make([]clause.Expression, len(sort))
+		for i, s := range sort {
+			and := make([]clause.Expression, 0, i+1)
+			for j := 0; j < i; j++ {
+				and = append(and, clause.Eq{Column: clause.Column{Name: sort[j].Field}, Value: values[j]})
+			}
+
+			column := clause.Column{Name: s.Field}
+			if s.Desc {
+				and = append(and, clause.Lt{Column: column, Value: values[i]})
+			} else {
+				and = append(and, clause.Gt{Column: column, Value: values[i]})
+			}
+			conditions[i] = clause.And(and...)
+		}
+		return db.Where(clause.Or(conditions...))
+	}
+}
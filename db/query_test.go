@@ -0,0 +1,46 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fabienbellanger/echo-boilerplate/utils/query"
+	"github.com/stretchr/testify/assert"
+)
+
+type keysetItem struct {
+	ID       int64
+	Lastname string
+}
+
+func TestKeysetPagination(t *testing.T) {
+	database, err := New(&DatabaseConfig{
+		Driver:   DriverSQLite,
+		Database: ":memory:",
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, database.AutoMigrate(&keysetItem{}))
+
+	for i := 1; i <= 10; i++ {
+		assert.Nil(t, database.Create(&keysetItem{ID: int64(i), Lastname: fmt.Sprintf("name%d", i%3)}).Error)
+	}
+
+	sort := []query.Sort{{Field: "lastname", Desc: true}, {Field: "id"}}
+	var all []keysetItem
+	assert.Nil(t, database.Scopes(OrderBy(sort)).Find(&all).Error)
+
+	var walked []keysetItem
+	var after []interface{}
+	for {
+		var page []keysetItem
+		assert.Nil(t, database.Scopes(OrderBy(sort), After(sort, after)).Limit(3).Find(&page).Error)
+		walked = append(walked, page...)
+		if len(page) < 3 {
+			break
+		}
+		last := page[len(page)-1]
+		after = []interface{}{last.Lastname, last.ID}
+	}
+	assert.Equal(t, all, walked)
+	assert.Equal(t, "name2", all[0].Lastname)
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Fixtures are records indexed by table name.
+// Records are column name => value maps and must contain their primary key.
+type Fixtures map[string][]map[string]interface{}
+
+// LoadFixtures decodes YAML or JSON fixtures.
+func LoadFixtures(r io.Reader) (Fixtures, error) {
+	fixtures := make(Fixtures)
+	if err := yaml.NewDecoder(r).Decode(&fixtures); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return fixtures, nil
+}
+
+// seedTable is a table with its model.
+type seedTable struct {
+	name  string
+	model interface{}
+}
+
+// seedTables returns the tables of the registered entities followed by their join tables.
+func (db *DB) seedTables() ([]seedTable, error) {
+	var tables, joinTables []seedTable
+	for _, entity := range entitiesList {
+		stmt := &gorm.Statement{DB: db.DB}
+		if err := stmt.Parse(entity); err != nil {
+			return nil, err
+		}
+		tables = append(tables, seedTable{name: stmt.Schema.Table, model: entity})
+		for _, rel := range stmt.Schema.Relationships.Many2Many {
+			joinTables = append(joinTables, seedTable{
+				name:  rel.JoinTable.Table,
+				model: reflect.New(rel.JoinTable.ModelType).Interface(),
+			})
+		}
+	}
+	return append(tables, joinTables...), nil
+}
+
+// Seed inserts the fixtures records which do not exist yet, so that it can be run several times.
+// It returns the number of inserted records by table.
+func (db *DB) Seed(fixtures Fixtures) (map[string]int64, error) {
+	tables, err := db.seedTables()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		known[table.name] = true
+	}
+	for table := range fixtures {
+		if !known[table] {
+			return nil, fmt.Errorf("unknown fixtures table %q", table)
+		}
+	}
+
+	inserted := make(map[string]int64)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range tables {
+			for _, record := range fixtures[table.name] {
+				values, err := fixtureValues(record)
+				if err != nil {
+					return fmt.Errorf("%s: %w", table.name, err)
+				}
+
+				result := tx.Model(table.model).Table(table.name).Clauses(clause.OnConflict{DoNothing: true}).Create(values)
+				if result.Error != nil {
+					return fmt.Errorf("%s: %w", table.name, result.Error)
+				}
+				if result.RowsAffected > 0 {
+					inserted[table.name] += result.RowsAffected
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return inserted, nil
+}
+
+// fixtureValues returns the values of a record to insert.
+// Lists and objects are stored as JSON (Ex.: API keys scopes).
+func fixtureValues(record map[string]interface{}) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(record))
+	for column, value := range record {
+		switch value.(type) {
+		case []interface{}, map[string]interface{}:
+			b, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			values[column] = string(b)
+		default:
+			values[column] = value
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeed(t *testing.T) {
+	database, err := New(&DatabaseConfig{
+		Driver:   DriverSQLite,
+		Database: ":memory:",
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, database.AutoMigrate(entitiesList...))
+
+	fixtures, err := LoadFixtures(strings.NewReader(`
+roles:
+  - id: 10
+    name: support
+permissions:
+  - id: 20
+    name: support:read
+role_permissions:
+  - role_id: 10
+    permission_id: 20
+api_keys:
+  - id: 1f0c1a7e-8e8a-4d57-9a55-08c5b2e6f2c5
+    user_id: 9b2a6f4e-2f0e-4b8e-8c1c-4a7a1b3c5d6e
+    name: CI
+    prefix: ebk_abcd
+    hash: 0123456789abcdef
+    scopes: [users:read]
+`))
+	assert.Nil(t, err)
+
+	inserted, err := database.Seed(fixtures)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int64{"roles": 1, "permissions": 1, "role_permissions": 1, "api_keys": 1}, inserted)
+
+	var role entities.Role
+	assert.Nil(t, database.Preload("Permissions").First(&role, "name = ?", "support").Error)
+	assert.Len(t, role.Permissions, 1)
+
+	var apiKey entities.APIKey
+	assert.Nil(t, database.First(&apiKey).Error)
+	assert.Equal(t, []string{"users:read"}, apiKey.Scopes)
+
+	// Seeding is idempotent
+	inserted, err = database.Seed(fixtures)
+	assert.Nil(t, err)
+	assert.Empty(t, inserted)
+
+	_, err = database.Seed(Fixtures{"unknown": nil})
+	assert.EqualError(t, err, `unknown fixtures table "unknown"`)
+}
+
+func TestLoadFixturesJSON(t *testing.T) {
+	fixtures, err := LoadFixtures(strings.NewReader(`{"roles": [{"id": 1, "name": "support"}]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "support", fixtures["roles"][0]["name"])
+
+	fixtures, err = LoadFixtures(strings.NewReader(""))
+	assert.Nil(t, err)
+	assert.Empty(t, fixtures)
+}
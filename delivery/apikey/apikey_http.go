@@ -0,0 +1,137 @@
+package apikey
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/fabienbellanger/echo-boilerplate/utils"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type APIKeyHandler struct {
+	group       *echo.Group
+	store       store.APIKeyStorer
+	maxLifetime time.Duration
+}
+
+// New returns a new APIKeyHandler.
+// maxLifetime limits the lifetime of the keys, 0 for no limit.
+func New(g *echo.Group, s store.APIKeyStorer, maxLifetime time.Duration) APIKeyHandler {
+	return APIKeyHandler{
+		group:       g,
+		store:       s,
+		maxLifetime: maxLifetime,
+	}
+}
+
+// Routes adds API keys routes
+func (h *APIKeyHandler) Routes() {
+	h.group.POST("", h.create())
+	h.group.GET("", h.getAll())
+	h.group.DELETE("/:id", h.revoke())
+}
+
+// claims returns the claims of the request.
+// API keys cannot be managed with an API key or while impersonating the user.
+func claims(c echo.Context) (*entities.Claims, error) {
+	claims, err := entities.ClaimsFromToken(c.Get("user"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+	if claims.APIKeyID != "" {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "API keys cannot be managed with an API key")
+	}
+	if claims.IsImpersonation() {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "API keys cannot be managed while impersonating")
+	}
+	return claims, nil
+}
+
+// create creates an API key, the key is only returned by this call
+func (h APIKeyHandler) create() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, err := claims(c)
+		if err != nil {
+			return err
+		}
+
+		form := new(entities.APIKeyForm)
+		if err := c.Bind(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bad data")
+		}
+
+		formErrors := utils.ValidateStruct(*form)
+		if formErrors != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, formErrors)
+		}
+
+		// Scopes must be granted to the user
+		for _, scope := range form.Scopes {
+			if !claims.HasPermission(scope) {
+				return echo.NewHTTPError(http.StatusBadRequest, "Scope not granted: "+scope)
+			}
+		}
+
+		lifetime := time.Duration(form.ExpiresIn) * 24 * time.Hour
+		if h.maxLifetime > 0 && (lifetime == 0 || lifetime > h.maxLifetime) {
+			lifetime = h.maxLifetime
+		}
+		var expiresAt *time.Time
+		if lifetime > 0 {
+			t := time.Now().Add(lifetime)
+			expiresAt = &t
+		}
+
+		apiKey, err := h.store.Create(claims.UserID, form.Name, form.Scopes, expiresAt)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when creating API key")
+		}
+
+		return c.JSON(http.StatusCreated, apiKey)
+	}
+}
+
+// getAll lists the API keys of the user
+func (h APIKeyHandler) getAll() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, err := claims(c)
+		if err != nil {
+			return err
+		}
+
+		keys, err := h.store.GetUserAPIKeys(claims.UserID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when retrieving API keys")
+		}
+
+		return c.JSON(http.StatusOK, keys)
+	}
+}
+
+// revoke revokes an API key of the user
+func (h APIKeyHandler) revoke() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, err := claims(c)
+		if err != nil {
+			return err
+		}
+
+		id := c.Param("id")
+		if id == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bad ID")
+		}
+
+		if err := h.store.Revoke(claims.UserID, id); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "No API key found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when revoking API key")
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
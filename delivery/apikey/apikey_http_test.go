@@ -0,0 +1,113 @@
+package apikey
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// apiKeyStoreStub is a store.APIKeyStorer recording the created keys.
+type apiKeyStoreStub struct {
+	created []entities.APIKey
+}
+
+func (s *apiKeyStoreStub) Create(userID, name string, scopes []string, expiresAt *time.Time) (entities.APIKey, error) {
+	apiKey := entities.APIKey{ID: "key-id", UserID: userID, Name: name, Scopes: scopes, ExpiresAt: expiresAt, Key: entities.APIKeyPrefix + "secret"}
+	s.created = append(s.created, apiKey)
+	return apiKey, nil
+}
+func (s *apiKeyStoreStub) GetUserAPIKeys(userID string) ([]entities.APIKey, error) {
+	return s.created, nil
+}
+func (s *apiKeyStoreStub) Revoke(userID, id string) error {
+	if id != "key-id" {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+func (s *apiKeyStoreStub) Authenticate(key string) (entities.APIKey, error) {
+	return entities.APIKey{}, nil
+}
+
+// newContext returns a context authenticated with the claims.
+func newContext(e *echo.Echo, method, body string, claims *entities.Claims) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user", &jwt.Token{Claims: claims, Valid: true})
+	return c, rec
+}
+
+func TestCreate(t *testing.T) {
+	s := &apiKeyStoreStub{}
+	h := New(nil, s, 30*24*time.Hour)
+	e := echo.New()
+	claims := entities.NewClaims("user-id", "john@test.com", "Doe", "John", 10)
+	claims.Permissions = []string{entities.PermissionUsersRead, entities.PermissionRolesRead}
+
+	// Scopes must be granted to the user
+	c, _ := newContext(e, http.MethodPost, `{"name": "CI", "scopes": ["users:read", "users:delete"]}`, claims)
+	err := h.create()(c)
+	assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+	assert.Empty(t, s.created)
+
+	c, rec := newContext(e, http.MethodPost, `{"name": "CI", "scopes": ["users:read"], "expires_in": 365}`, claims)
+	assert.Nil(t, h.create()(c))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var apiKey entities.APIKey
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &apiKey))
+	assert.Equal(t, entities.APIKeyPrefix+"secret", apiKey.Key, "the key is returned at creation")
+	if assert.Len(t, s.created, 1) {
+		assert.Equal(t, "user-id", s.created[0].UserID)
+		assert.Equal(t, []string{entities.PermissionUsersRead}, s.created[0].Scopes)
+		if assert.NotNil(t, s.created[0].ExpiresAt) {
+			assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), *s.created[0].ExpiresAt, time.Minute, "the lifetime is limited")
+		}
+	}
+}
+
+func TestManagementForbidden(t *testing.T) {
+	h := New(nil, &apiKeyStoreStub{}, 0)
+	e := echo.New()
+
+	apiKeyClaims := entities.NewClaims("user-id", "john@test.com", "Doe", "John", 10)
+	apiKeyClaims.APIKeyID = "key-id"
+	impersonationClaims := entities.NewClaims("user-id", "john@test.com", "Doe", "John", 10)
+	impersonationClaims.Actor = &entities.Actor{UserID: "admin-id"}
+
+	for _, claims := range []*entities.Claims{apiKeyClaims, impersonationClaims} {
+		for _, handler := range []echo.HandlerFunc{h.create(), h.getAll(), h.revoke()} {
+			c, _ := newContext(e, http.MethodPost, `{"name": "CI", "scopes": ["users:read"]}`, claims)
+			err := handler(c)
+			assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+		}
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	h := New(nil, &apiKeyStoreStub{}, 0)
+	e := echo.New()
+	claims := entities.NewClaims("user-id", "john@test.com", "Doe", "John", 10)
+
+	c, rec := newContext(e, http.MethodDelete, "", claims)
+	c.SetParamNames("id")
+	c.SetParamValues("key-id")
+	assert.Nil(t, h.revoke()(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	c, _ = newContext(e, http.MethodDelete, "", claims)
+	c.SetParamNames("id")
+	c.SetParamValues("unknown")
+	err := h.revoke()(c)
+	assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+}
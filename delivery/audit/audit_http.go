@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/delivery/middlewares"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/fabienbellanger/echo-boilerplate/utils/query"
+	"github.com/labstack/echo/v4"
+)
+
+type auditLogsPage struct {
+	Data       []entities.AuditLog `json:"data" xml:"data" form:"data"`
+	Total      int64               `json:"total" xml:"total" form:"total"`
+	Page       int                 `json:"page" xml:"page" form:"page"`
+	Limit      int                 `json:"limit" xml:"limit" form:"limit"`
+	NextCursor string              `json:"next_cursor,omitempty" xml:"next_cursor,omitempty" form:"next_cursor"`
+}
+
+// auditLogsQuery lists the pagination and filter parameters of the audit logs list.
+// Logs are always sorted newest first.
+var auditLogsQuery = query.Config{
+	DefaultSort: []query.Sort{{Field: "created_at", Desc: true}},
+	Tiebreaker:  "id",
+	Filters: map[string]query.FilterType{
+		"actor_id":    query.FilterString,
+		"target_type": query.FilterString,
+		"target_id":   query.FilterString,
+		"action":      query.FilterString,
+		"since":       query.FilterTime,
+		"until":       query.FilterTime,
+	},
+	DefaultLimit: db.MaxLimit,
+	MaxLimit:     db.MaxLimit,
+}
+
+type AuditHandler struct {
+	group *echo.Group
+	store store.AuditStorer
+}
+
+// New returns a new AuditHandler
+func New(g *echo.Group, s store.AuditStorer) AuditHandler {
+	return AuditHandler{
+		group: g,
+		store: s,
+	}
+}
+
+// Routes adds audit routes
+func (a *AuditHandler) Routes() {
+	a.group.GET("", a.getAll(), middlewares.RequirePermission(entities.PermissionAuditRead))
+}
+
+// getAll lists audit logs, newest first, by page or with a cursor.
+// The next page links are also sent in the Link header.
+func (a AuditHandler) getAll() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		params, queryErrors := query.Parse(c.QueryParams(), auditLogsQuery)
+		if queryErrors != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, queryErrors)
+		}
+
+		logs, total, err := a.store.GetAuditLogs(auditFilter(params.Filters), params)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when retrieving audit logs")
+		}
+
+		var nextCursor string
+		if params.HasMore(len(logs)) {
+			logs = logs[:params.Limit]
+			last := logs[len(logs)-1]
+			nextCursor, err = query.EncodeCursor(params.Sort, []interface{}{last.CreatedAt, last.ID})
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Error when retrieving audit logs")
+			}
+		}
+
+		if links := query.Links(*c.Request().URL, params, total, nextCursor); links != "" {
+			c.Response().Header().Set("Link", links)
+		}
+
+		return c.JSON(http.StatusOK, auditLogsPage{
+			Data:       logs,
+			Total:      total,
+			Page:       params.Page,
+			Limit:      params.Limit,
+			NextCursor: nextCursor,
+		})
+	}
+}
+
+// auditFilter returns the store filter of the parsed list filters.
+func auditFilter(filters map[string]interface{}) entities.AuditFilter {
+	filter := entities.AuditFilter{}
+	filter.ActorID, _ = filters["actor_id"].(string)
+	filter.TargetType, _ = filters["target_type"].(string)
+	filter.TargetID, _ = filters["target_id"].(string)
+	filter.Action, _ = filters["action"].(string)
+	if since, ok := filters["since"].(time.Time); ok {
+		filter.Since = &since
+	}
+	if until, ok := filters["until"].(time.Time); ok {
+		filter.Until = &until
+	}
+	return filter
+}
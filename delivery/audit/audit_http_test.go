@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/utils/query"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// auditStoreStub is a store.AuditStorer returning its logs, ignoring the filter and the cursor.
+type auditStoreStub struct {
+	logs   []entities.AuditLog
+	filter entities.AuditFilter
+}
+
+func (s *auditStoreStub) Create(log entities.AuditLog) error { return nil }
+
+func (s *auditStoreStub) GetAuditLogs(filter entities.AuditFilter, params query.Params) ([]entities.AuditLog, int64, error) {
+	s.filter = filter
+	logs := s.logs
+	if len(logs) > params.FetchLimit() {
+		logs = logs[:params.FetchLimit()]
+	}
+	return logs, int64(len(s.logs)), nil
+}
+
+func (s *auditStoreStub) Export(filter entities.AuditFilter, fn func(entities.AuditLog) error) error {
+	return nil
+}
+
+func TestGetAll(t *testing.T) {
+	s := &auditStoreStub{}
+	for i := 0; i < 3; i++ {
+		s.logs = append(s.logs, entities.AuditLog{ID: fmt.Sprintf("log-%d", i), CreatedAt: time.Now()})
+	}
+	h := New(nil, s)
+	e := echo.New()
+
+	getAll := func(target string) (auditLogsPage, error) {
+		rec := httptest.NewRecorder()
+		var page auditLogsPage
+		if err := h.getAll()(e.NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec)); err != nil {
+			return page, err
+		}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		return page, nil
+	}
+
+	page, err := getAll("/?limit=2&action=update&since=2022-01-01T00:00:00Z")
+	assert.Nil(t, err)
+	assert.Len(t, page.Data, 2)
+	assert.Equal(t, int64(3), page.Total)
+	assert.NotEmpty(t, page.NextCursor)
+	assert.Equal(t, entities.AuditActionUpdate, s.filter.Action)
+	if assert.NotNil(t, s.filter.Since) {
+		assert.Equal(t, 2022, s.filter.Since.Year())
+	}
+	assert.Nil(t, s.filter.Until)
+
+	// The page is exactly the last one
+	page, err = getAll("/?limit=3")
+	assert.Nil(t, err)
+	assert.Len(t, page.Data, 3)
+	assert.Empty(t, page.NextCursor)
+
+	_, err = getAll("/?until=yesterday")
+	assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+
+	_, err = getAll("/?sort=action")
+	assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+}
@@ -0,0 +1,394 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/delivery/middlewares"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/fabienbellanger/echo-boilerplate/utils"
+	"github.com/fabienbellanger/echo-boilerplate/utils/mailer"
+	"github.com/fabienbellanger/echo-boilerplate/utils/oidc"
+	"github.com/fabienbellanger/echo-boilerplate/utils/password"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type userLogin struct {
+	entities.User
+	tokens
+}
+
+type tokens struct {
+	Token                 string `json:"token" xml:"token" form:"token"`
+	ExpiresAt             string `json:"expires_at" xml:"expires_at" form:"expires_at"`
+	RefreshToken          string `json:"refresh_token" xml:"refresh_token" form:"refresh_token"`
+	RefreshTokenExpiresAt string `json:"refresh_token_expires_at" xml:"refresh_token_expires_at" form:"refresh_token_expires_at"`
+}
+
+type userAuth struct {
+	Username string `json:"username" xml:"username" form:"username" validate:"required,email"`
+	Password string `json:"password" xml:"password" form:"password" validate:"required,min=8"`
+}
+
+type tokenRefresh struct {
+	RefreshToken string `json:"refresh_token" xml:"refresh_token" form:"refresh_token" validate:"required"`
+}
+
+type userLogout struct {
+	RefreshToken string `json:"refresh_token" xml:"refresh_token" form:"refresh_token"`
+	All          bool   `json:"all" xml:"all" form:"all"`
+}
+
+type AuthHandler struct {
+	group              *echo.Group
+	userStore          store.UserStorer
+	tokenStore         store.RefreshTokenStorer
+	revocationStore    store.TokenRevocationStorer
+	roleStore          store.RoleStorer
+	mfaStore           store.MFAStorer
+	throttleStore      store.LoginThrottleStorer
+	emailThrottleStore store.LoginThrottleStorer
+	oneTimeTokenStore  store.OneTimeTokenStorer
+	identityStore      store.IdentityStorer
+	sessionStore       store.SessionStorer
+	auditStore         store.AuditStorer
+	passwordPolicy     password.Policy
+	oidcProviders      map[string]*oidc.Provider
+	keySet             entities.JWTKeySet
+	mailer             mailer.Mailer
+	logger             *zap.Logger
+}
+
+// Dependencies lists the stores and services used by AuthHandler.
+type Dependencies struct {
+	UserStore          store.UserStorer
+	TokenStore         store.RefreshTokenStorer
+	RevocationStore    store.TokenRevocationStorer
+	RoleStore          store.RoleStorer
+	MFAStore           store.MFAStorer
+	ThrottleStore      store.LoginThrottleStorer
+	EmailThrottleStore store.LoginThrottleStorer // Throttles the emails requested per username and IP address
+	OneTimeTokenStore  store.OneTimeTokenStorer
+	IdentityStore      store.IdentityStorer
+	SessionStore       store.SessionStorer
+	AuditStore         store.AuditStorer
+	PasswordPolicy     password.Policy
+	OIDCProviders      map[string]*oidc.Provider // Indexed by name
+	KeySet             entities.JWTKeySet
+	Mailer             mailer.Mailer
+	Logger             *zap.Logger
+}
+
+// New returns a new AuthHandler
+func New(g *echo.Group, deps Dependencies) AuthHandler {
+	return AuthHandler{
+		group:              g,
+		userStore:          deps.UserStore,
+		tokenStore:         deps.TokenStore,
+		revocationStore:    deps.RevocationStore,
+		roleStore:          deps.RoleStore,
+		mfaStore:           deps.MFAStore,
+		throttleStore:      deps.ThrottleStore,
+		emailThrottleStore: deps.EmailThrottleStore,
+		oneTimeTokenStore:  deps.OneTimeTokenStore,
+		identityStore:      deps.IdentityStore,
+		sessionStore:       deps.SessionStore,
+		auditStore:         deps.AuditStore,
+		passwordPolicy:     deps.PasswordPolicy,
+		oidcProviders:      deps.OIDCProviders,
+		keySet:             deps.KeySet,
+		mailer:             deps.Mailer,
+		logger:             deps.Logger,
+	}
+}
+
+// Routes adds public authentication routes
+func (a *AuthHandler) Routes() {
+	a.group.POST("/login", a.login())
+	a.group.POST("/login/mfa", a.loginMFA())
+	a.group.POST("/token/refresh", a.refresh())
+	a.group.POST("/password/forgot", a.forgotPassword())
+	a.group.POST("/password/reset", a.resetPassword())
+	a.group.GET("/users/verify/:token", a.verifyEmail())
+	a.group.GET("/auth/oidc/:provider/login", a.oidcLogin())
+	a.group.GET("/auth/oidc/:provider/callback", a.oidcCallback()).Name = "oidcCallback"
+}
+
+// ProtectedRoutes adds authentication routes which need a valid JWT.
+// These routes cannot be used with an API key.
+// An admin impersonating a user cannot log the user out, terminate their sessions or manage their MFA.
+func (a *AuthHandler) ProtectedRoutes(g *echo.Group) {
+	g = g.Group("", middlewares.RejectAPIKey())
+	noImpersonation := middlewares.RejectImpersonation()
+
+	g.POST("/logout", a.logout(), noImpersonation)
+
+	g.GET("/me/sessions", a.getSessions())
+	g.DELETE("/me/sessions/:id", a.terminateSession(), noImpersonation)
+
+	mfa := g.Group("/me/mfa", noImpersonation)
+	mfa.POST("/totp", a.enrollTOTP())
+	mfa.POST("/totp/confirm", a.confirmTOTP())
+	mfa.DELETE("/totp", a.disableTOTP())
+}
+
+// UserRoutes adds authentication routes to the users group
+func (a *AuthHandler) UserRoutes(g *echo.Group) {
+	g.POST("/:id/unlock", a.unlock(), middlewares.RequirePermission(entities.PermissionUsersUnlock))
+	g.POST("/:id/impersonate", a.impersonate(), middlewares.RequirePermission(entities.PermissionUsersImpersonate))
+}
+
+// login authenticates a user and returns an access token and a refresh token.
+// If the user has enabled a second factor, a short-lived MFA token is returned instead
+// and must be exchanged with a valid code on /login/mfa.
+func (a AuthHandler) login() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ua := new(userAuth)
+		if err := c.Bind(ua); err != nil {
+			return err
+		}
+
+		loginErrors := utils.ValidateStruct(*ua)
+		if loginErrors != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, loginErrors)
+		}
+
+		// Brute-force protection
+		// ----------------------
+		blockedFor, err := a.throttleStore.Check(ua.Username, c.RealIP())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication")
+		}
+		if blockedFor > 0 {
+			return tooManyAttempts(c, blockedFor)
+		}
+
+		user, err := a.userStore.WithContext(c.Request().Context()).Login(ua.Username, ua.Password)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return a.loginFailed(c, ua.Username)
+			}
+			if errors.Is(err, store.ErrInvalidPasswordHash) {
+				a.logger.Error("Invalid stored password hash", zap.String("username", ua.Username), zap.Error(err))
+				return a.loginFailed(c, ua.Username)
+			}
+			if errors.Is(err, store.ErrUserNotVerified) {
+				return echo.NewHTTPError(http.StatusForbidden, err.Error())
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication").SetInternal(err)
+		}
+
+		return a.completeLogin(c, user)
+	}
+}
+
+// completeLogin issues the tokens of a user authenticated by a first factor.
+// If the user has enabled a second factor, a short-lived MFA token is returned instead.
+func (a AuthHandler) completeLogin(c echo.Context, user entities.User) error {
+	factor, err := a.mfaStore.GetTOTP(user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication")
+	}
+	if factor.Enabled() {
+		claims := entities.NewMFAClaims(user.ID, viper.GetInt("MFA_TOKEN_LIFETIME"))
+		token, err := a.keySet.Sign(claims)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, mfaPending{
+			MFARequired: true,
+			MFAToken:    token,
+			ExpiresAt:   time.Unix(claims.ExpiresAt, 0).Format("2006-01-02T15:04:05.000Z"),
+		})
+	}
+
+	return a.loginResponse(c, user)
+}
+
+// loginResponse issues the tokens of an authenticated user.
+func (a AuthHandler) loginResponse(c echo.Context, user entities.User) error {
+	if err := a.throttleStore.RegisterSuccess(user.Username); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication")
+	}
+
+	// The session is created first so that a refresh token is never issued without its session.
+	// It shares the ID of the refresh token family.
+	lifetime := refreshTokenLifetime()
+	session := entities.Session{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		UserAgent: truncate(c.Request().UserAgent(), sessionUserAgentMaxLength),
+		IP:        c.RealIP(),
+		ExpiresAt: time.Now().Add(lifetime),
+	}
+	if err := a.sessionStore.Create(&session); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication")
+	}
+
+	refreshToken, err := a.tokenStore.Create(user.ID, session.ID, lifetime)
+	if err != nil {
+		if err := a.sessionStore.Terminate(user.ID, session.ID); err != nil {
+			a.logger.Error("Session termination failed", zap.String("session", session.ID), zap.Error(err))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication")
+	}
+
+	t, err := a.generateTokens(user, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, userLogin{
+		User:   user,
+		tokens: t,
+	})
+}
+
+// refresh exchanges a refresh token for a new access token and a new refresh token.
+func (a AuthHandler) refresh() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		tr := new(tokenRefresh)
+		if err := c.Bind(tr); err != nil {
+			return err
+		}
+
+		refreshErrors := utils.ValidateStruct(*tr)
+		if refreshErrors != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, refreshErrors)
+		}
+
+		refreshToken, err := a.tokenStore.Rotate(tr.RefreshToken, refreshTokenLifetime())
+		if err != nil {
+			if errors.Is(err, store.ErrRefreshTokenInvalid) || errors.Is(err, store.ErrRefreshTokenReused) {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during token refresh")
+		}
+
+		user, err := a.userStore.WithContext(c.Request().Context()).GetUser(refreshToken.UserID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during token refresh").SetInternal(err)
+		}
+		if user.ID == "" {
+			a.tokenStore.RevokeFamily(refreshToken.FamilyID)
+			return echo.NewHTTPError(http.StatusUnauthorized, nil)
+		}
+
+		if err := a.sessionStore.Refresh(refreshToken.FamilyID, c.RealIP(), refreshToken.ExpiresAt); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during token refresh")
+		}
+
+		t, err := a.generateTokens(user, refreshToken)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, t)
+	}
+}
+
+// verifyEmail marks the email address of a user as verified with the token received by email.
+func (a AuthHandler) verifyEmail() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, err := a.oneTimeTokenStore.Consume(c.Param("token"), entities.TokenPurposeEmailVerification)
+		if err != nil {
+			if errors.Is(err, store.ErrOneTimeTokenInvalid) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during email verification")
+		}
+
+		if err := a.userStore.WithContext(c.Request().Context()).WithAudit(auditMeta(c, userID)).VerifyUser(userID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during email verification").SetInternal(err)
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// logout revokes the current access token and terminates its session.
+// The given refresh token is also revoked for tokens issued without session.
+// If all is true, all the tokens and sessions of the user are revoked.
+func (a AuthHandler) logout() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ul := new(userLogout)
+		if err := c.Bind(ul); err != nil {
+			return err
+		}
+
+		claims, err := entities.ClaimsFromToken(c.Get("user"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+
+		if ul.All {
+			if err := a.revocationStore.RevokeAllForUser(claims.UserID); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Error during logout")
+			}
+			return c.NoContent(http.StatusNoContent)
+		}
+
+		if err := a.revocationStore.Revoke(claims.Id, claims.UserID, time.Unix(claims.ExpiresAt, 0)); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during logout")
+		}
+		if claims.SessionID != "" {
+			if err := a.sessionStore.Terminate(claims.UserID, claims.SessionID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Error during logout")
+			}
+		}
+		if ul.RefreshToken != "" {
+			if err := a.tokenStore.Revoke(ul.RefreshToken); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Error during logout")
+			}
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// generateTokens generates a new access token for the user.
+// The user roles and permissions are added to the claims.
+func (a AuthHandler) generateTokens(user entities.User, refreshToken entities.RefreshToken) (tokens, error) {
+	roles, permissions, err := a.roleStore.GetUserRolesAndPermissions(user.ID)
+	if err != nil {
+		return tokens{}, err
+	}
+
+	claims := entities.NewClaims(user.ID, user.Username, user.Lastname, user.Firstname, viper.GetInt("JWT_LIFETIME"))
+	claims.Roles = roles
+	claims.Permissions = permissions
+	claims.SessionID = refreshToken.FamilyID
+
+	token, err := claims.GenerateJWT(a.keySet)
+	if err != nil {
+		return tokens{}, err
+	}
+
+	return tokens{
+		Token:                 token,
+		ExpiresAt:             time.Unix(claims.ExpiresAt, 0).Format("2006-01-02T15:04:05.000Z"),
+		RefreshToken:          refreshToken.Token,
+		RefreshTokenExpiresAt: refreshToken.ExpiresAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+	}, nil
+}
+
+// refreshTokenLifetime returns the refresh token lifetime.
+func refreshTokenLifetime() time.Duration {
+	return time.Duration(viper.GetInt("JWT_REFRESH_LIFETIME")) * time.Hour * 24
+}
+
+// auditMeta returns the audit information of a request made by the actor.
+func auditMeta(c echo.Context, actorID string) entities.AuditMeta {
+	return entities.AuditMeta{
+		ActorID:   actorID,
+		IP:        c.RealIP(),
+		RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+	}
+}
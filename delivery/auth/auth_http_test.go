@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// newProtectedRoutes returns a server serving the protected routes, authenticated with the claims.
+// Stores are not set: requests must be rejected before reaching the handlers.
+func newProtectedRoutes(claims *entities.Claims) *echo.Echo {
+	e := echo.New()
+	g := e.Group("", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("user", &jwt.Token{Claims: claims, Valid: true})
+			return next(c)
+		}
+	})
+
+	h := New(nil, Dependencies{})
+	h.ProtectedRoutes(g)
+	return e
+}
+
+func TestProtectedRoutesImpersonation(t *testing.T) {
+	claims := entities.NewClaims("user-id", "john@test.com", "Doe", "John", 5)
+	claims.Actor = &entities.Actor{UserID: "admin-id", Username: "admin@test.com"}
+	e := newProtectedRoutes(claims)
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPost, "/logout"},
+		{http.MethodDelete, "/me/sessions/session-id"},
+		{http.MethodPost, "/me/mfa/totp"},
+		{http.MethodPost, "/me/mfa/totp/confirm"},
+		{http.MethodDelete, "/me/mfa/totp"},
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(route.method, route.path, nil))
+		assert.Equal(t, http.StatusForbidden, rec.Code, route.method+" "+route.path)
+	}
+}
+
+func TestProtectedRoutesAPIKey(t *testing.T) {
+	e := newProtectedRoutes(&entities.Claims{UserID: "user-id", Username: "john@test.com", APIKeyID: "key-id"})
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPost, "/logout"},
+		{http.MethodGet, "/me/sessions"},
+		{http.MethodDelete, "/me/sessions/session-id"},
+		{http.MethodPost, "/me/mfa/totp"},
+		{http.MethodPost, "/me/mfa/totp/confirm"},
+		{http.MethodDelete, "/me/mfa/totp"},
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(route.method, route.path, nil))
+		assert.Equal(t, http.StatusForbidden, rec.Code, route.method+" "+route.path)
+	}
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+type impersonation struct {
+	User      entities.User `json:"user" xml:"user" form:"user"`
+	Token     string        `json:"token" xml:"token" form:"token"`
+	ExpiresAt string        `json:"expires_at" xml:"expires_at" form:"expires_at"`
+}
+
+// impersonate issues a short-lived access token of a user to an admin.
+// The token carries the admin in the act claim, has no refresh token and no session.
+// Admins cannot impersonate users having permissions they do not have.
+func (a AuthHandler) impersonate() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Param("id")
+		if id == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bad ID")
+		}
+
+		claims, err := entities.ClaimsFromToken(c.Get("user"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+		if claims.APIKeyID != "" {
+			return echo.NewHTTPError(http.StatusForbidden, "Impersonation is not allowed with an API key")
+		}
+		if claims.IsImpersonation() {
+			return echo.NewHTTPError(http.StatusForbidden, "Impersonation is not allowed while impersonating")
+		}
+		if claims.UserID == id {
+			return echo.NewHTTPError(http.StatusBadRequest, "Users cannot impersonate themselves")
+		}
+
+		user, err := a.userStore.WithContext(c.Request().Context()).GetUser(id)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when retrieving user").SetInternal(err)
+		}
+		if user.ID == "" {
+			return echo.NewHTTPError(http.StatusNotFound, "No user found")
+		}
+
+		roles, permissions, err := a.roleStore.GetUserRolesAndPermissions(user.ID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during impersonation")
+		}
+		for _, p := range permissions {
+			if !claims.HasPermission(p) {
+				return echo.NewHTTPError(http.StatusForbidden, "Users with more permissions cannot be impersonated")
+			}
+		}
+
+		impersonationClaims := entities.NewClaims(user.ID, user.Username, user.Lastname, user.Firstname, viper.GetInt("IMPERSONATION_LIFETIME"))
+		impersonationClaims.Roles = roles
+		impersonationClaims.Permissions = permissions
+		impersonationClaims.Actor = &entities.Actor{
+			UserID:   claims.UserID,
+			Username: claims.Username,
+		}
+
+		token, err := impersonationClaims.GenerateJWT(a.keySet)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during impersonation")
+		}
+
+		if err := a.auditStore.Create(entities.NewAuditLog(auditMeta(c, claims.UserID), entities.AuditActionImpersonate, entities.AuditTargetUser, user.ID, nil)); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during impersonation")
+		}
+
+		a.logger.Warn("User impersonated",
+			zap.String("userId", user.ID),
+			zap.String("actorId", claims.UserID),
+			zap.String("ip", c.RealIP()),
+		)
+
+		return c.JSON(http.StatusOK, impersonation{
+			User:      user,
+			Token:     token,
+			ExpiresAt: time.Unix(impersonationClaims.ExpiresAt, 0).Format("2006-01-02T15:04:05.000Z"),
+		})
+	}
+}
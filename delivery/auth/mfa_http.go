@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/utils"
+	"github.com/fabienbellanger/echo-boilerplate/utils/totp"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+)
+
+// totpSkew is the number of time steps accepted before and after the current one.
+const totpSkew = 1
+
+var totpCodeRegexp = regexp.MustCompile(`^[0-9]{6}$`)
+
+type mfaPending struct {
+	MFARequired bool   `json:"mfa_required" xml:"mfa_required" form:"mfa_required"`
+	MFAToken    string `json:"mfa_token" xml:"mfa_token" form:"mfa_token"`
+	ExpiresAt   string `json:"expires_at" xml:"expires_at" form:"expires_at"`
+}
+
+type mfaLogin struct {
+	MFAToken string `json:"mfa_token" xml:"mfa_token" form:"mfa_token" validate:"required"`
+	Code     string `json:"code" xml:"code" form:"code" validate:"required"`
+}
+
+type totpEnrollment struct {
+	Secret string `json:"secret" xml:"secret" form:"secret"`
+	URI    string `json:"uri" xml:"uri" form:"uri"`
+}
+
+type totpRecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes" xml:"recovery_codes" form:"recovery_codes"`
+}
+
+// loginMFA exchanges a MFA token and a TOTP or recovery code for an access token and a refresh token.
+func (a AuthHandler) loginMFA() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ml := new(mfaLogin)
+		if err := c.Bind(ml); err != nil {
+			return err
+		}
+
+		loginErrors := utils.ValidateStruct(*ml)
+		if loginErrors != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, loginErrors)
+		}
+
+		claims := new(entities.MFAClaims)
+		if _, err := jwt.ParseWithClaims(ml.MFAToken, claims, a.keySet.KeyFunc); err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid MFA token")
+		}
+
+		revoked, err := a.revocationStore.IsRevoked(claims.Id, claims.UserID, claims.IssueTime())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication")
+		}
+		if revoked {
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid MFA token")
+		}
+
+		user, err := a.userStore.WithContext(c.Request().Context()).GetUser(claims.UserID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication").SetInternal(err)
+		}
+		if user.ID == "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, nil)
+		}
+
+		blockedFor, err := a.throttleStore.Check(user.Username, c.RealIP())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication")
+		}
+		if blockedFor > 0 {
+			return tooManyAttempts(c, blockedFor)
+		}
+
+		factor, err := a.mfaStore.GetTOTP(user.ID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication")
+		}
+		ok, err := a.verifyCode(factor, ml.Code)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication")
+		}
+		if !ok {
+			return a.loginFailed(c, user.Username)
+		}
+
+		// The MFA token can only be used once
+		if err := a.revocationStore.Revoke(claims.Id, claims.UserID, time.Unix(claims.ExpiresAt, 0)); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication")
+		}
+
+		return a.loginResponse(c, user)
+	}
+}
+
+// enrollTOTP generates a new TOTP secret for the current user.
+// The factor is enabled once confirmed with a valid code.
+func (a AuthHandler) enrollTOTP() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, err := entities.ClaimsFromToken(c.Get("user"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+
+		factor, err := a.mfaStore.GetTOTP(claims.UserID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during TOTP enrollment")
+		}
+		if factor.Enabled() {
+			return echo.NewHTTPError(http.StatusConflict, "TOTP already enabled")
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during TOTP enrollment")
+		}
+		if err := a.mfaStore.CreateTOTP(claims.UserID, secret); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during TOTP enrollment")
+		}
+
+		return c.JSON(http.StatusOK, totpEnrollment{
+			Secret: secret,
+			URI:    totp.ProvisioningURI(mfaIssuer(), claims.Username, secret),
+		})
+	}
+}
+
+// confirmTOTP enables the TOTP factor of the current user and returns the recovery codes.
+func (a AuthHandler) confirmTOTP() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, err := entities.ClaimsFromToken(c.Get("user"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+
+		form := new(entities.TOTPCodeForm)
+		if err := c.Bind(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bad data")
+		}
+		formErrors := utils.ValidateStruct(*form)
+		if formErrors != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, formErrors)
+		}
+
+		factor, err := a.mfaStore.GetTOTP(claims.UserID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during TOTP confirmation")
+		}
+		if factor.UserID == "" {
+			return echo.NewHTTPError(http.StatusNotFound, "No TOTP enrollment found")
+		}
+		if factor.Enabled() {
+			return echo.NewHTTPError(http.StatusConflict, "TOTP already enabled")
+		}
+
+		step, ok := totp.Validate(factor.Secret, form.Code, time.Now(), totpSkew)
+		if !ok {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid code")
+		}
+
+		codes, err := a.mfaStore.ConfirmTOTP(claims.UserID, step)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during TOTP confirmation")
+		}
+
+		return c.JSON(http.StatusOK, totpRecoveryCodes{RecoveryCodes: codes})
+	}
+}
+
+// disableTOTP removes the TOTP factor of the current user.
+// A valid TOTP or recovery code is required.
+func (a AuthHandler) disableTOTP() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, err := entities.ClaimsFromToken(c.Get("user"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+
+		form := new(entities.TOTPCodeForm)
+		if err := c.Bind(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bad data")
+		}
+		formErrors := utils.ValidateStruct(*form)
+		if formErrors != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, formErrors)
+		}
+
+		factor, err := a.mfaStore.GetTOTP(claims.UserID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when disabling TOTP")
+		}
+		if !factor.Enabled() {
+			return echo.NewHTTPError(http.StatusNotFound, "TOTP not enabled")
+		}
+
+		ok, err := a.verifyCode(factor, form.Code)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when disabling TOTP")
+		}
+		if !ok {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid code")
+		}
+
+		if err := a.mfaStore.DisableTOTP(claims.UserID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when disabling TOTP")
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// verifyCode checks a TOTP code or, if the code is not a 6 digits code, a recovery code.
+// Each code can only be used once.
+func (a AuthHandler) verifyCode(factor entities.TOTPFactor, code string) (bool, error) {
+	if !factor.Enabled() {
+		return false, nil
+	}
+
+	if totpCodeRegexp.MatchString(code) {
+		step, ok := totp.Validate(factor.Secret, code, time.Now(), totpSkew)
+		if !ok {
+			return false, nil
+		}
+		return a.mfaStore.UseTOTPStep(factor.UserID, step)
+	}
+	return a.mfaStore.UseRecoveryCode(factor.UserID, code)
+}
+
+// mfaIssuer returns the issuer displayed in authenticator applications.
+func mfaIssuer() string {
+	if issuer := viper.GetString("MFA_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return viper.GetString("APP_NAME")
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/utils"
+	"github.com/fabienbellanger/echo-boilerplate/utils/oidc"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// oidcStateCookie is the name of the cookie storing the login state between the redirection and the callback.
+const oidcStateCookie = "oidc_state"
+
+// oidcProvider returns the provider of the request.
+func (a AuthHandler) oidcProvider(c echo.Context) (*oidc.Provider, error) {
+	provider, ok := a.oidcProviders[c.Param("provider")]
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Unknown identity provider")
+	}
+	return provider, nil
+}
+
+// oidcLogin redirects the user to the identity provider.
+// State, nonce and PKCE verifier are kept in a signed cookie until the callback.
+func (a AuthHandler) oidcLogin() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		provider, err := a.oidcProvider(c)
+		if err != nil {
+			return err
+		}
+
+		state, err := utils.GenerateRandomToken(24)
+		if err != nil {
+			return err
+		}
+		nonce, err := utils.GenerateRandomToken(24)
+		if err != nil {
+			return err
+		}
+		verifier, err := oidc.NewVerifier()
+		if err != nil {
+			return err
+		}
+
+		authURL, err := provider.AuthCodeURL(state, nonce, verifier)
+		if err != nil {
+			a.logger.Error("OIDC discovery failed", zap.String("provider", provider.Name()), zap.Error(err))
+			return echo.NewHTTPError(http.StatusBadGateway, "Identity provider unavailable")
+		}
+
+		lifetime := viper.GetInt("OIDC_STATE_LIFETIME")
+		token, err := a.keySet.Sign(entities.NewOIDCStateClaims(provider.Name(), state, nonce, verifier, lifetime))
+		if err != nil {
+			return err
+		}
+
+		c.SetCookie(&http.Cookie{
+			Name:     oidcStateCookie,
+			Value:    token,
+			Path:     c.Echo().Reverse("oidcCallback", provider.Name()),
+			MaxAge:   lifetime * 60,
+			Secure:   c.Scheme() == "https",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		return c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// oidcCallback authenticates the user returned by the identity provider
+// and issues the same tokens as the password login.
+func (a AuthHandler) oidcCallback() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		provider, err := a.oidcProvider(c)
+		if err != nil {
+			return err
+		}
+
+		cookie, err := c.Cookie(oidcStateCookie)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Missing OIDC state")
+		}
+		c.SetCookie(&http.Cookie{
+			Name:     oidcStateCookie,
+			Path:     c.Request().URL.Path,
+			MaxAge:   -1,
+			HttpOnly: true,
+		})
+
+		state := new(entities.OIDCStateClaims)
+		if _, err := jwt.ParseWithClaims(cookie.Value, state, a.keySet.KeyFunc); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid OIDC state")
+		}
+		if state.Provider != provider.Name() ||
+			subtle.ConstantTimeCompare([]byte(state.State), []byte(c.QueryParam("state"))) != 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid OIDC state")
+		}
+
+		if e := c.QueryParam("error"); e != "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Identity provider error: "+e)
+		}
+
+		claims, err := provider.Exchange(c.QueryParam("code"), state.Verifier, state.Nonce)
+		if err != nil {
+			a.logger.Warn("OIDC login failed",
+				zap.String("provider", provider.Name()),
+				zap.String("ip", c.RealIP()),
+				zap.Error(err),
+			)
+			return echo.NewHTTPError(http.StatusUnauthorized, nil)
+		}
+
+		user, err := a.oidcUser(c.Request().Context(), provider.Name(), claims)
+		if err != nil {
+			return err
+		}
+
+		blockedFor, err := a.throttleStore.Check(user.Username, c.RealIP())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication")
+		}
+		if blockedFor > 0 {
+			return tooManyAttempts(c, blockedFor)
+		}
+
+		return a.completeLogin(c, user)
+	}
+}
+
+// oidcUser returns the user linked to an external identity.
+// Unlinked identities are linked to the user with the same verified email address,
+// or to a new user if just-in-time provisioning is enabled.
+func (a AuthHandler) oidcUser(ctx context.Context, provider string, claims *oidc.IDTokenClaims) (entities.User, error) {
+	userID, err := a.identityStore.GetUserID(provider, claims.Subject)
+	if err != nil {
+		return entities.User{}, echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication")
+	}
+	if userID != "" {
+		user, err := a.userStore.WithContext(ctx).GetUser(userID)
+		if err != nil {
+			return user, echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication").SetInternal(err)
+		}
+		if user.ID == "" {
+			return user, echo.NewHTTPError(http.StatusUnauthorized, nil)
+		}
+		return user, nil
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return entities.User{}, echo.NewHTTPError(http.StatusForbidden, "A verified email address is required")
+	}
+
+	// Link an existing user
+	// ---------------------
+	user, err := a.userStore.WithContext(ctx).GetUserByUsername(claims.Email)
+	if err != nil {
+		return user, echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication").SetInternal(err)
+	}
+	if user.ID != "" {
+		if !viper.GetBool("OIDC_LINK_BY_EMAIL") {
+			return user, echo.NewHTTPError(http.StatusForbidden, "No account linked to this identity")
+		}
+		if user.VerifiedAt == nil {
+			if err := a.userStore.WithContext(ctx).VerifyUser(user.ID); err != nil {
+				return user, echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication").SetInternal(err)
+			}
+		}
+	} else {
+		// Just-in-time provisioning
+		// -------------------------
+		if !viper.GetBool("OIDC_AUTO_PROVISION") {
+			return user, echo.NewHTTPError(http.StatusForbidden, "No account linked to this identity")
+		}
+
+		user, err = a.provisionUser(ctx, claims)
+		if err != nil {
+			return user, echo.NewHTTPError(http.StatusInternalServerError, "Error during user creation").SetInternal(err)
+		}
+	}
+
+	if err := a.identityStore.Link(user.ID, provider, claims.Subject, claims.Email); err != nil {
+		return user, echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication")
+	}
+	a.logger.Info("External identity linked",
+		zap.String("provider", provider),
+		zap.String("subject", claims.Subject),
+		zap.String("userId", user.ID),
+	)
+
+	return user, nil
+}
+
+// provisionUser creates a user from the ID token claims.
+// The random password is never returned, the user can set one with the password reset.
+func (a AuthHandler) provisionUser(ctx context.Context, claims *oidc.IDTokenClaims) (entities.User, error) {
+	password, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return entities.User{}, err
+	}
+
+	now := time.Now()
+	user := entities.User{
+		Username:   claims.Email,
+		Password:   password,
+		Lastname:   claims.FamilyName,
+		Firstname:  claims.GivenName,
+		VerifiedAt: &now,
+	}
+	if user.Lastname == "" && user.Firstname == "" {
+		user.Lastname = claims.Name
+	}
+
+	if err := a.userStore.WithContext(ctx).Register(&user); err != nil {
+		return entities.User{}, err
+	}
+
+	if roles := strings.Fields(viper.GetString("OIDC_DEFAULT_ROLES")); len(roles) > 0 {
+		if err := a.roleStore.AssignRoles(user.ID, roles); err != nil {
+			return user, err
+		}
+	}
+
+	return user, nil
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/fabienbellanger/echo-boilerplate/utils"
+	"github.com/fabienbellanger/echo-boilerplate/utils/mailer"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// forgotPassword sends a password reset link to the user.
+// The response is the same whether the user exists or not, to prevent username enumeration.
+// Requests are throttled per username and per IP address, existing user or not.
+func (a AuthHandler) forgotPassword() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		form := new(entities.PasswordForgotForm)
+		if err := c.Bind(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bad data")
+		}
+
+		formErrors := utils.ValidateStruct(*form)
+		if formErrors != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, formErrors)
+		}
+
+		// Email throttling
+		// ----------------
+		blockedFor, err := a.emailThrottleStore.Check(form.Username, c.RealIP())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during password reset")
+		}
+		if blockedFor > 0 {
+			return tooManyRequests(c, blockedFor)
+		}
+		if _, err := a.emailThrottleStore.RegisterFailure(form.Username, c.RealIP()); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during password reset")
+		}
+
+		user, err := a.userStore.WithContext(c.Request().Context()).GetUserByUsername(form.Username)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during password reset").SetInternal(err)
+		}
+		if user.ID == "" {
+			return c.NoContent(http.StatusAccepted)
+		}
+
+		lifetime := time.Duration(viper.GetInt("PASSWORD_RESET_LIFETIME")) * time.Minute
+		token, err := a.oneTimeTokenStore.Create(user.ID, entities.TokenPurposePasswordReset, lifetime)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during password reset")
+		}
+
+		msg := mailer.Message{
+			To:      []string{user.Username},
+			Subject: "Password reset",
+			Body: fmt.Sprintf("Hello %s,\n\nTo reset your password, follow this link:\n%s\n\nThis link expires in %s.\nIf you did not request a password reset, ignore this email.\n",
+				user.Firstname,
+				strings.ReplaceAll(viper.GetString("PASSWORD_RESET_URL"), "{token}", token),
+				lifetime),
+		}
+		mailer.SendAsync(a.mailer, msg, a.logger)
+
+		return c.NoContent(http.StatusAccepted)
+	}
+}
+
+// resetPassword replaces the password of the user with a valid reset token.
+// All the user tokens are revoked and the account is unlocked.
+func (a AuthHandler) resetPassword() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		form := new(entities.PasswordResetForm)
+		if err := c.Bind(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bad data")
+		}
+
+		formErrors := utils.ValidateStruct(*form)
+		if formErrors != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, formErrors)
+		}
+
+		// The token is not used while the new password is checked against the policy
+		userID, err := a.oneTimeTokenStore.Peek(form.Token, entities.TokenPurposePasswordReset)
+		if err != nil {
+			if errors.Is(err, store.ErrOneTimeTokenInvalid) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during password reset")
+		}
+
+		user, err := a.userStore.WithContext(c.Request().Context()).GetUser(userID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during password reset").SetInternal(err)
+		}
+		if user.ID == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, store.ErrOneTimeTokenInvalid.Error())
+		}
+
+		policyErrors, err := a.passwordPolicy.Validate(form.Password, user.Username)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during password reset")
+		}
+		if policyErrors != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, policyErrors)
+		}
+
+		// The token is consumed before the password is changed, so that it cannot be used twice concurrently.
+		// It is released if the password cannot be changed.
+		if _, err := a.oneTimeTokenStore.Consume(form.Token, entities.TokenPurposePasswordReset); err != nil {
+			if errors.Is(err, store.ErrOneTimeTokenInvalid) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during password reset")
+		}
+		if err := a.userStore.WithContext(c.Request().Context()).WithAudit(auditMeta(c, user.ID)).UpdatePassword(user.ID, form.Password); err != nil {
+			if releaseErr := a.oneTimeTokenStore.Release(form.Token, entities.TokenPurposePasswordReset); releaseErr != nil {
+				a.logger.Error("Error when releasing password reset token", zap.Error(releaseErr), zap.String("userId", user.ID))
+			}
+			if errors.Is(err, store.ErrPasswordReused) {
+				return echo.NewHTTPError(http.StatusBadRequest, a.passwordPolicy.ReusedError())
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during password reset").SetInternal(err)
+		}
+		if err := a.revocationStore.RevokeAllForUser(user.ID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when revoking user tokens")
+		}
+		if err := a.throttleStore.Unlock(user.Username); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when unlocking user")
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
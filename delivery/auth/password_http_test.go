@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	storeUser "github.com/fabienbellanger/echo-boilerplate/store/user"
+	"github.com/fabienbellanger/echo-boilerplate/utils/mailer"
+	"github.com/fabienbellanger/echo-boilerplate/utils/password"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// throttleStoreStub is a store.LoginThrottleStorer blocking a username or an IP address after limit attempts.
+type throttleStoreStub struct {
+	limit    int
+	attempts map[string]int
+}
+
+func (s *throttleStoreStub) Check(username, ip string) (time.Duration, error) {
+	if s.attempts[entities.LoginThrottleUsernameKey(username)] >= s.limit || s.attempts[entities.LoginThrottleIPKey(ip)] >= s.limit {
+		return time.Minute, nil
+	}
+	return 0, nil
+}
+func (s *throttleStoreStub) RegisterFailure(username, ip string) (entities.LoginFailure, error) {
+	s.attempts[entities.LoginThrottleUsernameKey(username)]++
+	s.attempts[entities.LoginThrottleIPKey(ip)]++
+	return entities.LoginFailure{}, nil
+}
+func (s *throttleStoreStub) RegisterSuccess(username string) error { return nil }
+func (s *throttleStoreStub) Unlock(username string) error          { return nil }
+
+// oneTimeTokenStoreStub is a store.OneTimeTokenStorer counting the created tokens.
+type oneTimeTokenStoreStub struct {
+	created int
+}
+
+func (s *oneTimeTokenStoreStub) Create(userID, purpose string, lifetime time.Duration) (string, error) {
+	s.created++
+	return "token", nil
+}
+func (s *oneTimeTokenStoreStub) Peek(token, purpose string) (string, error)    { return "", nil }
+func (s *oneTimeTokenStoreStub) Consume(token, purpose string) (string, error) { return "", nil }
+func (s *oneTimeTokenStoreStub) Release(token, purpose string) error           { return nil }
+
+// mailerStub is a mailer.Mailer sending nothing.
+type mailerStub struct{}
+
+func (mailerStub) Send(msg mailer.Message) error { return nil }
+
+func TestForgotPasswordThrottle(t *testing.T) {
+	users := storeUser.NewMemory(password.NewBcrypt(4), storeUser.Options{})
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	assert.Nil(t, users.WithContext(context.Background()).Register(&user))
+	tokens := &oneTimeTokenStoreStub{}
+	h := New(nil, Dependencies{
+		UserStore:          users,
+		EmailThrottleStore: &throttleStoreStub{limit: 2, attempts: make(map[string]int)},
+		OneTimeTokenStore:  tokens,
+		Mailer:             mailerStub{},
+		Logger:             zap.NewNop(),
+	})
+	e := echo.New()
+	forgot := func(username, ip string) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"username": "`+username+`"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderXRealIP, ip)
+		rec := httptest.NewRecorder()
+		return rec, h.forgotPassword()(e.NewContext(req, rec))
+	}
+
+	// Per username, existing or not
+	for username, ip := range map[string]string{"john@test.com": "10.0.0.1", "unknown@test.com": "10.0.0.2"} {
+		for i := 0; i < 2; i++ {
+			rec, err := forgot(username, ip)
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+		}
+		rec, err := forgot(username, "10.0.0.3")
+		assert.Equal(t, http.StatusTooManyRequests, err.(*echo.HTTPError).Code, username)
+		assert.Equal(t, "60", rec.Header().Get(echo.HeaderRetryAfter))
+	}
+	assert.Equal(t, 2, tokens.created, "only existing users receive an email")
+
+	// Per IP address
+	_, err := forgot("jane@test.com", "10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, err.(*echo.HTTPError).Code)
+	assert.Equal(t, 2, tokens.created)
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// sessionUserAgentMaxLength is the size of the user agent column.
+const sessionUserAgentMaxLength = 255
+
+// getSessions lists the active sessions of the user
+func (a AuthHandler) getSessions() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, err := entities.ClaimsFromToken(c.Get("user"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+
+		sessions, err := a.sessionStore.GetUserSessions(claims.UserID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when retrieving sessions")
+		}
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == claims.SessionID
+		}
+
+		return c.JSON(http.StatusOK, sessions)
+	}
+}
+
+// terminateSession terminates a session of the user.
+// The access tokens of the session are rejected and its refresh tokens are revoked.
+func (a AuthHandler) terminateSession() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, err := entities.ClaimsFromToken(c.Get("user"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+
+		id := c.Param("id")
+		if id == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bad ID")
+		}
+
+		if err := a.sessionStore.Terminate(claims.UserID, id); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "No session found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when terminating session")
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// truncate truncates a string to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// loginFailed records a failed login attempt and logs the lockouts.
+func (a AuthHandler) loginFailed(c echo.Context, username string) error {
+	ip := c.RealIP()
+
+	failure, err := a.throttleStore.RegisterFailure(username, ip)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication")
+	}
+
+	fields := []zap.Field{
+		zap.String("username", username),
+		zap.String("ip", ip),
+		zap.Int("failures", failure.Failures),
+		zap.String("requestId", c.Response().Header().Get(echo.HeaderXRequestID)),
+	}
+	a.logger.Info("Login failed", fields...)
+
+	if failure.Locked {
+		a.logger.Warn("Account locked", append(fields, zap.Duration("duration", failure.BlockedFor))...)
+	}
+	if failure.IPBlockedFor > 0 {
+		a.logger.Warn("IP address throttled", append(fields, zap.Duration("duration", failure.IPBlockedFor))...)
+	}
+
+	return echo.NewHTTPError(http.StatusUnauthorized, nil)
+}
+
+// unlock removes the lockout of a user account.
+func (a AuthHandler) unlock() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Param("id")
+		if id == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bad ID")
+		}
+
+		claims, err := entities.ClaimsFromToken(c.Get("user"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+
+		user, err := a.userStore.WithContext(c.Request().Context()).GetUser(id)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when retrieving user").SetInternal(err)
+		}
+		if user.ID == "" {
+			return echo.NewHTTPError(http.StatusNotFound, "No user found")
+		}
+
+		if err := a.throttleStore.Unlock(user.Username); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when unlocking user")
+		}
+
+		a.logger.Warn("Account unlocked",
+			zap.String("username", user.Username),
+			zap.String("actorId", claims.UserID),
+			zap.String("ip", c.RealIP()),
+		)
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// tooManyAttempts returns a 429 error with a Retry-After header.
+func tooManyAttempts(c echo.Context, blockedFor time.Duration) error {
+	c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(blockedFor.Seconds()))))
+	return echo.NewHTTPError(http.StatusTooManyRequests, "Too many failed login attempts")
+}
+
+// tooManyRequests returns a 429 error with a Retry-After header, for throttled email requests.
+func tooManyRequests(c echo.Context, blockedFor time.Duration) error {
+	c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(blockedFor.Seconds()))))
+	return echo.NewHTTPError(http.StatusTooManyRequests, "Too many requests, retry later")
+}
@@ -0,0 +1,116 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+)
+
+// APIKeyHeader is the header carrying an API key.
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyAuthScheme is the Authorization scheme carrying an API key.
+const apiKeyAuthScheme = "ApiKey"
+
+// APIKeyFromRequest returns the API key sent in the X-API-Key header or with the ApiKey authorization scheme.
+func APIKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+
+	auth := r.Header.Get(echo.HeaderAuthorization)
+	l := len(apiKeyAuthScheme)
+	if len(auth) > l+1 && strings.EqualFold(auth[:l], apiKeyAuthScheme) && auth[l] == ' ' {
+		return strings.TrimSpace(auth[l+1:])
+	}
+	return ""
+}
+
+// APIKeyAuth returns a middleware which authenticates requests carrying an API key.
+// Claims are built from the key owner and stored in the context like the JWT middleware does,
+// with the scopes of the key still granted to the user as permissions.
+// Requests without API key are passed to the next handler untouched.
+func APIKeyAuth(apiKeyStore store.APIKeyStorer, userStore store.UserStorer, roleStore store.RoleStorer) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := APIKeyFromRequest(c.Request())
+			if key == "" {
+				return next(c)
+			}
+
+			apiKey, err := apiKeyStore.Authenticate(key)
+			if err != nil {
+				if errors.Is(err, store.ErrAPIKeyInvalid) {
+					return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+				}
+				return echo.NewHTTPError(http.StatusInternalServerError, "Error when checking API key")
+			}
+
+			user, err := userStore.WithContext(c.Request().Context()).GetUser(apiKey.UserID)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Error when checking API key").SetInternal(err)
+			}
+			if user.ID == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, store.ErrAPIKeyInvalid.Error())
+			}
+
+			roles, permissions, err := roleStore.GetUserRolesAndPermissions(user.ID)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Error when checking API key")
+			}
+
+			claims := &entities.Claims{
+				UserID:      user.ID,
+				Username:    user.Username,
+				Lastname:    user.Lastname,
+				Firstname:   user.Firstname,
+				Roles:       roles,
+				Permissions: grantedScopes(apiKey.Scopes, permissions),
+				APIKeyID:    apiKey.ID,
+			}
+			c.Set("user", &jwt.Token{Claims: claims, Valid: true})
+
+			return next(c)
+		}
+	}
+}
+
+// IsAPIKeyRequest returns true if the request has been authenticated with an API key.
+// It can be used as a skipper of the JWT middlewares.
+func IsAPIKeyRequest(c echo.Context) bool {
+	claims, err := entities.ClaimsFromToken(c.Get("user"))
+	return err == nil && claims.APIKeyID != ""
+}
+
+// RejectAPIKey returns a middleware which rejects requests authenticated with an API key.
+// It protects the routes which need a user token, like logout, sessions and MFA management.
+func RejectAPIKey() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if IsAPIKeyRequest(c) {
+				return echo.NewHTTPError(http.StatusForbidden, "Not allowed with an API key")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// grantedScopes returns the scopes which are still in the user permissions.
+func grantedScopes(scopes, permissions []string) []string {
+	granted := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		for _, permission := range permissions {
+			if scope == permission {
+				granted = append(granted, scope)
+				break
+			}
+		}
+	}
+	return granted
+}
@@ -0,0 +1,114 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	storeUser "github.com/fabienbellanger/echo-boilerplate/store/user"
+	"github.com/fabienbellanger/echo-boilerplate/utils/password"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyFromRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Equal(t, "", APIKeyFromRequest(r))
+
+	r.Header.Set(echo.HeaderAuthorization, "Bearer my.jwt.token")
+	assert.Equal(t, "", APIKeyFromRequest(r))
+
+	r.Header.Set(echo.HeaderAuthorization, "ApiKey ebk_secret")
+	assert.Equal(t, "ebk_secret", APIKeyFromRequest(r))
+
+	r.Header.Set(APIKeyHeader, "ebk_header")
+	assert.Equal(t, "ebk_header", APIKeyFromRequest(r))
+}
+
+func TestGrantedScopes(t *testing.T) {
+	assert.Equal(t, []string{"users:read"}, grantedScopes([]string{"users:read", "users:delete"}, []string{"users:read", "roles:read"}))
+	assert.Equal(t, []string{}, grantedScopes([]string{"users:delete"}, nil))
+}
+
+// apiKeyStoreStub is a store.APIKeyStorer authenticating a single key.
+type apiKeyStoreStub struct {
+	apiKey entities.APIKey
+}
+
+func (s apiKeyStoreStub) Create(userID, name string, scopes []string, expiresAt *time.Time) (entities.APIKey, error) {
+	return entities.APIKey{}, nil
+}
+func (s apiKeyStoreStub) GetUserAPIKeys(userID string) ([]entities.APIKey, error) { return nil, nil }
+func (s apiKeyStoreStub) Revoke(userID, id string) error                          { return nil }
+func (s apiKeyStoreStub) Authenticate(key string) (entities.APIKey, error) {
+	if key != s.apiKey.Key {
+		return entities.APIKey{}, store.ErrAPIKeyInvalid
+	}
+	return s.apiKey, nil
+}
+
+// roleStoreStub is a store.RoleStorer returning the same permissions for all users.
+type roleStoreStub struct {
+	permissions []string
+}
+
+func (s roleStoreStub) InitDefaultRoles() error                             { return nil }
+func (s roleStoreStub) GetAllRoles() ([]entities.Role, error)               { return nil, nil }
+func (s roleStoreStub) GetUserRoles(userID string) ([]entities.Role, error) { return nil, nil }
+func (s roleStoreStub) AssignRoles(userID string, roleNames []string) error { return nil }
+func (s roleStoreStub) GetUserRolesAndPermissions(userID string) ([]string, []string, error) {
+	return []string{entities.RoleUser}, s.permissions, nil
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	users := storeUser.NewMemory(password.NewBcrypt(4), storeUser.Options{})
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	assert.Nil(t, users.WithContext(context.Background()).Register(&user))
+
+	apiKeys := apiKeyStoreStub{apiKey: entities.APIKey{
+		ID:     "key-id",
+		UserID: user.ID,
+		Key:    "ebk_secret",
+		Scopes: []string{entities.PermissionUsersRead, entities.PermissionUsersDelete},
+	}}
+	roles := roleStoreStub{permissions: []string{entities.PermissionUsersRead, entities.PermissionRolesRead}}
+
+	e := echo.New()
+	e.Use(APIKeyAuth(apiKeys, users, roles))
+	var claims *entities.Claims
+	e.GET("/", func(c echo.Context) error {
+		claims, _ = entities.ClaimsFromToken(c.Get("user"))
+		return c.NoContent(http.StatusOK)
+	})
+	request := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("ebk_secret"))
+	if assert.NotNil(t, claims) {
+		assert.Equal(t, user.ID, claims.UserID)
+		assert.Equal(t, "key-id", claims.APIKeyID)
+		assert.Equal(t, []string{entities.PermissionUsersRead}, claims.Permissions, "scopes are limited to the user permissions")
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request("ebk_unknown"))
+
+	// Requests without API key are passed untouched
+	claims = nil
+	assert.Equal(t, http.StatusOK, request(""))
+	assert.Nil(t, claims)
+
+	// Keys of deleted users are rejected
+	assert.Nil(t, users.WithContext(context.Background()).DeleteUser(user.ID))
+	assert.Equal(t, http.StatusUnauthorized, request("ebk_secret"))
+}
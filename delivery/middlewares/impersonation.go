@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/labstack/echo/v4"
+)
+
+// RejectImpersonation returns a middleware which rejects requests made with an impersonation token.
+// It protects the routes managing the credentials and sessions of the user.
+// It must be used after the JWT middleware.
+func RejectImpersonation() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, err := entities.ClaimsFromToken(c.Get("user"))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+
+			if claims.IsImpersonation() {
+				return echo.NewHTTPError(http.StatusForbidden, "Not allowed while impersonating")
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRejectImpersonation(t *testing.T) {
+	e := echo.New()
+	handler := RejectImpersonation()(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	newContext := func(actor *entities.Actor) echo.Context {
+		c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+		c.Set("user", &jwt.Token{Claims: &entities.Claims{UserID: "user-id", Actor: actor}})
+		return c
+	}
+
+	err := handler(newContext(nil))
+	assert.Nil(t, err)
+
+	err = handler(newContext(&entities.Actor{UserID: "admin-id"}))
+	assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+	err = handler(c)
+	assert.Equal(t, http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+}
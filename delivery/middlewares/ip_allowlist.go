@@ -0,0 +1,70 @@
+package middlewares
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// IPAllowlist returns a middleware which only accepts requests from the listed IP addresses or CIDR ranges.
+// An empty list accepts every request.
+// The client IP is read with RealIP, so proxy headers must only be trusted behind a reverse proxy.
+func IPAllowlist(allowed []string, logger *zap.Logger) (echo.MiddlewareFunc, error) {
+	networks, err := parseNetworks(allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if len(networks) == 0 {
+				return next(c)
+			}
+
+			ip := net.ParseIP(c.RealIP())
+			for _, n := range networks {
+				if ip != nil && n.Contains(ip) {
+					return next(c)
+				}
+			}
+
+			logger.Warn("IP address not allowed",
+				zap.String("ip", c.RealIP()),
+				zap.String("path", c.Request().URL.Path),
+				zap.String("requestId", c.Response().Header().Get(echo.HeaderXRequestID)),
+			)
+			return echo.NewHTTPError(http.StatusForbidden, nil)
+		}
+	}, nil
+}
+
+// parseNetworks parses IP addresses and CIDR ranges.
+func parseNetworks(list []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", s)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %q", s)
+		}
+		networks = append(networks, n)
+	}
+	return networks, nil
+}
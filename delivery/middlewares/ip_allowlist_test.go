@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestIPAllowlist(t *testing.T) {
+	e := echo.New()
+	mw, err := IPAllowlist([]string{"127.0.0.1", "::1", "10.0.0.0/8"}, zap.NewNop())
+	assert.Nil(t, err)
+	handler := mw(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	request := func(remoteAddr string) error {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		return handler(e.NewContext(req, httptest.NewRecorder()))
+	}
+
+	assert.Nil(t, request("127.0.0.1:1234"))
+	assert.Nil(t, request("[::1]:1234"))
+	assert.Nil(t, request("10.1.2.3:1234"))
+	assert.Equal(t, http.StatusForbidden, request("192.168.1.1:1234").(*echo.HTTPError).Code)
+
+	_, err = IPAllowlist([]string{"localhost"}, zap.NewNop())
+	assert.NotNil(t, err)
+}
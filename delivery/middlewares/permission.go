@@ -0,0 +1,27 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/labstack/echo/v4"
+)
+
+// RequirePermission returns a middleware which rejects requests whose JWT does not carry the permission.
+// It must be used after the JWT middleware.
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, err := entities.ClaimsFromToken(c.Get("user"))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+
+			if !claims.HasPermission(permission) {
+				return echo.NewHTTPError(http.StatusForbidden, "Missing permission "+permission)
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequirePermission(t *testing.T) {
+	e := echo.New()
+	handler := RequirePermission(entities.PermissionUsersDelete)(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	newContext := func(permissions ...string) echo.Context {
+		c := e.NewContext(httptest.NewRequest(http.MethodDelete, "/", nil), httptest.NewRecorder())
+		c.Set("user", &jwt.Token{Claims: &entities.Claims{Permissions: permissions}})
+		return c
+	}
+
+	err := handler(newContext(entities.PermissionUsersRead, entities.PermissionUsersDelete))
+	assert.Nil(t, err)
+
+	err = handler(newContext(entities.PermissionUsersRead))
+	assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+
+	c := e.NewContext(httptest.NewRequest(http.MethodDelete, "/", nil), httptest.NewRecorder())
+	err = handler(c)
+	assert.Equal(t, http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+}
@@ -0,0 +1,161 @@
+package role
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/fabienbellanger/echo-boilerplate/delivery/middlewares"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/fabienbellanger/echo-boilerplate/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type RoleHandler struct {
+	group           *echo.Group
+	store           store.RoleStorer
+	userStore       store.UserStorer
+	revocationStore store.TokenRevocationStorer
+	auditStore      store.AuditStorer
+}
+
+// New returns a new RoleHandler
+func New(g *echo.Group, role store.RoleStorer, user store.UserStorer, revocation store.TokenRevocationStorer, audit store.AuditStorer) RoleHandler {
+	return RoleHandler{
+		group:           g,
+		store:           role,
+		userStore:       user,
+		revocationStore: revocation,
+		auditStore:      audit,
+	}
+}
+
+// Routes adds roles routes
+func (r *RoleHandler) Routes() {
+	r.group.GET("", r.getAll(), middlewares.RequirePermission(entities.PermissionRolesRead))
+}
+
+// UserRoutes adds user roles routes to the users group
+func (r *RoleHandler) UserRoutes(g *echo.Group) {
+	g.GET("/:id/roles", r.getUserRoles(), middlewares.RequirePermission(entities.PermissionRolesRead))
+	g.PUT("/:id/roles", r.assign(), middlewares.RequirePermission(entities.PermissionRolesAssign))
+}
+
+// getAll lists all roles
+func (r RoleHandler) getAll() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		roles, err := r.store.GetAllRoles()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when retrieving roles")
+		}
+
+		return c.JSON(http.StatusOK, roles)
+	}
+}
+
+// getUserRoles lists the roles of a user
+func (r RoleHandler) getUserRoles() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Param("id")
+		if id == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bad ID")
+		}
+
+		roles, err := r.store.GetUserRoles(id)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when retrieving user roles")
+		}
+
+		return c.JSON(http.StatusOK, roles)
+	}
+}
+
+// assign replaces the roles of a user.
+// The tokens of the user are revoked, so that the new permissions apply at the next login.
+// The change is recorded in the audit trail.
+func (r RoleHandler) assign() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Param("id")
+		if id == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bad ID")
+		}
+
+		form := new(entities.UserRolesForm)
+		if err := c.Bind(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bad data")
+		}
+
+		assignErrors := utils.ValidateStruct(*form)
+		if assignErrors != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, assignErrors)
+		}
+
+		user, err := r.userStore.WithContext(c.Request().Context()).GetUser(id)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when retrieving user").SetInternal(err)
+		}
+		if user.ID == "" {
+			return echo.NewHTTPError(http.StatusNotFound, "No user found")
+		}
+
+		before, err := r.store.GetUserRoles(id)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when retrieving user roles")
+		}
+
+		if err := r.store.AssignRoles(id, form.Roles); err != nil {
+			if errors.Is(err, store.ErrUnknownRole) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when assigning roles")
+		}
+
+		if err := r.revocationStore.RevokeAllForUser(id); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when revoking user tokens")
+		}
+
+		roles, err := r.store.GetUserRoles(id)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when retrieving user roles")
+		}
+
+		changes := entities.NewAuditChanges(
+			map[string]interface{}{"roles": roleNames(before)},
+			map[string]interface{}{"roles": roleNames(roles)},
+		)
+		if len(changes) > 0 {
+			log := entities.NewAuditLog(auditMeta(c), entities.AuditActionAssignRoles, entities.AuditTargetUser, id, changes)
+			if err := r.auditStore.Create(log); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Error when assigning roles")
+			}
+		}
+
+		return c.JSON(http.StatusOK, roles)
+	}
+}
+
+// roleNames returns the names of the roles.
+func roleNames(roles []entities.Role) []string {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = role.Name
+	}
+	return names
+}
+
+// auditMeta returns the audit information of the request.
+// Under impersonation, the actor is the admin.
+func auditMeta(c echo.Context) entities.AuditMeta {
+	meta := entities.AuditMeta{
+		IP:        c.RealIP(),
+		RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+	}
+	if claims, err := entities.ClaimsFromToken(c.Get("user")); err == nil {
+		meta.ActorID = claims.UserID
+		if claims.IsImpersonation() {
+			meta.ActorID = claims.Actor.UserID
+			meta.OnBehalfOf = claims.UserID
+		}
+	}
+	return meta
+}
@@ -0,0 +1,120 @@
+package role
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	storeUser "github.com/fabienbellanger/echo-boilerplate/store/user"
+	"github.com/fabienbellanger/echo-boilerplate/utils/password"
+	"github.com/fabienbellanger/echo-boilerplate/utils/query"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// roleStoreStub is a store.RoleStorer knowing the default roles.
+type roleStoreStub struct {
+	assigned map[string][]string
+}
+
+func (s *roleStoreStub) InitDefaultRoles() error               { return nil }
+func (s *roleStoreStub) GetAllRoles() ([]entities.Role, error) { return nil, nil }
+func (s *roleStoreStub) GetUserRoles(userID string) ([]entities.Role, error) {
+	var roles []entities.Role
+	for _, name := range s.assigned[userID] {
+		roles = append(roles, entities.Role{Name: name})
+	}
+	return roles, nil
+}
+func (s *roleStoreStub) GetUserRolesAndPermissions(userID string) ([]string, []string, error) {
+	return nil, nil, nil
+}
+func (s *roleStoreStub) AssignRoles(userID string, roleNames []string) error {
+	for _, name := range roleNames {
+		if _, ok := entities.DefaultRoles[name]; !ok {
+			return store.ErrUnknownRole
+		}
+	}
+	s.assigned[userID] = roleNames
+	return nil
+}
+
+// revocationStoreStub is a store.TokenRevocationStorer recording the revoked users.
+type revocationStoreStub struct {
+	userIDs []string
+}
+
+func (s *revocationStoreStub) Revoke(jti, userID string, expiresAt time.Time) error { return nil }
+func (s *revocationStoreStub) RevokeAllForUser(userID string) error {
+	s.userIDs = append(s.userIDs, userID)
+	return nil
+}
+func (s *revocationStoreStub) IsRevoked(jti, userID string, issuedAt time.Time) (bool, error) {
+	return false, nil
+}
+
+// auditStoreStub is a store.AuditStorer recording the audit logs.
+type auditStoreStub struct {
+	logs []entities.AuditLog
+}
+
+func (s *auditStoreStub) Create(log entities.AuditLog) error {
+	s.logs = append(s.logs, log)
+	return nil
+}
+func (s *auditStoreStub) GetAuditLogs(filter entities.AuditFilter, params query.Params) ([]entities.AuditLog, int64, error) {
+	return s.logs, int64(len(s.logs)), nil
+}
+func (s *auditStoreStub) Export(filter entities.AuditFilter, fn func(entities.AuditLog) error) error {
+	return nil
+}
+
+func TestAssign(t *testing.T) {
+	users := storeUser.NewMemory(password.NewBcrypt(4), storeUser.Options{})
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	assert.Nil(t, users.WithContext(context.Background()).Register(&user))
+
+	roles := &roleStoreStub{assigned: make(map[string][]string)}
+	revocations := &revocationStoreStub{}
+	audits := &auditStoreStub{}
+	h := New(nil, roles, users, revocations, audits)
+
+	assign := func(id, body string) int {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		c.Set("user", &jwt.Token{Claims: entities.NewClaims("admin-id", "admin@test.com", "Admin", "Admin", 10), Valid: true})
+		if err := h.assign()(c); err != nil {
+			e.HTTPErrorHandler(err, c)
+		}
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, assign(user.ID, `{"roles": ["unknown"]}`))
+	assert.Empty(t, revocations.userIDs)
+
+	assert.Equal(t, http.StatusOK, assign(user.ID, `{"roles": ["admin"]}`))
+	assert.Equal(t, []string{entities.RoleAdmin}, roles.assigned[user.ID])
+	assert.Equal(t, []string{user.ID}, revocations.userIDs)
+	if assert.Len(t, audits.logs, 1) {
+		log := audits.logs[0]
+		assert.Equal(t, entities.AuditActionAssignRoles, log.Action)
+		assert.Equal(t, "admin-id", log.ActorID)
+		assert.Equal(t, user.ID, log.TargetID)
+		assert.Equal(t, entities.AuditChange{Before: []string{}, After: []string{entities.RoleAdmin}}, log.Changes["roles"])
+	}
+
+	// Unchanged roles are not audited
+	assert.Equal(t, http.StatusOK, assign(user.ID, `{"roles": ["admin"]}`))
+	assert.Len(t, audits.logs, 1)
+}
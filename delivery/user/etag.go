@@ -0,0 +1,62 @@
+package user
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/labstack/echo/v4"
+)
+
+// ETag headers
+const (
+	headerETag        = "ETag"
+	headerIfMatch     = "If-Match"
+	headerIfNoneMatch = "If-None-Match"
+)
+
+// userETag returns the entity tag of a user, which changes with its version.
+func userETag(user entities.User) string {
+	return `"` + strconv.FormatUint(uint64(user.Version), 10) + `"`
+}
+
+// matchETag checks if an If-Match or If-None-Match header value matches an entity tag.
+// Weak tags (W/"...") only match with the weak comparison, used by If-None-Match (RFC 9110).
+func matchETag(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[2:]
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch checks the If-Match header of a request changing the current user
+// and returns true if the header has been given.
+// 428 Precondition Required is returned if the header is required but missing
+// and 412 Precondition Failed if it does not match the ETag of the user.
+func (u UserHandler) checkIfMatch(c echo.Context, current entities.User) (bool, error) {
+	ifMatch := c.Request().Header.Get(headerIfMatch)
+	if ifMatch == "" {
+		if u.requireIfMatch {
+			return false, echo.NewHTTPError(http.StatusPreconditionRequired, "Missing If-Match header")
+		}
+		return false, nil
+	}
+	if current.ID == "" || !matchETag(ifMatch, userETag(current), false) {
+		return false, echo.NewHTTPError(http.StatusPreconditionFailed, store.ErrVersionConflict.Error())
+	}
+	return true, nil
+}
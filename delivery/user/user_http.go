@@ -3,83 +3,103 @@ package user
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
 	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/delivery/middlewares"
 	"github.com/fabienbellanger/echo-boilerplate/entities"
 	"github.com/fabienbellanger/echo-boilerplate/store"
 	"github.com/fabienbellanger/echo-boilerplate/utils"
+	"github.com/fabienbellanger/echo-boilerplate/utils/mailer"
+	"github.com/fabienbellanger/echo-boilerplate/utils/password"
+	"github.com/fabienbellanger/echo-boilerplate/utils/patch"
+	"github.com/fabienbellanger/echo-boilerplate/utils/query"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
-type userLogin struct {
-	entities.User
-	Token     string `json:"token" xml:"token" form:"token"`
-	ExpiresAt string `json:"expires_at" xml:"expires_at" form:"expires_at"`
+type usersPage struct {
+	Data       interface{} `json:"data" xml:"data" form:"data"`
+	Total      int64       `json:"total" xml:"total" form:"total"`
+	Page       int         `json:"page" xml:"page" form:"page"`
+	Limit      int         `json:"limit" xml:"limit" form:"limit"`
+	NextCursor string      `json:"next_cursor,omitempty" xml:"next_cursor,omitempty" form:"next_cursor"`
 }
 
-type userAuth struct {
-	Username string `json:"username" xml:"username" form:"username" validate:"required,email"`
-	Password string `json:"password" xml:"password" form:"password" validate:"required,min=8"`
+// deletedUser is a soft deleted user, with its deletion date.
+type deletedUser struct {
+	entities.User
+	DeletedAt time.Time `json:"deleted_at" xml:"deleted_at" form:"deleted_at"`
 }
 
 type UserHandler struct {
-	group *echo.Group
-	store store.UserStorer
+	group              *echo.Group
+	store              store.UserStorer
+	revocationStore    store.TokenRevocationStorer
+	oneTimeTokenStore  store.OneTimeTokenStorer
+	emailThrottleStore store.LoginThrottleStorer
+	passwordPolicy     password.Policy
+	mailer             mailer.Mailer
+	logger             *zap.Logger
+	requireIfMatch     bool
+}
+
+// Dependencies lists the stores and services used by UserHandler.
+type Dependencies struct {
+	UserStore          store.UserStorer
+	RevocationStore    store.TokenRevocationStorer
+	OneTimeTokenStore  store.OneTimeTokenStorer
+	EmailThrottleStore store.LoginThrottleStorer // Throttles the emails requested per username and IP address
+	PasswordPolicy     password.Policy
+	Mailer             mailer.Mailer
+	Logger             *zap.Logger
+	RequireIfMatch     bool // Updates and deletions without If-Match header are rejected
 }
 
 // New returns a new UserHandler
-func New(g *echo.Group, user store.UserStorer) UserHandler {
+func New(g *echo.Group, deps Dependencies) UserHandler {
 	return UserHandler{
-		group: g,
-		store: user,
+		group:              g,
+		store:              deps.UserStore,
+		revocationStore:    deps.RevocationStore,
+		oneTimeTokenStore:  deps.OneTimeTokenStore,
+		emailThrottleStore: deps.EmailThrottleStore,
+		passwordPolicy:     deps.PasswordPolicy,
+		mailer:             deps.Mailer,
+		logger:             deps.Logger,
+		requireIfMatch:     deps.RequireIfMatch,
 	}
 }
 
-// Routes adds users routes
-func (u *UserHandler) Routes() {
-	u.group.POST("", u.register())
-	u.group.GET("", u.getAll())
-	u.group.GET("/stream", u.stream())
-	u.group.GET("/:id", u.getOne())
-	u.group.PUT("/:id", u.update())
-	u.group.DELETE("/:id", u.delete())
+// PublicRoutes adds users routes which do not need authentication.
+// The handler group must not be protected.
+func (u *UserHandler) PublicRoutes() {
+	u.group.POST("/users/verify/resend", u.resendVerification())
 }
 
-// Login route
-func (u *UserHandler) Login(c echo.Context) error {
-	ua := new(userAuth)
-	if err := c.Bind(ua); err != nil {
-		return err
-	}
-
-	loginErrors := utils.ValidateStruct(*ua)
-	if loginErrors != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, loginErrors)
-	}
-
-	user, err := u.store.Login(ua.Username, ua.Password)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return echo.NewHTTPError(http.StatusUnauthorized, nil)
-		}
-		return echo.NewHTTPError(http.StatusInternalServerError, "Error during authentication")
-	}
-
-	claims := entities.NewClaims(user.ID, ua.Username, user.Lastname, user.Firstname, viper.GetInt("JWT_LIFETIME"))
-	token, err := claims.GenerateJWT(viper.GetString("JWT_ALGO"), viper.GetString("JWT_SECRET"))
-	if err != nil {
-		return err
-	}
-
-	return c.JSON(http.StatusOK, userLogin{
-		User:      user,
-		Token:     token,
-		ExpiresAt: time.Unix(claims.ExpiresAt, 0).Format("2006-01-02T15:04:05.000Z"),
-	})
+// Routes adds users routes
+func (u *UserHandler) Routes() {
+	u.group.POST("", u.register(), middlewares.RequirePermission(entities.PermissionUsersCreate))
+	u.group.GET("", u.getAll(), middlewares.RequirePermission(entities.PermissionUsersRead))
+	u.group.GET("/stream", u.stream(), middlewares.RequirePermission(entities.PermissionUsersRead))
+	u.group.GET("/deleted", u.getDeleted(), middlewares.RequirePermission(entities.PermissionUsersRestore))
+	u.group.GET("/:id", u.getOne(), middlewares.RequirePermission(entities.PermissionUsersRead))
+	u.group.PUT("/:id", u.update(), middlewares.RequirePermission(entities.PermissionUsersUpdate))
+	u.group.PATCH("/:id", u.patch(), middlewares.RequirePermission(entities.PermissionUsersUpdate))
+	u.group.DELETE("/:id", u.delete(), middlewares.RequirePermission(entities.PermissionUsersDelete))
+	u.group.POST("/:id/restore", u.restore(), middlewares.RequirePermission(entities.PermissionUsersRestore))
 }
 
 // register creates a new user
@@ -94,6 +114,14 @@ func (u UserHandler) register() echo.HandlerFunc {
 			return echo.NewHTTPError(http.StatusBadRequest, "Bad Parameters")
 		}
 
+		policyErrors, err := u.passwordPolicy.Validate(uf.Password, uf.Username)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when checking password")
+		}
+		if policyErrors != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, policyErrors)
+		}
+
 		user := entities.User{
 			Lastname:  uf.Lastname,
 			Firstname: uf.Firstname,
@@ -101,27 +129,217 @@ func (u UserHandler) register() echo.HandlerFunc {
 			Username:  uf.Username,
 		}
 
-		if err := u.store.Register(&user); err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "Error during user creation")
+		if err := u.store.WithContext(c.Request().Context()).WithAudit(auditMeta(c)).Register(&user); err != nil {
+			if errors.Is(err, store.ErrUsernameTaken) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error during user creation").SetInternal(err)
 		}
 
-		return c.JSON(http.StatusOK, user)
+		// The user is created, a new verification email can be requested
+		u.sendVerificationEmail(user)
+
+		return c.JSON(http.StatusCreated, user)
 	}
 }
 
-// getAll lists all users
+// auditMeta returns the audit information of the request.
+// Under impersonation, the actor is the admin.
+func auditMeta(c echo.Context) entities.AuditMeta {
+	meta := entities.AuditMeta{
+		IP:        c.RealIP(),
+		RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+	}
+	if claims, err := entities.ClaimsFromToken(c.Get("user")); err == nil {
+		meta.ActorID = claims.UserID
+		if claims.IsImpersonation() {
+			meta.ActorID = claims.Actor.UserID
+			meta.OnBehalfOf = claims.UserID
+		}
+	}
+	return meta
+}
+
+// sendVerificationEmail sends a link to verify the email address of a user.
+// Errors are logged: the user can request a new email.
+func (u UserHandler) sendVerificationEmail(user entities.User) {
+	lifetime := time.Duration(viper.GetInt("EMAIL_VERIFICATION_LIFETIME")) * time.Hour
+	token, err := u.oneTimeTokenStore.Create(user.ID, entities.TokenPurposeEmailVerification, lifetime)
+	if err != nil {
+		u.logger.Error("Error when creating email verification token", zap.Error(err), zap.String("userId", user.ID))
+		return
+	}
+
+	mailer.SendAsync(u.mailer, mailer.Message{
+		To:      []string{user.Username},
+		Subject: "Email address verification",
+		Body: fmt.Sprintf("Hello %s,\n\nTo verify your email address, follow this link:\n%s\n\nThis link expires in %s.\n",
+			user.Firstname,
+			strings.ReplaceAll(viper.GetString("EMAIL_VERIFICATION_URL"), "{token}", token),
+			lifetime),
+	}, u.logger)
+}
+
+// resendVerification sends a new verification email to a user whose email address is not verified.
+// The response is the same whether the user exists or not, to prevent username enumeration.
+// Requests are throttled per username and per IP address, existing user or not.
+func (u UserHandler) resendVerification() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		form := new(entities.VerificationResendForm)
+		if err := c.Bind(form); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bad data")
+		}
+
+		formErrors := utils.ValidateStruct(*form)
+		if formErrors != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, formErrors)
+		}
+
+		// Email throttling
+		// ----------------
+		blockedFor, err := u.emailThrottleStore.Check(form.Username, c.RealIP())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when sending verification email")
+		}
+		if blockedFor > 0 {
+			c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(blockedFor.Seconds()))))
+			return echo.NewHTTPError(http.StatusTooManyRequests, "Too many requests, retry later")
+		}
+		if _, err := u.emailThrottleStore.RegisterFailure(form.Username, c.RealIP()); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when sending verification email")
+		}
+
+		user, err := u.store.WithContext(c.Request().Context()).GetUserByUsername(form.Username)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when sending verification email").SetInternal(err)
+		}
+		if user.ID != "" && user.VerifiedAt == nil {
+			u.sendVerificationEmail(user)
+		}
+
+		return c.NoContent(http.StatusAccepted)
+	}
+}
+
+// usersQuery lists the pagination, sort and filter parameters of the users list.
+var usersQuery = query.Config{
+	Sortable:    []string{"username", "lastname", "firstname", "created_at", "updated_at"},
+	DefaultSort: []query.Sort{{Field: "lastname"}, {Field: "firstname"}},
+	Tiebreaker:  "id",
+	Filters: map[string]query.FilterType{
+		"username":       query.FilterString,
+		"lastname":       query.FilterString,
+		"firstname":      query.FilterString,
+		"created_after":  query.FilterTime,
+		"created_before": query.FilterTime,
+	},
+	DefaultLimit: db.MaxLimit,
+	MaxLimit:     db.MaxLimit,
+}
+
+// deletedUsersQuery lists the pagination, sort and filter parameters of the deleted users list.
+var deletedUsersQuery = query.Config{
+	Sortable:     append([]string{"deleted_at"}, usersQuery.Sortable...),
+	DefaultSort:  []query.Sort{{Field: "deleted_at", Desc: true}},
+	Tiebreaker:   usersQuery.Tiebreaker,
+	Filters:      usersQuery.Filters,
+	DefaultLimit: usersQuery.DefaultLimit,
+	MaxLimit:     usersQuery.MaxLimit,
+}
+
+// getAll lists users, by page or with a cursor.
+// The next page links are also sent in the Link header.
 func (u UserHandler) getAll() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		users, err := u.store.GetAllUsers()
+		users, page, err := u.list(c, usersQuery, u.store.WithContext(c.Request().Context()).GetUsers)
+		if err != nil {
+			return err
+		}
+		page.Data = users
+
+		return c.JSON(http.StatusOK, page)
+	}
+}
+
+// getDeleted lists soft deleted users, by page or with a cursor.
+// The next page links are also sent in the Link header.
+func (u UserHandler) getDeleted() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		users, page, err := u.list(c, deletedUsersQuery, u.store.WithContext(c.Request().Context()).GetDeletedUsers)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			return err
+		}
+
+		deleted := make([]deletedUser, len(users))
+		for i, user := range users {
+			deleted[i] = deletedUser{User: user, DeletedAt: user.DeletedAt.Time}
 		}
+		page.Data = deleted
 
-		return c.JSON(http.StatusOK, users)
+		return c.JSON(http.StatusOK, page)
 	}
 }
 
-// getOne returns the user
+// list fetches the users of the page requested by the list parameters.
+// The returned page has no data, the caller sets it.
+func (u UserHandler) list(c echo.Context, config query.Config, fetch func(query.Params) ([]entities.User, int64, error)) ([]entities.User, usersPage, error) {
+	params, queryErrors := query.Parse(c.QueryParams(), config)
+	if queryErrors != nil {
+		return nil, usersPage{}, echo.NewHTTPError(http.StatusBadRequest, queryErrors)
+	}
+
+	users, total, err := fetch(params)
+	if err != nil {
+		return nil, usersPage{}, echo.NewHTTPError(http.StatusInternalServerError, "Error when retrieving users").SetInternal(err)
+	}
+
+	var nextCursor string
+	if params.HasMore(len(users)) {
+		users = users[:params.Limit]
+		nextCursor, err = query.EncodeCursor(params.Sort, userSortValues(users[len(users)-1], params.Sort))
+		if err != nil {
+			return nil, usersPage{}, echo.NewHTTPError(http.StatusInternalServerError, "Error when retrieving users")
+		}
+	}
+
+	if links := query.Links(*c.Request().URL, params, total, nextCursor); links != "" {
+		c.Response().Header().Set("Link", links)
+	}
+
+	return users, usersPage{
+		Total:      total,
+		Page:       params.Page,
+		Limit:      params.Limit,
+		NextCursor: nextCursor,
+	}, nil
+}
+
+// userSortValues returns the values of the sorted fields of a user.
+func userSortValues(user entities.User, sort []query.Sort) []interface{} {
+	values := make([]interface{}, len(sort))
+	for i, s := range sort {
+		switch s.Field {
+		case "id":
+			values[i] = user.ID
+		case "username":
+			values[i] = user.Username
+		case "lastname":
+			values[i] = user.Lastname
+		case "firstname":
+			values[i] = user.Firstname
+		case "created_at":
+			values[i] = user.CreatedAt
+		case "updated_at":
+			values[i] = user.UpdatedAt
+		case "deleted_at":
+			values[i] = user.DeletedAt.Time
+		}
+	}
+	return values
+}
+
+// getOne returns the user with its ETag.
+// 304 Not Modified is returned if the If-None-Match header matches the ETag.
 func (u UserHandler) getOne() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		id := c.Param("id")
@@ -129,19 +347,29 @@ func (u UserHandler) getOne() echo.HandlerFunc {
 			return echo.NewHTTPError(http.StatusBadRequest, "Bad ID")
 		}
 
-		user, err := u.store.GetUser(id)
+		user, err := u.store.WithContext(c.Request().Context()).GetUser(id)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "Error when retrieving user")
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when retrieving user").SetInternal(err)
 		}
 		if user.ID == "" {
 			return echo.NewHTTPError(http.StatusNotFound, "No user found")
 		}
 
+		etag := userETag(user)
+		c.Response().Header().Set(headerETag, etag)
+		if ifNoneMatch := c.Request().Header.Get(headerIfNoneMatch); ifNoneMatch != "" && matchETag(ifNoneMatch, etag, true) {
+			return c.NoContent(http.StatusNotModified)
+		}
+
 		return c.JSON(http.StatusOK, user)
 	}
 }
 
-// delete deletes the user
+// delete deletes the user.
+// The user is soft deleted, unless ?purge=true is given: the user and their data are then permanently deleted.
+// The If-Match header of a soft deletion must match the ETag of the user (see update).
+// Purges are not conditional, deleted users have no ETag.
+// In both cases, all the user tokens are revoked.
 func (u UserHandler) delete() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		id := c.Param("id")
@@ -149,16 +377,72 @@ func (u UserHandler) delete() echo.HandlerFunc {
 			return echo.NewHTTPError(http.StatusBadRequest, "Bad ID")
 		}
 
-		err := u.store.DeleteUser(id)
+		users := u.store.WithContext(c.Request().Context()).WithAudit(auditMeta(c))
+		if c.QueryParam("purge") == "true" {
+			claims, err := entities.ClaimsFromToken(c.Get("user"))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+			if !claims.HasPermission(entities.PermissionUsersPurge) {
+				return echo.NewHTTPError(http.StatusForbidden, "Missing permission "+entities.PermissionUsersPurge)
+			}
+
+			if err := users.PurgeUser(id); err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return echo.NewHTTPError(http.StatusNotFound, "No user found")
+				}
+				return echo.NewHTTPError(http.StatusInternalServerError, "Error when purging user").SetInternal(err)
+			}
+			if err := u.revocationStore.RevokeAllForUser(id); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Error when revoking user tokens")
+			}
+			return c.NoContent(http.StatusOK)
+		}
+
+		current, err := users.GetUser(id)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "Error when deleting user")
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when deleting user").SetInternal(err)
+		}
+		if _, err := u.checkIfMatch(c, current); err != nil {
+			return err
+		}
+
+		if err := users.DeleteUser(id); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when deleting user").SetInternal(err)
+		}
+		if err := u.revocationStore.RevokeAllForUser(id); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when revoking user tokens")
 		}
 
 		return c.NoContent(http.StatusOK)
 	}
 }
 
+// restore restores a soft deleted user
+func (u UserHandler) restore() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Param("id")
+		if id == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bad ID")
+		}
+
+		user, err := u.store.WithContext(c.Request().Context()).WithAudit(auditMeta(c)).RestoreUser(id)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when restoring user").SetInternal(err)
+		}
+		if user.ID == "" {
+			return echo.NewHTTPError(http.StatusNotFound, "No deleted user found")
+		}
+
+		c.Response().Header().Set(headerETag, userETag(user))
+		return c.JSON(http.StatusOK, user)
+	}
+}
+
 // update updates user information.
+// If the If-Match header is given, it must match the ETag of the user, otherwise 412 Precondition Failed is returned.
+// If it is required and missing, 428 Precondition Required is returned.
+// All the user tokens are revoked if the password changes.
 func (u UserHandler) update() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		id := c.Param("id")
@@ -176,15 +460,248 @@ func (u UserHandler) update() echo.HandlerFunc {
 			return echo.NewHTTPError(http.StatusBadRequest, updateErrors)
 		}
 
-		updatedUser, err := u.store.UpdateUser(id, user)
+		policyErrors, err := u.passwordPolicy.Validate(user.Password, user.Username)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when checking password")
+		}
+		if policyErrors != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, policyErrors)
+		}
+
+		users := u.store.WithContext(c.Request().Context())
+		current, err := users.GetUser(id)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when updating user").SetInternal(err)
+		}
+		if current.ID == "" {
+			return echo.NewHTTPError(http.StatusNotFound, "No user found")
+		}
+
+		// Optimistic concurrency control
+		// ------------------------------
+		var version uint
+		matched, err := u.checkIfMatch(c, current)
+		if err != nil {
+			return err
+		}
+		if matched {
+			version = current.Version
+		}
+
+		updatedUser, err := users.WithAudit(auditMeta(c)).UpdateUser(id, user, version)
+		if err != nil {
+			if errors.Is(err, store.ErrVersionConflict) {
+				return echo.NewHTTPError(http.StatusPreconditionFailed, err.Error())
+			}
+			if errors.Is(err, store.ErrPasswordReused) {
+				return echo.NewHTTPError(http.StatusBadRequest, u.passwordPolicy.ReusedError())
+			}
+			if errors.Is(err, store.ErrUsernameTaken) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when updating user").SetInternal(err)
+		}
+
+		if updatedUser.ID != "" {
+			if updatedUser.Password != current.Password {
+				if err := u.revocationStore.RevokeAllForUser(id); err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, "Error when revoking user tokens")
+				}
+			}
+			if updatedUser.Username != current.Username {
+				u.sendVerificationEmail(updatedUser)
+			}
+			c.Response().Header().Set(headerETag, userETag(updatedUser))
+		}
+		return c.JSON(http.StatusOK, updatedUser)
+	}
+}
+
+// patch partially updates user information with a JSON Merge Patch (application/merge-patch+json)
+// or a JSON Patch (application/json-patch+json) applied to the user representation.
+// Only the supplied fields are validated and the password is only hashed if it is given.
+// The password is not in the representation, it is set with an add operation in a JSON Patch.
+// If-Match is checked as in update.
+func (u UserHandler) patch() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Param("id")
+		if id == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bad ID")
+		}
+
+		var apply func(doc interface{}, patch []byte) (interface{}, error)
+		mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+		switch mediaType {
+		case patch.MIMEMergePatch:
+			apply = patch.Merge
+		case patch.MIMEJSONPatch:
+			apply = patch.Apply
+		default:
+			c.Response().Header().Set("Accept-Patch", patch.MIMEMergePatch+", "+patch.MIMEJSONPatch)
+			return echo.ErrUnsupportedMediaType
+		}
+
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Bad data")
+		}
+
+		users := u.store.WithContext(c.Request().Context())
+		current, err := users.GetUser(id)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when updating user").SetInternal(err)
+		}
+		if current.ID == "" {
+			return echo.NewHTTPError(http.StatusNotFound, "No user found")
+		}
+		if _, err := u.checkIfMatch(c, current); err != nil {
+			return err
+		}
+
+		// Patch application
+		// -----------------
+		doc, err := userDocument(current)
 		if err != nil {
 			return echo.NewHTTPError(http.StatusInternalServerError, "Error when updating user")
 		}
+		patched, err := apply(doc, body)
+		if err != nil {
+			if errors.Is(err, patch.ErrTestFailed) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		after, ok := patched.(map[string]interface{})
+		if !ok {
+			return echo.NewHTTPError(http.StatusBadRequest, "The patched user must be an object")
+		}
+		userPatch, patchErrors := userPatchFromDocuments(doc, after)
+		if patchErrors == nil {
+			patchErrors = utils.ValidateStruct(*userPatch)
+		}
+		if patchErrors != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, patchErrors)
+		}
 
+		if userPatch.Password != nil {
+			username := current.Username
+			if userPatch.Username != nil {
+				username = *userPatch.Username
+			}
+			policyErrors, err := u.passwordPolicy.Validate(*userPatch.Password, username)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Error when checking password")
+			}
+			if policyErrors != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, policyErrors)
+			}
+		}
+
+		// The patch has been computed from the current version
+		updatedUser, err := users.WithAudit(auditMeta(c)).PatchUser(id, userPatch, current.Version)
+		if err != nil {
+			if errors.Is(err, store.ErrVersionConflict) {
+				return echo.NewHTTPError(http.StatusPreconditionFailed, err.Error())
+			}
+			if errors.Is(err, store.ErrPasswordReused) {
+				return echo.NewHTTPError(http.StatusBadRequest, u.passwordPolicy.ReusedError())
+			}
+			if errors.Is(err, store.ErrUsernameTaken) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Error when updating user").SetInternal(err)
+		}
+		if updatedUser.ID == "" {
+			return echo.NewHTTPError(http.StatusNotFound, "No user found")
+		}
+
+		if updatedUser.Password != current.Password {
+			if err := u.revocationStore.RevokeAllForUser(id); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Error when revoking user tokens")
+			}
+		}
+		if updatedUser.Username != current.Username {
+			u.sendVerificationEmail(updatedUser)
+		}
+
+		c.Response().Header().Set(headerETag, userETag(updatedUser))
 		return c.JSON(http.StatusOK, updatedUser)
 	}
 }
 
+// userPatchFields maps the writable fields of the user representation to the UserPatch fields.
+var userPatchFields = map[string]string{
+	"username":  "Username",
+	"password":  "Password",
+	"lastname":  "Lastname",
+	"firstname": "Firstname",
+}
+
+// userDocument returns the JSON representation of a user, decoded as a generic value.
+func userDocument(user entities.User) (map[string]interface{}, error) {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+	err = json.Unmarshal(data, &doc)
+	return doc, err
+}
+
+// userPatchFromDocuments returns the changes between the user representation and the patched one.
+// Only the writable fields can be changed and they cannot be removed.
+func userPatchFromDocuments(doc, after map[string]interface{}) (*entities.UserPatch, []*utils.ValidatorError) {
+	fields := make([]string, 0, len(doc)+len(after))
+	for field := range doc {
+		fields = append(fields, field)
+	}
+	for field := range after {
+		if _, ok := doc[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	userPatch := new(entities.UserPatch)
+	var errors []*utils.ValidatorError
+	for _, field := range fields {
+		before, existed := doc[field]
+		value, exists := after[field]
+		if existed && exists && reflect.DeepEqual(before, value) {
+			continue
+		}
+
+		name, writable := userPatchFields[field]
+		if !writable {
+			errors = append(errors, &utils.ValidatorError{FailedField: field, Tag: "readonly"})
+			continue
+		}
+		if !exists {
+			errors = append(errors, &utils.ValidatorError{FailedField: name, Tag: "required"})
+			continue
+		}
+		s, ok := value.(string)
+		if !ok {
+			errors = append(errors, &utils.ValidatorError{FailedField: name, Tag: "string"})
+			continue
+		}
+
+		switch field {
+		case "username":
+			userPatch.Username = &s
+		case "password":
+			userPatch.Password = &s
+		case "lastname":
+			userPatch.Lastname = &s
+		case "firstname":
+			userPatch.Firstname = &s
+		}
+	}
+	return userPatch, errors
+}
+
 // stream is an example of users list sent with a stream
 func (u UserHandler) stream() echo.HandlerFunc {
 	return func(c echo.Context) error {
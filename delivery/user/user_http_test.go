@@ -1 +1,530 @@
 package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	storeUser "github.com/fabienbellanger/echo-boilerplate/store/user"
+	"github.com/fabienbellanger/echo-boilerplate/utils/mailer"
+	"github.com/fabienbellanger/echo-boilerplate/utils/password"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// newTestHandler returns a UserHandler using an in-memory store with the given users.
+func newTestHandler(t *testing.T, users ...*entities.User) UserHandler {
+	s := storeUser.NewMemory(password.NewBcrypt(4), storeUser.Options{})
+	for _, user := range users {
+		if err := s.WithContext(context.Background()).Register(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return New(nil, Dependencies{
+		UserStore:          s,
+		RevocationStore:    &revocationStoreStub{},
+		OneTimeTokenStore:  &oneTimeTokenStoreStub{},
+		EmailThrottleStore: &throttleStoreStub{limit: 10, attempts: make(map[string]int)},
+		Mailer:             mailerStub{},
+		Logger:             zap.NewNop(),
+		RequireIfMatch:     true,
+	})
+}
+
+// oneTimeTokenStoreStub is a store.OneTimeTokenStorer recording the users of the created tokens.
+type oneTimeTokenStoreStub struct {
+	mu      sync.Mutex
+	userIDs []string
+	err     error // Returned by Create
+}
+
+func (s *oneTimeTokenStoreStub) Create(userID, purpose string, lifetime time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return "", s.err
+	}
+	s.userIDs = append(s.userIDs, userID)
+	return "token", nil
+}
+func (s *oneTimeTokenStoreStub) Peek(token, purpose string) (string, error)    { return "", nil }
+func (s *oneTimeTokenStoreStub) Consume(token, purpose string) (string, error) { return "", nil }
+func (s *oneTimeTokenStoreStub) Release(token, purpose string) error           { return nil }
+
+// created returns the users of the created tokens.
+func (s *oneTimeTokenStoreStub) created() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.userIDs...)
+}
+
+// throttleStoreStub is a store.LoginThrottleStorer blocking a username or an IP address after limit attempts.
+type throttleStoreStub struct {
+	limit    int
+	attempts map[string]int
+}
+
+func (s *throttleStoreStub) Check(username, ip string) (time.Duration, error) {
+	if s.attempts[entities.LoginThrottleUsernameKey(username)] >= s.limit || s.attempts[entities.LoginThrottleIPKey(ip)] >= s.limit {
+		return time.Minute, nil
+	}
+	return 0, nil
+}
+func (s *throttleStoreStub) RegisterFailure(username, ip string) (entities.LoginFailure, error) {
+	s.attempts[entities.LoginThrottleUsernameKey(username)]++
+	s.attempts[entities.LoginThrottleIPKey(ip)]++
+	return entities.LoginFailure{}, nil
+}
+func (s *throttleStoreStub) RegisterSuccess(username string) error { return nil }
+func (s *throttleStoreStub) Unlock(username string) error          { return nil }
+
+// mailerStub is a mailer.Mailer sending nothing.
+type mailerStub struct{}
+
+func (mailerStub) Send(msg mailer.Message) error { return nil }
+
+// revocationStoreStub is a store.TokenRevocationStorer recording the users whose tokens are revoked.
+type revocationStoreStub struct {
+	userIDs []string
+}
+
+func (s *revocationStoreStub) Revoke(jti, userID string, expiresAt time.Time) error { return nil }
+func (s *revocationStoreStub) RevokeAllForUser(userID string) error {
+	s.userIDs = append(s.userIDs, userID)
+	return nil
+}
+func (s *revocationStoreStub) IsRevoked(jti, userID string, issuedAt time.Time) (bool, error) {
+	return false, nil
+}
+
+func TestGetOne(t *testing.T) {
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	h := newTestHandler(t, &user)
+	e := echo.New()
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	c.SetParamNames("id")
+	c.SetParamValues(user.ID)
+	assert.Nil(t, h.getOne()(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var body entities.User
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "john@test.com", body.Username)
+	assert.Empty(t, body.Password)
+
+	c = e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("unknown")
+	err := h.getOne()(c)
+	assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+
+	// The store error is attached, so that canceled requests are mapped by the error handler
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c = e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx), httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues(user.ID)
+	assert.ErrorIs(t, h.getOne()(c), context.Canceled)
+}
+
+func TestRegister(t *testing.T) {
+	h := newTestHandler(t)
+	tokens := h.oneTimeTokenStore.(*oneTimeTokenStoreStub)
+	e := echo.New()
+	newContext := func(body string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	c, rec := newContext(`{"username": "john@test.com", "password": "00000000", "lastname": "Doe", "firstname": "John"}`)
+	assert.Nil(t, h.register()(c))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var user entities.User
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &user))
+	assert.Equal(t, []string{user.ID}, tokens.created())
+
+	// The user is created even if the verification email cannot be sent
+	tokens.err = errors.New("token error")
+	c, rec = newContext(`{"username": "jane@test.com", "password": "00000000", "lastname": "Doe", "firstname": "Jane"}`)
+	assert.Nil(t, h.register()(c))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	found, err := h.store.WithContext(context.Background()).GetUserByUsername("jane@test.com")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, found.ID)
+}
+
+func TestResendVerification(t *testing.T) {
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	verified := entities.User{Username: "jane@test.com", Password: "00000000", Lastname: "Doe", Firstname: "Jane"}
+	h := newTestHandler(t, &user, &verified)
+	assert.Nil(t, h.store.WithContext(context.Background()).VerifyUser(verified.ID))
+	tokens := h.oneTimeTokenStore.(*oneTimeTokenStoreStub)
+	e := echo.New()
+
+	for _, username := range []string{"john@test.com", "jane@test.com", "unknown@test.com"} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"username": "`+username+`"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		assert.Nil(t, h.resendVerification()(e.NewContext(req, rec)))
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+	}
+	assert.Equal(t, []string{user.ID}, tokens.created(), "only unverified users receive an email")
+
+	// Requests are throttled
+	h.emailThrottleStore.(*throttleStoreStub).limit = 1
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"username": "john@test.com"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	err := h.resendVerification()(e.NewContext(req, rec))
+	assert.Equal(t, http.StatusTooManyRequests, err.(*echo.HTTPError).Code)
+	assert.Equal(t, "60", rec.Header().Get(echo.HeaderRetryAfter))
+	assert.Equal(t, []string{user.ID}, tokens.created())
+}
+
+func TestUpdateUsernameVerification(t *testing.T) {
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	h := newTestHandler(t, &user)
+	assert.Nil(t, h.store.WithContext(context.Background()).VerifyUser(user.ID))
+	tokens := h.oneTimeTokenStore.(*oneTimeTokenStoreStub)
+	e := echo.New()
+	update := func(body string) entities.User {
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("If-Match", "*")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(user.ID)
+		assert.Nil(t, h.update()(c))
+
+		var updated entities.User
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+		return updated
+	}
+
+	updated := update(`{"username": "john@test.com", "password": "11111111", "lastname": "Doe", "firstname": "Johnny"}`)
+	assert.NotNil(t, updated.VerifiedAt)
+	assert.Empty(t, tokens.created())
+
+	updated = update(`{"username": "johnny@test.com", "password": "22222222", "lastname": "Doe", "firstname": "Johnny"}`)
+	assert.Nil(t, updated.VerifiedAt)
+	assert.Equal(t, []string{user.ID}, tokens.created())
+}
+
+func TestUpdateRevocation(t *testing.T) {
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	h := newTestHandler(t, &user)
+	revocations := h.revocationStore.(*revocationStoreStub)
+	e := echo.New()
+	update := func(id, body string) error {
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("If-Match", "*")
+		c := e.NewContext(req, httptest.NewRecorder())
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		return h.update()(c)
+	}
+
+	err := update("unknown", `{"username": "john@test.com", "password": "00000000", "lastname": "Doe", "firstname": "John"}`)
+	assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+
+	// The tokens are kept if the password is unchanged
+	assert.Nil(t, update(user.ID, `{"username": "john@test.com", "password": "00000000", "lastname": "Doe", "firstname": "Johnny"}`))
+	assert.Empty(t, revocations.userIDs)
+
+	assert.Nil(t, update(user.ID, `{"username": "john@test.com", "password": "11111111", "lastname": "Doe", "firstname": "Johnny"}`))
+	assert.Equal(t, []string{user.ID}, revocations.userIDs)
+}
+
+func TestGetAll(t *testing.T) {
+	h := newTestHandler(t,
+		&entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"},
+		&entities.User{Username: "jane@test.com", Password: "00000000", Lastname: "Doe", Firstname: "Jane"},
+		&entities.User{Username: "alice@test.com", Password: "00000000", Lastname: "Martin", Firstname: "Alice"},
+	)
+	e := echo.New()
+
+	type usersPage struct {
+		Data       []entities.User `json:"data"`
+		Total      int64           `json:"total"`
+		NextCursor string          `json:"next_cursor"`
+	}
+	getAll := func(target string) usersPage {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec)
+		assert.Nil(t, h.getAll()(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var page usersPage
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		return page
+	}
+
+	// The page is exactly the last one
+	page := getAll("/?limit=2&lastname=doe")
+	assert.Equal(t, int64(2), page.Total)
+	assert.Len(t, page.Data, 2)
+	assert.Equal(t, "Jane", page.Data[0].Firstname)
+	assert.Empty(t, page.NextCursor)
+
+	page = getAll("/?limit=1&lastname=doe")
+	if assert.Len(t, page.Data, 1) {
+		assert.Equal(t, "Jane", page.Data[0].Firstname)
+	}
+	assert.NotEmpty(t, page.NextCursor)
+
+	page = getAll("/?limit=1&lastname=doe&cursor=" + page.NextCursor)
+	if assert.Len(t, page.Data, 1) {
+		assert.Equal(t, "John", page.Data[0].Firstname)
+	}
+	assert.Empty(t, page.NextCursor)
+
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/?sort=password", nil), httptest.NewRecorder())
+	err := h.getAll()(c)
+	assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+}
+
+func TestDelete(t *testing.T) {
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	h := newTestHandler(t, &user)
+	e := echo.New()
+
+	del := func(ifMatch string) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(http.MethodDelete, "/", nil)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(user.ID)
+		return rec, h.delete()(c)
+	}
+
+	_, err := del("")
+	assert.Equal(t, http.StatusPreconditionRequired, err.(*echo.HTTPError).Code)
+	_, err = del(`"2"`)
+	assert.Equal(t, http.StatusPreconditionFailed, err.(*echo.HTTPError).Code)
+
+	rec, err := del(`"1"`)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	deleted, err := h.store.WithContext(context.Background()).GetUser(user.ID)
+	assert.Nil(t, err)
+	assert.Empty(t, deleted.ID)
+	assert.Equal(t, []string{user.ID}, h.revocationStore.(*revocationStoreStub).userIDs)
+}
+
+func TestPurgeAndRestore(t *testing.T) {
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	h := newTestHandler(t, &user)
+	e := echo.New()
+	newContext := func(method, target string, permissions ...string) (echo.Context, *httptest.ResponseRecorder) {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(method, target, nil), rec)
+		c.SetParamNames("id")
+		c.SetParamValues(user.ID)
+		claims := entities.NewClaims("admin-id", "admin@test.com", "Admin", "", 10)
+		claims.Permissions = permissions
+		c.Set("user", &jwt.Token{Claims: claims, Valid: true})
+		return c, rec
+	}
+
+	// Restore
+	c, _ := newContext(http.MethodPost, "/")
+	err := h.restore()(c)
+	assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+
+	c, _ = newContext(http.MethodDelete, "/")
+	c.Request().Header.Set("If-Match", "*")
+	assert.Nil(t, h.delete()(c))
+
+	c, rec := newContext(http.MethodGet, "/")
+	assert.Nil(t, h.getDeleted()(c))
+	var page struct {
+		Data []struct {
+			ID        string     `json:"id"`
+			DeletedAt *time.Time `json:"deleted_at"`
+		} `json:"data"`
+	}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	if assert.Len(t, page.Data, 1) {
+		assert.Equal(t, user.ID, page.Data[0].ID)
+		assert.NotNil(t, page.Data[0].DeletedAt)
+	}
+
+	c, rec = newContext(http.MethodPost, "/")
+	assert.Nil(t, h.restore()(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Purge
+	c, _ = newContext(http.MethodDelete, "/?purge=true", entities.PermissionUsersDelete)
+	err = h.delete()(c)
+	assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+
+	revocations := h.revocationStore.(*revocationStoreStub)
+	revocations.userIDs = nil
+	c, _ = newContext(http.MethodDelete, "/?purge=true", entities.PermissionUsersDelete, entities.PermissionUsersPurge)
+	assert.Nil(t, h.delete()(c))
+	assert.Equal(t, []string{user.ID}, revocations.userIDs)
+
+	c, _ = newContext(http.MethodDelete, "/?purge=true", entities.PermissionUsersDelete, entities.PermissionUsersPurge)
+	err = h.delete()(c)
+	assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+	assert.Len(t, revocations.userIDs, 1)
+
+	c, _ = newContext(http.MethodPost, "/")
+	err = h.restore()(c)
+	assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+}
+
+func TestETag(t *testing.T) {
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	h := newTestHandler(t, &user)
+	e := echo.New()
+	newContext := func(method string, body string, headers map[string]string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(user.ID)
+		return c, rec
+	}
+	form := `{"username": "john@test.com", "password": "11111111", "lastname": "Doe", "firstname": "Johnny"}`
+
+	// Read
+	c, rec := newContext(http.MethodGet, "", nil)
+	assert.Nil(t, h.getOne()(c))
+	assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+
+	c, rec = newContext(http.MethodGet, "", map[string]string{"If-None-Match": `W/"1"`})
+	assert.Nil(t, h.getOne()(c))
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	// Update
+	c, _ = newContext(http.MethodPut, form, nil)
+	err := h.update()(c)
+	assert.Equal(t, http.StatusPreconditionRequired, err.(*echo.HTTPError).Code)
+
+	c, rec = newContext(http.MethodPut, form, map[string]string{"If-Match": `"1"`})
+	assert.Nil(t, h.update()(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	c, _ = newContext(http.MethodPut, form, map[string]string{"If-Match": `"1"`})
+	err = h.update()(c)
+	assert.Equal(t, http.StatusPreconditionFailed, err.(*echo.HTTPError).Code)
+
+	c, rec = newContext(http.MethodGet, "", map[string]string{"If-None-Match": `"1"`})
+	assert.Nil(t, h.getOne()(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestMatchETag(t *testing.T) {
+	assert.True(t, matchETag(`"2"`, `"2"`, false))
+	assert.True(t, matchETag(`"1", "2"`, `"2"`, false))
+	assert.True(t, matchETag(`*`, `"2"`, false))
+	assert.False(t, matchETag(`"1"`, `"2"`, false))
+	assert.False(t, matchETag(`W/"2"`, `"2"`, false))
+	assert.True(t, matchETag(`W/"2"`, `"2"`, true))
+}
+
+func TestPatch(t *testing.T) {
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	h := newTestHandler(t, &user)
+	e := echo.New()
+	patch := func(contentType, body string, headers map[string]string) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(user.ID)
+		return rec, h.patch()(c)
+	}
+	code := func(err error) int {
+		if httpError, ok := err.(*echo.HTTPError); ok {
+			return httpError.Code
+		}
+		return 0
+	}
+	anyVersion := map[string]string{"If-Match": "*"}
+	stored, err := h.store.WithContext(context.Background()).GetUser(user.ID)
+	assert.Nil(t, err)
+
+	// Merge patch
+	rec, err := patch("application/merge-patch+json", `{"firstname": "Johnny"}`, anyVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	patched, err := h.store.WithContext(context.Background()).GetUser(user.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "Johnny", patched.Firstname)
+	assert.Equal(t, "Doe", patched.Lastname)
+	assert.Equal(t, stored.Password, patched.Password)
+
+	// JSON Patch
+	rec, err = patch("application/json-patch+json", `[
+		{"op": "test", "path": "/firstname", "value": "Johnny"},
+		{"op": "replace", "path": "/lastname", "value": "Smith"},
+		{"op": "add", "path": "/password", "value": "11111111"}
+	]`, map[string]string{"If-Match": `"2"`})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	patched, err = h.store.WithContext(context.Background()).GetUser(user.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "Smith", patched.Lastname)
+	assert.NotEqual(t, stored.Password, patched.Password)
+
+	// Errors
+	_, err = patch("application/merge-patch+json", `{"firstname": "Jo"}`, nil)
+	assert.Equal(t, http.StatusPreconditionRequired, code(err))
+	_, err = patch("application/json", `{"firstname": "Jo"}`, anyVersion)
+	assert.Equal(t, http.StatusUnsupportedMediaType, code(err))
+	_, err = patch("application/merge-patch+json", `{"firstname": "Jo"}`, map[string]string{"If-Match": `"2"`})
+	assert.Equal(t, http.StatusPreconditionFailed, code(err))
+	_, err = patch("application/json-patch+json", `[{"op": "test", "path": "/firstname", "value": "Jo"}]`, anyVersion)
+	assert.Equal(t, http.StatusConflict, code(err))
+	_, err = patch("application/json-patch+json", `[{"op": "replace", "path": "/password", "value": "22222222"}]`, anyVersion)
+	assert.Equal(t, http.StatusBadRequest, code(err))
+
+	for _, body := range []string{
+		`{"id": "other"}`,
+		`{"lastname": null}`,
+		`{"lastname": ""}`,
+		`{"lastname": 1}`,
+		`{"username": "not an email"}`,
+		`"user"`,
+	} {
+		_, err = patch("application/merge-patch+json", body, anyVersion)
+		assert.Equal(t, http.StatusBadRequest, code(err), body)
+	}
+
+	patched, err = h.store.WithContext(context.Background()).GetUser(user.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(3), patched.Version)
+}
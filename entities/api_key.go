@@ -0,0 +1,32 @@
+package entities
+
+import (
+	"time"
+)
+
+// APIKeyPrefix is the recognisable prefix of all API keys.
+const APIKeyPrefix = "ebk_"
+
+// APIKey represents a personal API key.
+// Only the SHA-256 hash of the key is stored, the key is only displayed at creation.
+// Scopes are permissions, the effective permissions are the scopes still granted to the user.
+type APIKey struct {
+	ID         string     `json:"id" xml:"id" form:"id" gorm:"primaryKey;size:36"`
+	UserID     string     `json:"-" xml:"-" form:"-" gorm:"index;size:36"`
+	Name       string     `json:"name" xml:"name" form:"name" gorm:"size:127"`
+	Prefix     string     `json:"prefix" xml:"prefix" form:"prefix" gorm:"size:15"` // Beginning of the key, used to recognize it
+	Hash       string     `json:"-" xml:"-" form:"-" gorm:"uniqueIndex;size:64"`
+	Scopes     []string   `json:"scopes" xml:"scopes" form:"scopes" gorm:"serializer:json;type:text"`
+	ExpiresAt  *time.Time `json:"expires_at" xml:"expires_at" form:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" xml:"last_used_at" form:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at" xml:"revoked_at" form:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" xml:"created_at" form:"created_at" gorm:"autoCreateTime"`
+	Key        string     `json:"key,omitempty" xml:"key,omitempty" form:"-" gorm:"-"` // Clear key, only set at creation
+}
+
+// APIKeyForm is used to create an API key.
+type APIKeyForm struct {
+	Name      string   `json:"name" xml:"name" form:"name" validate:"required,max=127"`
+	Scopes    []string `json:"scopes" xml:"scopes" form:"scopes" validate:"required,min=1"`
+	ExpiresIn int      `json:"expires_in" xml:"expires_in" form:"expires_in" validate:"min=0"` // In days, 0 for the maximum lifetime
+}
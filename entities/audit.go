@@ -0,0 +1,147 @@
+package entities
+
+import (
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Audit actions
+const (
+	AuditActionCreate      = "create"
+	AuditActionUpdate      = "update"
+	AuditActionDelete      = "delete"
+	AuditActionRestore     = "restore"
+	AuditActionPurge       = "purge"
+	AuditActionImpersonate = "impersonate"
+	AuditActionAssignRoles = "assign_roles"
+)
+
+// AuditTargetUser is the target type of the user operations.
+const AuditTargetUser = "user"
+
+// AuditRedacted replaces the values of secret fields.
+const AuditRedacted = "[REDACTED]"
+
+// ErrAuditLogAppendOnly is returned when an audit log is updated or deleted.
+var ErrAuditLogAppendOnly = errors.New("audit logs are append-only")
+
+// AuditLog is an entry of the audit trail.
+// Entries cannot be updated or deleted.
+type AuditLog struct {
+	ID         string       `json:"id" xml:"id" form:"id" gorm:"primaryKey;size:36"`
+	ActorID    string       `json:"actor_id" xml:"actor_id" form:"actor_id" gorm:"index;size:36"`
+	OnBehalfOf string       `json:"on_behalf_of,omitempty" xml:"on_behalf_of,omitempty" form:"on_behalf_of" gorm:"size:36"` // Impersonated user
+	Action     string       `json:"action" xml:"action" form:"action" gorm:"size:31"`
+	TargetType string       `json:"target_type" xml:"target_type" form:"target_type" gorm:"size:63"`
+	TargetID   string       `json:"target_id" xml:"target_id" form:"target_id" gorm:"index;size:36"`
+	Changes    AuditChanges `json:"changes" xml:"-" form:"-" gorm:"serializer:json;type:text"`
+	IP         string       `json:"ip" xml:"ip" form:"ip" gorm:"size:45"`
+	RequestID  string       `json:"request_id" xml:"request_id" form:"request_id" gorm:"size:36"`
+	CreatedAt  time.Time    `json:"created_at" xml:"created_at" form:"created_at" gorm:"index;autoCreateTime"`
+}
+
+// BeforeUpdate prevents audit logs updates.
+func (AuditLog) BeforeUpdate(*gorm.DB) error {
+	return ErrAuditLogAppendOnly
+}
+
+// BeforeDelete prevents audit logs deletions.
+func (AuditLog) BeforeDelete(*gorm.DB) error {
+	return ErrAuditLogAppendOnly
+}
+
+// AuditMeta describes the request at the origin of an audited operation.
+type AuditMeta struct {
+	ActorID    string
+	OnBehalfOf string // Impersonated user
+	IP         string
+	RequestID  string
+}
+
+// NewAuditLog creates a new AuditLog
+func NewAuditLog(meta AuditMeta, action, targetType, targetID string, changes AuditChanges) AuditLog {
+	// The JSON serializer cannot store a nil map
+	if changes == nil {
+		changes = AuditChanges{}
+	}
+
+	return AuditLog{
+		ID:         uuid.New().String(),
+		ActorID:    meta.ActorID,
+		OnBehalfOf: meta.OnBehalfOf,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Changes:    changes,
+		IP:         meta.IP,
+		RequestID:  meta.RequestID,
+	}
+}
+
+// AuditChange is the before and after values of a field.
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditChanges lists the changed fields.
+type AuditChanges map[string]AuditChange
+
+// NewAuditChanges returns the fields whose values differ between before and after.
+// A nil map represents a missing resource. The values of secret fields are redacted.
+func NewAuditChanges(before, after map[string]interface{}, secrets ...string) AuditChanges {
+	changes := make(AuditChanges)
+	for field, value := range after {
+		if old, ok := before[field]; !ok || !reflect.DeepEqual(old, value) {
+			changes[field] = AuditChange{Before: old, After: value}
+		}
+	}
+	for field, old := range before {
+		if _, ok := after[field]; !ok {
+			changes[field] = AuditChange{Before: old}
+		}
+	}
+
+	for _, secret := range secrets {
+		if change, ok := changes[secret]; ok {
+			if change.Before != nil {
+				change.Before = AuditRedacted
+			}
+			if change.After != nil {
+				change.After = AuditRedacted
+			}
+			changes[secret] = change
+		}
+	}
+	return changes
+}
+
+// Redacted returns the changes with all their values redacted, the changed fields are kept.
+// It is used to erase the personal data of a purged user from the audit trail.
+func (c AuditChanges) Redacted() AuditChanges {
+	redacted := make(AuditChanges, len(c))
+	for field, change := range c {
+		if change.Before != nil {
+			change.Before = AuditRedacted
+		}
+		if change.After != nil {
+			change.After = AuditRedacted
+		}
+		redacted[field] = change
+	}
+	return redacted
+}
+
+// AuditFilter restricts the listed audit logs.
+type AuditFilter struct {
+	ActorID    string
+	TargetType string
+	TargetID   string
+	Action     string
+	Since      *time.Time
+	Until      *time.Time
+}
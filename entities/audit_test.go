@@ -0,0 +1,49 @@
+package entities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAuditChanges(t *testing.T) {
+	before := User{Username: "john@example.com", Password: "hash1", Lastname: "Doe", Firstname: "John"}
+	after := before
+	after.Firstname = "Johnny"
+	after.Password = "hash2"
+
+	changes := NewAuditChanges(before.AuditFields(), after.AuditFields(), AuditSecretFields...)
+	assert.Equal(t, AuditChanges{
+		"firstname": {Before: "John", After: "Johnny"},
+		"password":  {Before: AuditRedacted, After: AuditRedacted},
+	}, changes)
+
+	// Creation
+	changes = NewAuditChanges(nil, before.AuditFields(), AuditSecretFields...)
+	assert.Equal(t, AuditChange{Before: nil, After: AuditRedacted}, changes["password"])
+	assert.Equal(t, AuditChange{Before: nil, After: "Doe"}, changes["lastname"])
+
+	// Deletion
+	changes = NewAuditChanges(before.AuditFields(), nil, AuditSecretFields...)
+	assert.Equal(t, AuditChange{Before: "john@example.com", After: nil}, changes["username"])
+	assert.Len(t, changes, 4)
+}
+
+func TestAuditChangesRedacted(t *testing.T) {
+	changes := AuditChanges{
+		"firstname": {Before: "John", After: "Johnny"},
+		"lastname":  {After: "Doe"},
+	}
+	assert.Equal(t, AuditChanges{
+		"firstname": {Before: AuditRedacted, After: AuditRedacted},
+		"lastname":  {Before: nil, After: AuditRedacted},
+	}, changes.Redacted())
+	assert.Equal(t, "John", changes["firstname"].Before, "the changes must not be modified")
+}
+
+func TestNewAuditLog(t *testing.T) {
+	log := NewAuditLog(AuditMeta{ActorID: "admin-id"}, AuditActionCreate, AuditTargetUser, "user-id", nil)
+	assert.NotEmpty(t, log.ID)
+	assert.Equal(t, "admin-id", log.ActorID)
+	assert.NotNil(t, log.Changes)
+}
@@ -5,42 +5,121 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
 )
 
 // TODO: Add unit tests
 
+// AudienceClient is the audience of the access tokens.
+const AudienceClient = "Client"
+
 // Claims are custom claims extending default ones
 type Claims struct {
-	UserID    string `json:"user_id"`
-	Username  string `json:"username"`
-	Lastname  string `json:"lastname"`
-	Firstname string `json:"firstname"`
+	UserID      string   `json:"user_id"`
+	Username    string   `json:"username"`
+	Lastname    string   `json:"lastname"`
+	Firstname   string   `json:"firstname"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	SessionID   string   `json:"sid,omitempty"`
+	APIKeyID    string   `json:"api_key_id,omitempty"` // Set when authenticated with an API key
+	Actor       *Actor   `json:"act,omitempty"`        // Set when an admin impersonates the user
+	IssuedAtMs  int64    `json:"iat_ms,omitempty"`     // Issue time in milliseconds, iat is in seconds
 	jwt.StandardClaims
 }
 
+// Actor is the user acting on behalf of the subject of the token (RFC 8693 act claim).
+type Actor struct {
+	UserID   string `json:"sub"`
+	Username string `json:"username,omitempty"`
+}
+
+// IsImpersonation returns true if the token has been issued to an admin impersonating the user.
+func (c Claims) IsImpersonation() bool {
+	return c.Actor != nil
+}
+
 // NewClaims creates a new Claims
-func NewClaims(id, username, firstname, lastname string, lifetime int) *Claims {
+func NewClaims(id, username, lastname, firstname string, lifetime int) *Claims {
+	now := time.Now()
 	return &Claims{
-		id,
-		username,
-		lastname,
-		firstname,
-		jwt.StandardClaims{
+		UserID:     id,
+		Username:   username,
+		Lastname:   lastname,
+		Firstname:  firstname,
+		IssuedAtMs: now.UnixMilli(),
+		StandardClaims: jwt.StandardClaims{
 			Subject:   "API authentication", // Subject of the JWT (the user)
 			Issuer:    "API",                // Issuer of the JWT
-			Audience:  "Client",             // Recipient for which the JWT is intended
-			ExpiresAt: time.Now().Add(time.Minute * time.Duration(lifetime)).Unix(),
-			IssuedAt:  time.Now().Unix(),
-			NotBefore: time.Now().Unix(),
+			Audience:  AudienceClient,       // Recipient for which the JWT is intended
+			Id:        uuid.New().String(),  // Unique identifier used for revocation
+			ExpiresAt: now.Add(time.Minute * time.Duration(lifetime)).Unix(),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
 		},
 	}
 }
 
+// IssueTime returns the issue time of the token, used to check the revocations of its user.
+func (c Claims) IssueTime() time.Time {
+	return issueTime(c.IssuedAt, c.IssuedAtMs)
+}
+
+// issueTime returns the issue time in milliseconds if it is known, in seconds otherwise.
+func issueTime(iat, iatMs int64) time.Time {
+	if iatMs != 0 {
+		return time.UnixMilli(iatMs)
+	}
+	return time.Unix(iat, 0)
+}
+
+// Valid checks the standard claims and the audience,
+// so that tokens issued for another purpose are not accepted as access tokens.
+func (c Claims) Valid() error {
+	if err := c.StandardClaims.Valid(); err != nil {
+		return err
+	}
+	if !c.VerifyAudience(AudienceClient, true) {
+		return errors.New("invalid token audience")
+	}
+	return nil
+}
+
+// JWTSigner signs claims with the current signing key.
+type JWTSigner interface {
+	Sign(claims jwt.Claims) (string, error)
+}
+
+// JWTKeySet signs and verifies tokens.
+type JWTKeySet interface {
+	JWTSigner
+	KeyFunc(token *jwt.Token) (interface{}, error)
+}
+
 // GenerateJWT generates token
-func (c Claims) GenerateJWT(algo, secret string) (string, error) {
-	if algo == "HS512" {
-		token := jwt.NewWithClaims(jwt.SigningMethodHS512, c)
-		return token.SignedString([]byte(secret))
+func (c Claims) GenerateJWT(signer JWTSigner) (string, error) {
+	return signer.Sign(c)
+}
+
+// ClaimsFromToken returns the claims of a token set in context by the JWT middleware.
+func ClaimsFromToken(t interface{}) (*Claims, error) {
+	token, ok := t.(*jwt.Token)
+	if !ok {
+		return nil, errors.New("invalid JWT")
+	}
+	claims, ok := token.Claims.(*Claims)
+	if !ok {
+		return nil, errors.New("invalid JWT claims")
+	}
+	return claims, nil
+}
+
+// HasPermission returns true if the claims carry the permission.
+func (c Claims) HasPermission(permission string) bool {
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
 	}
-	return "", errors.New("unsupported JWT algo")
+	return false
 }
@@ -0,0 +1,45 @@
+package entities
+
+import (
+	"strings"
+	"time"
+)
+
+// LoginThrottle tracks the failed login attempts of a username or an IP address.
+// Other throttled actions, like email requests, are tracked in their own scope.
+type LoginThrottle struct {
+	Identifier   string     `json:"identifier" xml:"identifier" form:"identifier" gorm:"primaryKey;size:191"` // [<scope>:]username:<username> or [<scope>:]ip:<ip>
+	Failures     int        `json:"failures" xml:"failures" form:"failures"`
+	LastFailedAt time.Time  `json:"last_failed_at" xml:"last_failed_at" form:"last_failed_at"`
+	BlockedUntil *time.Time `json:"blocked_until" xml:"blocked_until" form:"blocked_until"`
+	Locked       bool       `json:"locked" xml:"locked" form:"locked"` // Blocked by an account lockout
+}
+
+// LoginFailure is the result of a failed login attempt.
+type LoginFailure struct {
+	Failures     int
+	BlockedFor   time.Duration
+	Locked       bool // The account is locked
+	IPBlockedFor time.Duration
+}
+
+// ThrottleScopeEmail is the throttle scope of the emails sent on request (Ex.: password reset).
+const ThrottleScopeEmail = "email"
+
+// LoginThrottleUsernameKey returns the throttle identifier of a username.
+func LoginThrottleUsernameKey(username string) string {
+	return "username:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+// LoginThrottleIPKey returns the throttle identifier of an IP address.
+func LoginThrottleIPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// ThrottleScopedKey returns the identifier of a key in a throttle scope, login failures have no scope.
+func ThrottleScopedKey(scope, key string) string {
+	if scope == "" {
+		return key
+	}
+	return scope + ":" + key
+}
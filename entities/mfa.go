@@ -0,0 +1,84 @@
+package entities
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+// AudienceMFA is the audience of the tokens issued between the two login steps.
+const AudienceMFA = "MFA"
+
+// TOTPFactor represents the TOTP (RFC 6238) second factor of a user.
+// The factor is only active once confirmed with a first valid code.
+type TOTPFactor struct {
+	UserID       string     `json:"-" xml:"-" form:"-" gorm:"primaryKey;size:36"`
+	Secret       string     `json:"-" xml:"-" form:"-" gorm:"size:63"` // Base32
+	LastUsedStep int64      `json:"-" xml:"-" form:"-"`                // Prevents code replay
+	ConfirmedAt  *time.Time `json:"confirmed_at" xml:"confirmed_at" form:"confirmed_at"`
+	CreatedAt    time.Time  `json:"created_at" xml:"created_at" form:"created_at" gorm:"autoCreateTime"`
+}
+
+// Enabled returns true if the factor has been confirmed.
+func (f TOTPFactor) Enabled() bool {
+	return f.ConfirmedAt != nil
+}
+
+// RecoveryCode represents a single use code replacing a TOTP code.
+// Only the SHA-256 hash of the code is stored.
+type RecoveryCode struct {
+	ID        string     `json:"-" xml:"-" form:"-" gorm:"primaryKey;size:36"`
+	UserID    string     `json:"-" xml:"-" form:"-" gorm:"index;size:36"`
+	Hash      string     `json:"-" xml:"-" form:"-" gorm:"size:64"`
+	UsedAt    *time.Time `json:"-" xml:"-" form:"-"`
+	CreatedAt time.Time  `json:"-" xml:"-" form:"-" gorm:"autoCreateTime"`
+}
+
+// TOTPCodeForm is used to send a TOTP or recovery code.
+type TOTPCodeForm struct {
+	Code string `json:"code" xml:"code" form:"code" validate:"required"`
+}
+
+// MFAClaims are the claims of the token returned by the first login step.
+// It can only be exchanged for an access token with a valid second factor.
+type MFAClaims struct {
+	UserID     string `json:"user_id"`
+	IssuedAtMs int64  `json:"iat_ms,omitempty"` // Issue time in milliseconds, iat is in seconds
+	jwt.StandardClaims
+}
+
+// NewMFAClaims creates a new MFAClaims
+func NewMFAClaims(userID string, lifetime int) *MFAClaims {
+	now := time.Now()
+	return &MFAClaims{
+		UserID:     userID,
+		IssuedAtMs: now.UnixMilli(),
+		StandardClaims: jwt.StandardClaims{
+			Subject:   "MFA pending",
+			Issuer:    "API",
+			Audience:  AudienceMFA,
+			Id:        uuid.New().String(),
+			ExpiresAt: now.Add(time.Minute * time.Duration(lifetime)).Unix(),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+		},
+	}
+}
+
+// IssueTime returns the issue time of the token, used to check the revocations of its user.
+func (c MFAClaims) IssueTime() time.Time {
+	return issueTime(c.IssuedAt, c.IssuedAtMs)
+}
+
+// Valid checks the standard claims and the audience.
+func (c MFAClaims) Valid() error {
+	if err := c.StandardClaims.Valid(); err != nil {
+		return err
+	}
+	if !c.VerifyAudience(AudienceMFA, true) {
+		return errors.New("invalid MFA token audience")
+	}
+	return nil
+}
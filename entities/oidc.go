@@ -0,0 +1,62 @@
+package entities
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+// AudienceOIDC is the audience of the tokens storing the state of an OpenID Connect login.
+const AudienceOIDC = "OIDC"
+
+// UserIdentity links a user to an account of an external identity provider.
+type UserIdentity struct {
+	ID        string    `json:"id" xml:"id" form:"id" gorm:"primaryKey;size:36"`
+	UserID    string    `json:"-" xml:"-" form:"-" gorm:"index;size:36"`
+	Provider  string    `json:"provider" xml:"provider" form:"provider" gorm:"uniqueIndex:idx_provider_subject;size:63"`
+	Subject   string    `json:"subject" xml:"subject" form:"subject" gorm:"uniqueIndex:idx_provider_subject;size:255"`
+	Email     string    `json:"email" xml:"email" form:"email" gorm:"size:127"`
+	CreatedAt time.Time `json:"created_at" xml:"created_at" form:"created_at" gorm:"autoCreateTime"`
+}
+
+// OIDCStateClaims are the claims of the cookie storing the state of an OpenID Connect login
+// between the redirection to the provider and the callback.
+type OIDCStateClaims struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.StandardClaims
+}
+
+// NewOIDCStateClaims creates a new OIDCStateClaims
+func NewOIDCStateClaims(provider, state, nonce, verifier string, lifetime int) *OIDCStateClaims {
+	return &OIDCStateClaims{
+		Provider: provider,
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   "OIDC login",
+			Issuer:    "API",
+			Audience:  AudienceOIDC,
+			Id:        uuid.New().String(),
+			ExpiresAt: time.Now().Add(time.Minute * time.Duration(lifetime)).Unix(),
+			IssuedAt:  time.Now().Unix(),
+			NotBefore: time.Now().Unix(),
+		},
+	}
+}
+
+// Valid checks the standard claims and the audience.
+func (c OIDCStateClaims) Valid() error {
+	if err := c.StandardClaims.Valid(); err != nil {
+		return err
+	}
+	if !c.VerifyAudience(AudienceOIDC, true) {
+		return errors.New("invalid OIDC state audience")
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package entities
+
+import (
+	"time"
+)
+
+// Permissions
+const (
+	PermissionUsersCreate      = "users:create"
+	PermissionUsersRead        = "users:read"
+	PermissionUsersUpdate      = "users:update"
+	PermissionUsersDelete      = "users:delete"
+	PermissionUsersRestore     = "users:restore"
+	PermissionUsersPurge       = "users:purge"
+	PermissionUsersUnlock      = "users:unlock"
+	PermissionUsersImpersonate = "users:impersonate"
+	PermissionRolesRead        = "roles:read"
+	PermissionRolesAssign      = "roles:assign"
+	PermissionAuditRead        = "audit:read"
+)
+
+// Roles
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// DefaultRoles lists the roles and their permissions created at startup.
+var DefaultRoles = map[string][]string{
+	RoleAdmin: {
+		PermissionUsersCreate,
+		PermissionUsersRead,
+		PermissionUsersUpdate,
+		PermissionUsersDelete,
+		PermissionUsersRestore,
+		PermissionUsersPurge,
+		PermissionUsersUnlock,
+		PermissionUsersImpersonate,
+		PermissionRolesRead,
+		PermissionRolesAssign,
+		PermissionAuditRead,
+	},
+	RoleUser: {
+		PermissionUsersRead,
+	},
+}
+
+// Role represents a set of permissions.
+type Role struct {
+	ID          uint         `json:"-" xml:"-" form:"-" gorm:"primaryKey"`
+	Name        string       `json:"name" xml:"name" form:"name" gorm:"unique;size:63" validate:"required"`
+	Permissions []Permission `json:"permissions" xml:"permissions" form:"permissions" gorm:"many2many:role_permissions"`
+	CreatedAt   time.Time    `json:"created_at" xml:"created_at" form:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time    `json:"updated_at" xml:"updated_at" form:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Permission represents an action on a resource (Ex.: users:delete).
+type Permission struct {
+	ID   uint   `json:"-" xml:"-" form:"-" gorm:"primaryKey"`
+	Name string `json:"name" xml:"name" form:"name" gorm:"unique;size:63" validate:"required"`
+}
+
+// UserRolesForm is used to assign roles to a user.
+type UserRolesForm struct {
+	Roles []string `json:"roles" xml:"roles" form:"roles" validate:"required"`
+}
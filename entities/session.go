@@ -0,0 +1,20 @@
+package entities
+
+import (
+	"time"
+)
+
+// Session represents a login of a user on a device.
+// Its ID is the family ID of the refresh tokens issued for this login
+// and the sid claim of the access tokens.
+type Session struct {
+	ID           string     `json:"id" xml:"id" form:"id" gorm:"primaryKey;size:36"`
+	UserID       string     `json:"-" xml:"-" form:"-" gorm:"index;size:36"`
+	UserAgent    string     `json:"user_agent" xml:"user_agent" form:"user_agent" gorm:"size:255"`
+	IP           string     `json:"ip" xml:"ip" form:"ip" gorm:"size:45"`
+	CreatedAt    time.Time  `json:"created_at" xml:"created_at" form:"created_at" gorm:"autoCreateTime"`
+	LastSeenAt   time.Time  `json:"last_seen_at" xml:"last_seen_at" form:"last_seen_at"`
+	ExpiresAt    time.Time  `json:"expires_at" xml:"expires_at" form:"expires_at" gorm:"index"`
+	TerminatedAt *time.Time `json:"-" xml:"-" form:"-"`
+	Current      bool       `json:"current" xml:"current" form:"current" gorm:"-"` // Session of the request
+}
@@ -0,0 +1,53 @@
+package entities
+
+import (
+	"time"
+)
+
+// RefreshToken represents an opaque refresh token in database.
+// Only the SHA-256 hash of the token is stored.
+// All tokens issued from the same login share the same family.
+type RefreshToken struct {
+	ID        string     `json:"id" xml:"id" form:"id" gorm:"primaryKey;size:36"`
+	UserID    string     `json:"user_id" xml:"user_id" form:"user_id" gorm:"index;size:36"`
+	FamilyID  string     `json:"family_id" xml:"family_id" form:"family_id" gorm:"index;size:36"`
+	Hash      string     `json:"-" xml:"-" form:"-" gorm:"uniqueIndex;size:64"`
+	ExpiresAt time.Time  `json:"expires_at" xml:"expires_at" form:"expires_at"`
+	UsedAt    *time.Time `json:"used_at" xml:"used_at" form:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at" xml:"revoked_at" form:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" xml:"created_at" form:"created_at" gorm:"autoCreateTime"`
+	Token     string     `json:"-" xml:"-" form:"-" gorm:"-"` // Clear token, only set at creation
+}
+
+// RevokedToken represents a revoked access token identified by its JWT ID.
+// It can be removed once the token has expired.
+type RevokedToken struct {
+	ID        string    `json:"id" xml:"id" form:"id" gorm:"primaryKey;size:36"` // JWT ID (jti)
+	UserID    string    `json:"user_id" xml:"user_id" form:"user_id" gorm:"index;size:36"`
+	ExpiresAt time.Time `json:"expires_at" xml:"expires_at" form:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at" xml:"created_at" form:"created_at" gorm:"autoCreateTime"`
+}
+
+// UserTokensRevocation invalidates all the access tokens of a user issued before RevokedAt.
+type UserTokensRevocation struct {
+	UserID    string    `json:"user_id" xml:"user_id" form:"user_id" gorm:"primaryKey;size:36"`
+	RevokedAt time.Time `json:"revoked_at" xml:"revoked_at" form:"revoked_at"`
+}
+
+// One-time token purposes
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+// OneTimeToken represents a single use token sent to a user (Ex.: password reset).
+// Only the SHA-256 hash of the token is stored.
+type OneTimeToken struct {
+	ID        string     `json:"id" xml:"id" form:"id" gorm:"primaryKey;size:36"`
+	UserID    string     `json:"user_id" xml:"user_id" form:"user_id" gorm:"index;size:36"`
+	Purpose   string     `json:"purpose" xml:"purpose" form:"purpose" gorm:"size:31"`
+	Hash      string     `json:"-" xml:"-" form:"-" gorm:"uniqueIndex;size:64"`
+	ExpiresAt time.Time  `json:"expires_at" xml:"expires_at" form:"expires_at"`
+	UsedAt    *time.Time `json:"used_at" xml:"used_at" form:"used_at"`
+	CreatedAt time.Time  `json:"created_at" xml:"created_at" form:"created_at" gorm:"autoCreateTime"`
+}
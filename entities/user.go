@@ -8,20 +8,82 @@ import (
 
 // User represents a user in database.
 type User struct {
-	ID        string         `json:"id" xml:"id" form:"id" gorm:"primaryKey" validate:"required,uuid"`
-	Username  string         `json:"username" xml:"username" form:"username" gorm:"unique;size:127" validate:"required,email"`
-	Password  string         `json:"-" xml:"-" form:"password" gorm:"index;size=128" validate:"required,min=8"` // SHA512
-	Lastname  string         `json:"lastname" xml:"lastname" form:"lastname" gorm:"size=63" validate:"required"`
-	Firstname string         `json:"firstname" xml:"firstname" form:"firstname" gorm:"size=63" validate:"required"`
-	CreatedAt time.Time      `json:"created_at" xml:"created_at" form:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt time.Time      `json:"updated_at" xml:"updated_at" form:"updated_at" gorm:"autoUpdateTime"`
-	DeletedAt gorm.DeletedAt `json:"-" xml:"-" form:"deleted_at" gorm:"index"`
+	ID         string         `json:"id" xml:"id" form:"id" gorm:"primaryKey" validate:"required,uuid"`
+	Username   string         `json:"username" xml:"username" form:"username" gorm:"unique;size:127" validate:"required,email"`
+	Password   string         `json:"-" xml:"-" form:"password" gorm:"index;size:255" validate:"required,min=8"` // PHC string (argon2id or bcrypt)
+	Lastname   string         `json:"lastname" xml:"lastname" form:"lastname" gorm:"size=63" validate:"required"`
+	Firstname  string         `json:"firstname" xml:"firstname" form:"firstname" gorm:"size=63" validate:"required"`
+	VerifiedAt *time.Time     `json:"verified_at" xml:"verified_at" form:"verified_at"`
+	Version    uint           `json:"version" xml:"version" form:"version" gorm:"not null;default:1"` // Incremented by each change, used in ETags
+	CreatedAt  time.Time      `json:"created_at" xml:"created_at" form:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time      `json:"updated_at" xml:"updated_at" form:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt  gorm.DeletedAt `json:"-" xml:"-" form:"deleted_at" gorm:"index"`
+	Roles      []Role         `json:"roles,omitempty" xml:"roles,omitempty" form:"roles" gorm:"many2many:user_roles"`
+}
+
+// PasswordHistory represents a previous password hash of a user, to prevent its reuse.
+type PasswordHistory struct {
+	ID        string    `json:"-" xml:"-" form:"-" gorm:"primaryKey;size:36"`
+	UserID    string    `json:"-" xml:"-" form:"-" gorm:"index;size:36"`
+	Hash      string    `json:"-" xml:"-" form:"-" gorm:"size:255"`
+	CreatedAt time.Time `json:"-" xml:"-" form:"-" gorm:"autoCreateTime"`
 }
 
 // UserForm is used to create or update a user.
 type UserForm struct {
 	Username  string `json:"username" xml:"username" form:"username" validate:"required,email"`
-	Password  string `json:"password" xml:"password" form:"password" validate:"required,min=8"`
+	Password  string `json:"password" xml:"password" form:"password" validate:"required"` // Checked by the password policy
 	Lastname  string `json:"lastname" xml:"lastname" form:"lastname" validate:"required"`
 	Firstname string `json:"firstname" xml:"firstname" form:"firstname" validate:"required"`
 }
+
+// Patch returns the partial update changing all the form fields.
+func (f UserForm) Patch() *UserPatch {
+	return &UserPatch{
+		Username:  &f.Username,
+		Password:  &f.Password,
+		Lastname:  &f.Lastname,
+		Firstname: &f.Firstname,
+	}
+}
+
+// UserPatch is a partial update of a user, only the non nil fields are changed.
+type UserPatch struct {
+	Username  *string `json:"username,omitempty" xml:"username,omitempty" form:"username" validate:"omitempty,email"`
+	Password  *string `json:"password,omitempty" xml:"password,omitempty" form:"password"` // Checked by the password policy
+	Lastname  *string `json:"lastname,omitempty" xml:"lastname,omitempty" form:"lastname" validate:"omitempty,min=1"`
+	Firstname *string `json:"firstname,omitempty" xml:"firstname,omitempty" form:"firstname" validate:"omitempty,min=1"`
+}
+
+// PasswordForgotForm is used to request a password reset.
+type PasswordForgotForm struct {
+	Username string `json:"username" xml:"username" form:"username" validate:"required,email"`
+}
+
+// VerificationResendForm is used to request a new email address verification.
+type VerificationResendForm struct {
+	Username string `json:"username" xml:"username" form:"username" validate:"required,email"`
+}
+
+// PasswordResetForm is used to reset a password with a token.
+type PasswordResetForm struct {
+	Token    string `json:"token" xml:"token" form:"token" validate:"required"`
+	Password string `json:"password" xml:"password" form:"password" validate:"required"` // Checked by the password policy
+}
+
+// AuditSecretFields lists the user fields redacted in the audit trail.
+var AuditSecretFields = []string{"password"}
+
+// AuditFields returns the user fields recorded in the audit trail.
+func (u User) AuditFields() map[string]interface{} {
+	fields := map[string]interface{}{
+		"username":  u.Username,
+		"password":  u.Password,
+		"lastname":  u.Lastname,
+		"firstname": u.Firstname,
+	}
+	if u.VerifiedAt != nil {
+		fields["verified_at"] = u.VerifiedAt.Format(time.RFC3339)
+	}
+	return fields
+}
@@ -3,10 +3,12 @@ package server
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path"
 	"time"
 
+	"github.com/fabienbellanger/echo-boilerplate/entities"
 	"github.com/fabienbellanger/goutils"
 	"github.com/labstack/echo/v4"
 	"github.com/spf13/viper"
@@ -134,6 +136,7 @@ func zapLogger(log *zap.Logger) echo.MiddlewareFunc {
 				id = res.Header().Get(echo.HeaderXRequestID)
 				fields = append(fields, zap.String("requestId", id))
 			}
+			fields = append(fields, impersonationFields(c)...)
 
 			log.Info("", fields...)
 
@@ -141,3 +144,21 @@ func zapLogger(log *zap.Logger) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// impersonationFields flags the mutating requests made while an admin impersonates a user.
+func impersonationFields(c echo.Context) []zapcore.Field {
+	switch c.Request().Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+
+	claims, err := entities.ClaimsFromToken(c.Get("user"))
+	if err != nil || !claims.IsImpersonation() {
+		return nil
+	}
+	return []zapcore.Field{
+		zap.Bool("impersonation", true),
+		zap.String("userId", claims.UserID),
+		zap.String("actorId", claims.Actor.UserID),
+	}
+}
@@ -1,8 +1,13 @@
 package server
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap/zapcore"
 )
@@ -67,3 +72,22 @@ func TestLogOutputsWithMoreThanOneOutput(t *testing.T) {
 	assert.Equal(t, []string{"/tmp/go-url-shortener.log", "stdout"}, gottenOutputs, "with stdout")
 	assert.Nil(t, err)
 }
+
+func TestImpersonationFields(t *testing.T) {
+	e := echo.New()
+	newContext := func(method string, claims *entities.Claims) echo.Context {
+		c := e.NewContext(httptest.NewRequest(method, "/", nil), httptest.NewRecorder())
+		if claims != nil {
+			c.Set("user", &jwt.Token{Claims: claims, Valid: true})
+		}
+		return c
+	}
+
+	claims := entities.NewClaims("user-id", "user@test.com", "Doe", "John", 10)
+	assert.Empty(t, impersonationFields(newContext(http.MethodPost, nil)))
+	assert.Empty(t, impersonationFields(newContext(http.MethodPost, claims)))
+
+	claims.Actor = &entities.Actor{UserID: "admin-id"}
+	assert.Empty(t, impersonationFields(newContext(http.MethodGet, claims)))
+	assert.Len(t, impersonationFields(newContext(http.MethodDelete, claims)), 3)
+}
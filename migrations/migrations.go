@@ -0,0 +1,14 @@
+// Package migrations embeds the versioned SQL migrations of each database driver.
+//
+// Migrations are stored in a directory per driver (mysql, postgres, sqlite)
+// and named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// the version being the UTC creation timestamp (Ex.: 20221018092139_init.up.sql).
+// Statements are separated by a semicolon at the end of a line.
+package migrations
+
+import "embed"
+
+// FS contains the migrations of all the drivers.
+//
+//go:embed */*.sql
+var FS embed.FS
@@ -0,0 +1,21 @@
+-- Initial schema, as previously created by GORM automigrations.
+-- IF NOT EXISTS allows to apply it on a database automigrated by a release without migrations.
+-- The following migrations are not idempotent: a database automigrated since cannot be migrated.
+
+CREATE TABLE IF NOT EXISTS `users` (`id` varchar(191),`username` varchar(127) UNIQUE,`password` varchar(255),`lastname` longtext,`firstname` longtext,`verified_at` datetime(3) NULL,`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,`deleted_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_users_password` (`password`),INDEX `idx_users_deleted_at` (`deleted_at`))ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `user_roles` (`user_id` varchar(191),`role_id` bigint unsigned,PRIMARY KEY (`user_id`,`role_id`))ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `roles` (`id` bigint unsigned AUTO_INCREMENT,`name` varchar(63) UNIQUE,`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`))ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `role_permissions` (`role_id` bigint unsigned,`permission_id` bigint unsigned,PRIMARY KEY (`role_id`,`permission_id`))ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `permissions` (`id` bigint unsigned AUTO_INCREMENT,`name` varchar(63) UNIQUE,PRIMARY KEY (`id`))ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `refresh_tokens` (`id` varchar(36),`user_id` varchar(36),`family_id` varchar(36),`hash` varchar(64),`expires_at` datetime(3) NULL,`used_at` datetime(3) NULL,`revoked_at` datetime(3) NULL,`created_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_refresh_tokens_user_id` (`user_id`),INDEX `idx_refresh_tokens_family_id` (`family_id`),UNIQUE INDEX `idx_refresh_tokens_hash` (`hash`))ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `revoked_tokens` (`id` varchar(36),`user_id` varchar(36),`expires_at` datetime(3) NULL,`created_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_revoked_tokens_user_id` (`user_id`),INDEX `idx_revoked_tokens_expires_at` (`expires_at`))ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `user_tokens_revocations` (`user_id` varchar(36),`revoked_at` datetime(3) NULL,PRIMARY KEY (`user_id`))ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `one_time_tokens` (`id` varchar(36),`user_id` varchar(36),`purpose` varchar(31),`hash` varchar(64),`expires_at` datetime(3) NULL,`used_at` datetime(3) NULL,`created_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_one_time_tokens_user_id` (`user_id`),UNIQUE INDEX `idx_one_time_tokens_hash` (`hash`))ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `totp_factors` (`user_id` varchar(36),`secret` varchar(63),`last_used_step` bigint,`confirmed_at` datetime(3) NULL,`created_at` datetime(3) NULL,PRIMARY KEY (`user_id`))ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `recovery_codes` (`id` varchar(36),`user_id` varchar(36),`hash` varchar(64),`used_at` datetime(3) NULL,`created_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_recovery_codes_user_id` (`user_id`))ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `login_throttles` (`identifier` varchar(191),`failures` bigint,`last_failed_at` datetime(3) NULL,`blocked_until` datetime(3) NULL,`locked` boolean,PRIMARY KEY (`identifier`))ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `api_keys` (`id` varchar(36),`user_id` varchar(36),`name` varchar(127),`prefix` varchar(15),`hash` varchar(64),`scopes` text,`expires_at` datetime(3) NULL,`last_used_at` datetime(3) NULL,`revoked_at` datetime(3) NULL,`created_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_api_keys_user_id` (`user_id`),UNIQUE INDEX `idx_api_keys_hash` (`hash`))ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `user_identities` (`id` varchar(36),`user_id` varchar(36),`provider` varchar(63),`subject` varchar(255),`email` varchar(127),`created_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_user_identities_user_id` (`user_id`),UNIQUE INDEX `idx_provider_subject` (`provider`,`subject`))ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `audit_logs` (`id` varchar(36),`actor_id` varchar(36),`on_behalf_of` varchar(36),`action` varchar(31),`target_type` varchar(63),`target_id` varchar(36),`changes` text,`ip` varchar(45),`request_id` varchar(36),`created_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_audit_logs_created_at` (`created_at`),INDEX `idx_audit_logs_actor_id` (`actor_id`),INDEX `idx_audit_logs_target_id` (`target_id`))ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `sessions` (`id` varchar(36),`user_id` varchar(36),`user_agent` varchar(255),`ip` varchar(45),`created_at` datetime(3) NULL,`last_seen_at` datetime(3) NULL,`expires_at` datetime(3) NULL,`terminated_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_sessions_expires_at` (`expires_at`),INDEX `idx_sessions_user_id` (`user_id`))ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `password_histories` (`id` varchar(36),`user_id` varchar(36),`hash` varchar(255),`created_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_password_histories_user_id` (`user_id`))ENGINE=InnoDB;
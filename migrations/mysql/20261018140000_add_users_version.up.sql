@@ -0,0 +1,3 @@
+-- Version of the users, incremented by each change (optimistic concurrency control).
+
+ALTER TABLE `users` ADD COLUMN `version` bigint unsigned NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+-- Rollback of backfill_users_verified_at
+-- Backfilled users cannot be told apart from verified users: nothing is rolled back.
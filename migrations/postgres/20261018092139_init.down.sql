@@ -0,0 +1,19 @@
+-- Drops the whole schema.
+
+DROP TABLE IF EXISTS "password_histories";
+DROP TABLE IF EXISTS "sessions";
+DROP TABLE IF EXISTS "audit_logs";
+DROP TABLE IF EXISTS "user_identities";
+DROP TABLE IF EXISTS "api_keys";
+DROP TABLE IF EXISTS "login_throttles";
+DROP TABLE IF EXISTS "recovery_codes";
+DROP TABLE IF EXISTS "totp_factors";
+DROP TABLE IF EXISTS "one_time_tokens";
+DROP TABLE IF EXISTS "user_tokens_revocations";
+DROP TABLE IF EXISTS "revoked_tokens";
+DROP TABLE IF EXISTS "refresh_tokens";
+DROP TABLE IF EXISTS "permissions";
+DROP TABLE IF EXISTS "role_permissions";
+DROP TABLE IF EXISTS "roles";
+DROP TABLE IF EXISTS "user_roles";
+DROP TABLE IF EXISTS "users";
@@ -0,0 +1,41 @@
+-- Initial schema, as previously created by GORM automigrations.
+-- IF NOT EXISTS allows to apply it on a database automigrated by a release without migrations.
+-- The following migrations are not idempotent: a database automigrated since cannot be migrated.
+
+CREATE TABLE IF NOT EXISTS "users" ("id" text,"username" varchar(127) UNIQUE,"password" varchar(255),"lastname" text,"firstname" text,"verified_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_users_password" ON "users" ("password");
+CREATE TABLE IF NOT EXISTS "user_roles" ("user_id" text,"role_id" bigint,PRIMARY KEY ("user_id","role_id"));
+CREATE TABLE IF NOT EXISTS "roles" ("id" bigserial,"name" varchar(63) UNIQUE,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE TABLE IF NOT EXISTS "role_permissions" ("role_id" bigint,"permission_id" bigint,PRIMARY KEY ("role_id","permission_id"));
+CREATE TABLE IF NOT EXISTS "permissions" ("id" bigserial,"name" varchar(63) UNIQUE,PRIMARY KEY ("id"));
+CREATE TABLE IF NOT EXISTS "refresh_tokens" ("id" varchar(36),"user_id" varchar(36),"family_id" varchar(36),"hash" varchar(64),"expires_at" timestamptz,"used_at" timestamptz,"revoked_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_family_id" ON "refresh_tokens" ("family_id");
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_user_id" ON "refresh_tokens" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_refresh_tokens_hash" ON "refresh_tokens" ("hash");
+CREATE TABLE IF NOT EXISTS "revoked_tokens" ("id" varchar(36),"user_id" varchar(36),"expires_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_revoked_tokens_expires_at" ON "revoked_tokens" ("expires_at");
+CREATE INDEX IF NOT EXISTS "idx_revoked_tokens_user_id" ON "revoked_tokens" ("user_id");
+CREATE TABLE IF NOT EXISTS "user_tokens_revocations" ("user_id" varchar(36),"revoked_at" timestamptz,PRIMARY KEY ("user_id"));
+CREATE TABLE IF NOT EXISTS "one_time_tokens" ("id" varchar(36),"user_id" varchar(36),"purpose" varchar(31),"hash" varchar(64),"expires_at" timestamptz,"used_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_one_time_tokens_user_id" ON "one_time_tokens" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_one_time_tokens_hash" ON "one_time_tokens" ("hash");
+CREATE TABLE IF NOT EXISTS "totp_factors" ("user_id" varchar(36),"secret" varchar(63),"last_used_step" bigint,"confirmed_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("user_id"));
+CREATE TABLE IF NOT EXISTS "recovery_codes" ("id" varchar(36),"user_id" varchar(36),"hash" varchar(64),"used_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+CREATE TABLE IF NOT EXISTS "login_throttles" ("identifier" varchar(191),"failures" bigint,"last_failed_at" timestamptz,"blocked_until" timestamptz,"locked" boolean,PRIMARY KEY ("identifier"));
+CREATE TABLE IF NOT EXISTS "api_keys" ("id" varchar(36),"user_id" varchar(36),"name" varchar(127),"prefix" varchar(15),"hash" varchar(64),"scopes" text,"expires_at" timestamptz,"last_used_at" timestamptz,"revoked_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_api_keys_user_id" ON "api_keys" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_api_keys_hash" ON "api_keys" ("hash");
+CREATE TABLE IF NOT EXISTS "user_identities" ("id" varchar(36),"user_id" varchar(36),"provider" varchar(63),"subject" varchar(255),"email" varchar(127),"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_provider_subject" ON "user_identities" ("provider","subject");
+CREATE INDEX IF NOT EXISTS "idx_user_identities_user_id" ON "user_identities" ("user_id");
+CREATE TABLE IF NOT EXISTS "audit_logs" ("id" varchar(36),"actor_id" varchar(36),"on_behalf_of" varchar(36),"action" varchar(31),"target_type" varchar(63),"target_id" varchar(36),"changes" text,"ip" varchar(45),"request_id" varchar(36),"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_created_at" ON "audit_logs" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_target_id" ON "audit_logs" ("target_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_actor_id" ON "audit_logs" ("actor_id");
+CREATE TABLE IF NOT EXISTS "sessions" ("id" varchar(36),"user_id" varchar(36),"user_agent" varchar(255),"ip" varchar(45),"created_at" timestamptz,"last_seen_at" timestamptz,"expires_at" timestamptz,"terminated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_sessions_expires_at" ON "sessions" ("expires_at");
+CREATE INDEX IF NOT EXISTS "idx_sessions_user_id" ON "sessions" ("user_id");
+CREATE TABLE IF NOT EXISTS "password_histories" ("id" varchar(36),"user_id" varchar(36),"hash" varchar(255),"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_password_histories_user_id" ON "password_histories" ("user_id");
@@ -0,0 +1,3 @@
+-- Rollback of add_users_version
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "version";
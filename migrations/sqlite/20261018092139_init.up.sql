@@ -0,0 +1,41 @@
+-- Initial schema, as previously created by GORM automigrations.
+-- IF NOT EXISTS allows to apply it on a database automigrated by a release without migrations.
+-- The following migrations are not idempotent: a database automigrated since cannot be migrated.
+
+CREATE TABLE IF NOT EXISTS `users` (`id` text,`username` text UNIQUE,`password` text,`lastname` text,`firstname` text,`verified_at` datetime,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,PRIMARY KEY (`id`));
+CREATE INDEX IF NOT EXISTS `idx_users_deleted_at` ON `users`(`deleted_at`);
+CREATE INDEX IF NOT EXISTS `idx_users_password` ON `users`(`password`);
+CREATE TABLE IF NOT EXISTS `user_roles` (`user_id` text,`role_id` integer,PRIMARY KEY (`user_id`,`role_id`));
+CREATE TABLE IF NOT EXISTS `roles` (`id` integer,`name` text UNIQUE,`created_at` datetime,`updated_at` datetime,PRIMARY KEY (`id`));
+CREATE TABLE IF NOT EXISTS `role_permissions` (`role_id` integer,`permission_id` integer,PRIMARY KEY (`role_id`,`permission_id`));
+CREATE TABLE IF NOT EXISTS `permissions` (`id` integer,`name` text UNIQUE,PRIMARY KEY (`id`));
+CREATE TABLE IF NOT EXISTS `refresh_tokens` (`id` text,`user_id` text,`family_id` text,`hash` text,`expires_at` datetime,`used_at` datetime,`revoked_at` datetime,`created_at` datetime,PRIMARY KEY (`id`));
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_refresh_tokens_hash` ON `refresh_tokens`(`hash`);
+CREATE INDEX IF NOT EXISTS `idx_refresh_tokens_family_id` ON `refresh_tokens`(`family_id`);
+CREATE INDEX IF NOT EXISTS `idx_refresh_tokens_user_id` ON `refresh_tokens`(`user_id`);
+CREATE TABLE IF NOT EXISTS `revoked_tokens` (`id` text,`user_id` text,`expires_at` datetime,`created_at` datetime,PRIMARY KEY (`id`));
+CREATE INDEX IF NOT EXISTS `idx_revoked_tokens_expires_at` ON `revoked_tokens`(`expires_at`);
+CREATE INDEX IF NOT EXISTS `idx_revoked_tokens_user_id` ON `revoked_tokens`(`user_id`);
+CREATE TABLE IF NOT EXISTS `user_tokens_revocations` (`user_id` text,`revoked_at` datetime,PRIMARY KEY (`user_id`));
+CREATE TABLE IF NOT EXISTS `one_time_tokens` (`id` text,`user_id` text,`purpose` text,`hash` text,`expires_at` datetime,`used_at` datetime,`created_at` datetime,PRIMARY KEY (`id`));
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_one_time_tokens_hash` ON `one_time_tokens`(`hash`);
+CREATE INDEX IF NOT EXISTS `idx_one_time_tokens_user_id` ON `one_time_tokens`(`user_id`);
+CREATE TABLE IF NOT EXISTS `totp_factors` (`user_id` text,`secret` text,`last_used_step` integer,`confirmed_at` datetime,`created_at` datetime,PRIMARY KEY (`user_id`));
+CREATE TABLE IF NOT EXISTS `recovery_codes` (`id` text,`user_id` text,`hash` text,`used_at` datetime,`created_at` datetime,PRIMARY KEY (`id`));
+CREATE INDEX IF NOT EXISTS `idx_recovery_codes_user_id` ON `recovery_codes`(`user_id`);
+CREATE TABLE IF NOT EXISTS `login_throttles` (`identifier` text,`failures` integer,`last_failed_at` datetime,`blocked_until` datetime,`locked` numeric,PRIMARY KEY (`identifier`));
+CREATE TABLE IF NOT EXISTS `api_keys` (`id` text,`user_id` text,`name` text,`prefix` text,`hash` text,`scopes` text,`expires_at` datetime,`last_used_at` datetime,`revoked_at` datetime,`created_at` datetime,PRIMARY KEY (`id`));
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_api_keys_hash` ON `api_keys`(`hash`);
+CREATE INDEX IF NOT EXISTS `idx_api_keys_user_id` ON `api_keys`(`user_id`);
+CREATE TABLE IF NOT EXISTS `user_identities` (`id` text,`user_id` text,`provider` text,`subject` text,`email` text,`created_at` datetime,PRIMARY KEY (`id`));
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_provider_subject` ON `user_identities`(`provider`,`subject`);
+CREATE INDEX IF NOT EXISTS `idx_user_identities_user_id` ON `user_identities`(`user_id`);
+CREATE TABLE IF NOT EXISTS `audit_logs` (`id` text,`actor_id` text,`on_behalf_of` text,`action` text,`target_type` text,`target_id` text,`changes` text,`ip` text,`request_id` text,`created_at` datetime,PRIMARY KEY (`id`));
+CREATE INDEX IF NOT EXISTS `idx_audit_logs_created_at` ON `audit_logs`(`created_at`);
+CREATE INDEX IF NOT EXISTS `idx_audit_logs_target_id` ON `audit_logs`(`target_id`);
+CREATE INDEX IF NOT EXISTS `idx_audit_logs_actor_id` ON `audit_logs`(`actor_id`);
+CREATE TABLE IF NOT EXISTS `sessions` (`id` text,`user_id` text,`user_agent` text,`ip` text,`created_at` datetime,`last_seen_at` datetime,`expires_at` datetime,`terminated_at` datetime,PRIMARY KEY (`id`));
+CREATE INDEX IF NOT EXISTS `idx_sessions_expires_at` ON `sessions`(`expires_at`);
+CREATE INDEX IF NOT EXISTS `idx_sessions_user_id` ON `sessions`(`user_id`);
+CREATE TABLE IF NOT EXISTS `password_histories` (`id` text,`user_id` text,`hash` text,`created_at` datetime,PRIMARY KEY (`id`));
+CREATE INDEX IF NOT EXISTS `idx_password_histories_user_id` ON `password_histories`(`user_id`);
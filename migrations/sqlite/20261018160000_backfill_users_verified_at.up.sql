@@ -0,0 +1,7 @@
+-- Users created before email verification are considered verified,
+-- so that they are not locked out when verification is required.
+-- Users having received a verification email are left unverified.
+
+UPDATE `users` SET `verified_at` = `created_at`
+WHERE `verified_at` IS NULL
+AND `id` NOT IN (SELECT `user_id` FROM `one_time_tokens` WHERE `purpose` = 'email_verification');
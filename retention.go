@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"go.uber.org/zap"
+)
+
+// retentionActor is the actor of the purges in the audit trail.
+const retentionActor = "system:retention"
+
+// startUsersRetention permanently deletes, every interval, the users soft deleted for longer than retention.
+// Nothing is done if retention is not positive. The job stops when the context is done.
+func startUsersRetention(ctx context.Context, userStore store.UserStorer, retention, interval time.Duration, logger *zap.Logger) {
+	if retention <= 0 {
+		return
+	}
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	go usersRetention(ctx, userStore, retention, interval, logger)
+}
+
+// usersRetention purges the deleted users every interval until the context is done.
+func usersRetention(ctx context.Context, userStore store.UserStorer, retention, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purgeDeletedUsers(userStore.WithContext(ctx), time.Now().Add(-retention), logger)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeDeletedUsers permanently deletes the users soft deleted before a date and logs the result.
+func purgeDeletedUsers(userStore store.UserStorer, before time.Time, logger *zap.Logger) {
+	n, err := userStore.WithAudit(entities.AuditMeta{ActorID: retentionActor}).PurgeDeletedUsers(before)
+	if err != nil {
+		logger.Error("Error when purging deleted users", zap.Error(err), zap.Int64("purged", n))
+		return
+	}
+	if n > 0 {
+		logger.Info("Deleted users purged", zap.Int64("purged", n), zap.Time("deletedBefore", before))
+	}
+}
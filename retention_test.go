@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	storeUser "github.com/fabienbellanger/echo-boilerplate/store/user"
+	"github.com/fabienbellanger/echo-boilerplate/utils/password"
+	"github.com/fabienbellanger/echo-boilerplate/utils/query"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	m := storeUser.NewMemory(password.NewBcrypt(4), storeUser.Options{})
+	s := m.WithContext(context.Background())
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	assert.Nil(t, s.Register(&user))
+	assert.Nil(t, s.DeleteUser(user.ID))
+	params := query.Params{Page: 1, Limit: 10, Sort: []query.Sort{{Field: "id"}}}
+
+	purgeDeletedUsers(s, time.Now().Add(-time.Hour), zap.NewNop())
+	_, total, err := s.GetDeletedUsers(params)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+
+	purgeDeletedUsers(s, time.Now().Add(time.Hour), zap.NewNop())
+	_, total, err = s.GetDeletedUsers(params)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), total)
+
+	logs := m.AuditLogs()
+	if assert.Len(t, logs, 1) {
+		assert.Equal(t, entities.AuditActionPurge, logs[0].Action)
+		assert.Equal(t, "system:retention", logs[0].ActorID)
+		assert.Equal(t, user.ID, logs[0].TargetID)
+	}
+}
+
+func TestUsersRetentionStops(t *testing.T) {
+	s := storeUser.NewMemory(password.NewBcrypt(4), storeUser.Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		usersRetention(ctx, s, time.Hour, time.Millisecond, zap.NewNop())
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the retention job must stop when the context is done")
+	}
+}
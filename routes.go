@@ -1,70 +1,364 @@
 package server
 
 import (
+	"context"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/delivery/apikey"
+	"github.com/fabienbellanger/echo-boilerplate/delivery/audit"
+	"github.com/fabienbellanger/echo-boilerplate/delivery/auth"
+	"github.com/fabienbellanger/echo-boilerplate/delivery/middlewares"
+	"github.com/fabienbellanger/echo-boilerplate/delivery/role"
 	"github.com/fabienbellanger/echo-boilerplate/delivery/user"
 	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	storeAPIKey "github.com/fabienbellanger/echo-boilerplate/store/apikey"
+	storeAudit "github.com/fabienbellanger/echo-boilerplate/store/audit"
+	storeIdentity "github.com/fabienbellanger/echo-boilerplate/store/identity"
+	storeMFA "github.com/fabienbellanger/echo-boilerplate/store/mfa"
+	storeRole "github.com/fabienbellanger/echo-boilerplate/store/role"
+	storeSession "github.com/fabienbellanger/echo-boilerplate/store/session"
+	storeThrottle "github.com/fabienbellanger/echo-boilerplate/store/throttle"
+	storeToken "github.com/fabienbellanger/echo-boilerplate/store/token"
 	storeUser "github.com/fabienbellanger/echo-boilerplate/store/user"
+	"github.com/fabienbellanger/echo-boilerplate/utils/jwk"
+	"github.com/fabienbellanger/echo-boilerplate/utils/mailer"
+	"github.com/fabienbellanger/echo-boilerplate/utils/oidc"
+	"github.com/fabienbellanger/echo-boilerplate/utils/password"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
-// Routes construct all server routes
-func Routes(e *echo.Echo, db *db.DB, logger *zap.Logger) {
-	webRoutes(e, logger)
-	apiRoutes(e, db, logger)
+// Routes construct all server routes.
+// Background jobs are stopped when the context is done.
+func Routes(ctx context.Context, e *echo.Echo, db *db.DB, logger *zap.Logger) error {
+	// JWT keys
+	// --------
+	keySet, err := jwk.New(jwk.Config{
+		Algorithm:       viper.GetString("JWT_ALGO"),
+		Secret:          viper.GetString("JWT_SECRET"),
+		PrivateKeyFiles: viper.GetStringSlice("JWT_PRIVATE_KEYS"),
+		PublicKeyFiles:  viper.GetStringSlice("JWT_PUBLIC_KEYS"),
+	})
+	if err != nil {
+		return err
+	}
+
+	webRoutes(e, logger, keySet)
+	return apiRoutes(ctx, e, db, logger, keySet)
 }
 
-// Initialize route protection with JWT
-func initJWT(g *echo.Group) {
+// Initialize route protection with JWT or API key
+func initJWT(g *echo.Group, keySet *jwk.KeySet, revocationStore store.TokenRevocationStorer, sessionStore store.SessionStorer, apiKeyAuth echo.MiddlewareFunc) {
 	// Protected routes
 	// ----------------
+	g.Use(apiKeyAuth)
+
 	jwtConfig := middleware.JWTConfig{
-		ContextKey:    "user",
-		TokenLookup:   "header:" + echo.HeaderAuthorization,
-		AuthScheme:    "Bearer",
-		SigningMethod: viper.GetString("JWT_ALGO"),
-		Claims:        &entities.Claims{},
-		SigningKey:    []byte(viper.GetString("JWT_SECRET")),
+		Skipper:     middlewares.IsAPIKeyRequest,
+		ContextKey:  "user",
+		TokenLookup: "header:" + echo.HeaderAuthorization,
+		AuthScheme:  "Bearer",
+		Claims:      &entities.Claims{},
+		KeyFunc:     keySet.KeyFunc,
 	}
 	g.Use(middleware.JWTWithConfig(jwtConfig))
+	g.Use(jwtRevocation(revocationStore))
+	g.Use(jwtSession(sessionStore))
+}
+
+// jwtRevocation rejects tokens which have been revoked.
+// It must be used after the JWT middleware. API keys have their own revocation.
+func jwtRevocation(revocationStore store.TokenRevocationStorer) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if middlewares.IsAPIKeyRequest(c) {
+				return next(c)
+			}
+
+			claims, err := entities.ClaimsFromToken(c.Get("user"))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+
+			revoked, err := revocationStore.IsRevoked(claims.Id, claims.UserID, claims.IssueTime())
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Error when checking token")
+			}
+			if revoked {
+				return echo.NewHTTPError(http.StatusUnauthorized, "revoked token")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// oidcProviders returns the OpenID Connect providers listed in OIDC_PROVIDERS.
+// Each provider is configured with the OIDC_<NAME>_* variables.
+func oidcProviders() (map[string]*oidc.Provider, error) {
+	providers := make(map[string]*oidc.Provider)
+	for _, name := range viper.GetStringSlice("OIDC_PROVIDERS") {
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		provider, err := oidc.New(oidc.Config{
+			Name:         name,
+			Issuer:       viper.GetString(prefix + "ISSUER"),
+			ClientID:     viper.GetString(prefix + "CLIENT_ID"),
+			ClientSecret: viper.GetString(prefix + "CLIENT_SECRET"),
+			RedirectURL:  viper.GetString(prefix + "REDIRECT_URL"),
+			Scopes:       viper.GetStringSlice(prefix + "SCOPES"),
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+		providers[name] = provider
+	}
+	return providers, nil
+}
+
+// jwtSession rejects tokens whose session has been terminated and records the session activity.
+// It must be used after the JWT middleware. Tokens without session are accepted.
+func jwtSession(sessionStore store.SessionStorer) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, err := entities.ClaimsFromToken(c.Get("user"))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+			if claims.SessionID == "" {
+				return next(c)
+			}
+
+			terminated, err := sessionStore.IsTerminated(claims.SessionID)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Error when checking session")
+			}
+			if terminated {
+				return echo.NewHTTPError(http.StatusUnauthorized, "terminated session")
+			}
+
+			if err := sessionStore.Seen(claims.SessionID, c.RealIP()); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Error when checking session")
+			}
+
+			return next(c)
+		}
+	}
 }
 
 // Web routes
-func webRoutes(e *echo.Echo, logger *zap.Logger) {
+func webRoutes(e *echo.Echo, logger *zap.Logger, keySet *jwk.KeySet) {
 	g := e.Group("")
 
 	g.GET("/health-check", func(c echo.Context) error {
 		// return echo.NewHTTPError(http.StatusUnauthorized, nil)
 		return c.String(http.StatusOK, "OK")
 	})
+
+	// Public keys used to verify JWT
+	g.GET("/.well-known/jwks.json", func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=300")
+		return c.JSON(http.StatusOK, keySet.JWKS())
+	})
+}
+
+// NewPasswordHasher returns the password hasher of the configuration.
+func NewPasswordHasher() (password.PasswordHasher, error) {
+	return password.New(password.Config{
+		Algorithm:         viper.GetString("PASSWORD_HASHER"),
+		BcryptCost:        viper.GetInt("PASSWORD_BCRYPT_COST"),
+		Argon2Memory:      viper.GetUint32("PASSWORD_ARGON2_MEMORY"),
+		Argon2Iterations:  viper.GetUint32("PASSWORD_ARGON2_ITERATIONS"),
+		Argon2Parallelism: uint8(viper.GetUint("PASSWORD_ARGON2_PARALLELISM")),
+	})
 }
 
 // Api routes
-func apiRoutes(e *echo.Echo, db *db.DB, logger *zap.Logger) {
+func apiRoutes(ctx context.Context, e *echo.Echo, db *db.DB, logger *zap.Logger, keySet *jwk.KeySet) error {
 	v1 := e.Group("/api/v1")
 
+	// Password hasher
+	// ---------------
+	hasher, err := NewPasswordHasher()
+	if err != nil {
+		return err
+	}
+
+	// Password policy
+	// ---------------
+	passwordPolicy := password.Policy{
+		MinLength:      viper.GetInt("PASSWORD_MIN_LENGTH"),
+		MaxLength:      viper.GetInt("PASSWORD_MAX_LENGTH"),
+		RequireLower:   viper.GetBool("PASSWORD_REQUIRE_LOWERCASE"),
+		RequireUpper:   viper.GetBool("PASSWORD_REQUIRE_UPPERCASE"),
+		RequireDigit:   viper.GetBool("PASSWORD_REQUIRE_DIGIT"),
+		RequireSpecial: viper.GetBool("PASSWORD_REQUIRE_SPECIAL"),
+		HistorySize:    viper.GetInt("PASSWORD_HISTORY"),
+	}
+	if path := viper.GetString("PASSWORD_BREACHED_LIST"); path != "" {
+		passwordPolicy.Breached, err = password.LoadBreachedList(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Mailer
+	// ------
+	mailer, err := mailer.New(mailer.Config{
+		Driver:   viper.GetString("MAILER_DRIVER"),
+		From:     viper.GetString("MAILER_FROM"),
+		Host:     viper.GetString("MAILER_SMTP_HOST"),
+		Port:     viper.GetInt("MAILER_SMTP_PORT"),
+		Username: viper.GetString("MAILER_SMTP_USERNAME"),
+		Password: viper.GetString("MAILER_SMTP_PASSWORD"),
+		Path:     viper.GetString("MAILER_FILE_PATH"),
+	}, logger)
+	if err != nil {
+		return err
+	}
+
+	// OpenID Connect providers
+	// ------------------------
+	providers, err := oidcProviders()
+	if err != nil {
+		return err
+	}
+
 	// Stores
 	// ------
-	userStore := storeUser.New(db)
+	userStore := storeUser.New(db, hasher, storeUser.Options{
+		RequireVerifiedEmail: viper.GetBool("AUTH_REQUIRE_VERIFIED_EMAIL"),
+		PasswordHistorySize:  passwordPolicy.HistorySize,
+		ReadTimeout:          time.Duration(viper.GetInt("DB_READ_TIMEOUT")) * time.Second,
+		WriteTimeout:         time.Duration(viper.GetInt("DB_WRITE_TIMEOUT")) * time.Second,
+		DefaultRole:          viper.GetString("USERS_DEFAULT_ROLE"),
+	})
+	tokenStore := storeToken.New(db)
+	revocationStore := storeToken.NewRevocationStore(db, viper.GetDuration("JWT_REVOCATION_REFRESH_INTERVAL")*time.Second)
+	roleStore := storeRole.New(db)
+	mfaStore := storeMFA.New(db)
+	oneTimeTokenStore := storeToken.NewOneTimeTokenStore(db)
+	apiKeyStore := storeAPIKey.New(db)
+	identityStore := storeIdentity.New(db)
+	auditStore := storeAudit.New(db)
+	sessionStore := storeSession.New(db, viper.GetDuration("JWT_REVOCATION_REFRESH_INTERVAL")*time.Second)
+	throttleStore := storeThrottle.New(db,
+		storeThrottle.Policy{
+			FreeAttempts:     viper.GetInt("LOGIN_FREE_ATTEMPTS"),
+			BackoffBase:      viper.GetDuration("LOGIN_BACKOFF_BASE") * time.Second,
+			BackoffMax:       viper.GetDuration("LOGIN_BACKOFF_MAX") * time.Second,
+			LockoutThreshold: viper.GetInt("LOGIN_LOCKOUT_THRESHOLD"),
+			LockoutDuration:  viper.GetDuration("LOGIN_LOCKOUT_DURATION") * time.Minute,
+			Window:           viper.GetDuration("LOGIN_ATTEMPTS_WINDOW") * time.Minute,
+		},
+		storeThrottle.Policy{
+			FreeAttempts: viper.GetInt("LOGIN_IP_FREE_ATTEMPTS"),
+			BackoffBase:  viper.GetDuration("LOGIN_BACKOFF_BASE") * time.Second,
+			BackoffMax:   viper.GetDuration("LOGIN_BACKOFF_MAX") * time.Second,
+			Window:       viper.GetDuration("LOGIN_ATTEMPTS_WINDOW") * time.Minute,
+		},
+	)
+	emailThrottleStore := throttleStore.WithScope(entities.ThrottleScopeEmail,
+		storeThrottle.Policy{
+			FreeAttempts: viper.GetInt("EMAIL_FREE_REQUESTS"),
+			BackoffBase:  viper.GetDuration("EMAIL_BACKOFF_BASE") * time.Second,
+			BackoffMax:   viper.GetDuration("EMAIL_BACKOFF_MAX") * time.Second,
+			Window:       viper.GetDuration("EMAIL_REQUESTS_WINDOW") * time.Minute,
+		},
+		storeThrottle.Policy{
+			FreeAttempts: viper.GetInt("EMAIL_IP_FREE_REQUESTS"),
+			BackoffBase:  viper.GetDuration("EMAIL_BACKOFF_BASE") * time.Second,
+			BackoffMax:   viper.GetDuration("EMAIL_BACKOFF_MAX") * time.Second,
+			Window:       viper.GetDuration("EMAIL_REQUESTS_WINDOW") * time.Minute,
+		},
+	)
+
+	if err := roleStore.InitDefaultRoles(); err != nil {
+		return err
+	}
+
+	// Soft deleted users retention
+	// ----------------------------
+	// The job must only be enabled on one instance
+	if viper.GetBool("USERS_RETENTION_JOB") {
+		startUsersRetention(ctx, userStore,
+			viper.GetDuration("USERS_RETENTION")*24*time.Hour,
+			viper.GetDuration("USERS_RETENTION_INTERVAL")*time.Hour,
+			logger,
+		)
+	}
 
 	// Public routes
 	// -------------
-	// TODO: Login => Improve
 	authGroup := v1.Group("")
-	auth := user.New(authGroup, userStore)
-	authGroup.POST("/login", auth.Login)
+	auth := auth.New(authGroup, auth.Dependencies{
+		UserStore:          userStore,
+		TokenStore:         tokenStore,
+		RevocationStore:    revocationStore,
+		RoleStore:          roleStore,
+		MFAStore:           mfaStore,
+		ThrottleStore:      throttleStore,
+		EmailThrottleStore: emailThrottleStore,
+		OneTimeTokenStore:  oneTimeTokenStore,
+		IdentityStore:      identityStore,
+		SessionStore:       sessionStore,
+		AuditStore:         auditStore,
+		PasswordPolicy:     passwordPolicy,
+		OIDCProviders:      providers,
+		KeySet:             keySet,
+		Mailer:             mailer,
+		Logger:             logger,
+	})
+	auth.Routes()
+
+	// User
+	userDeps := user.Dependencies{
+		UserStore:          userStore,
+		RevocationStore:    revocationStore,
+		OneTimeTokenStore:  oneTimeTokenStore,
+		EmailThrottleStore: emailThrottleStore,
+		PasswordPolicy:     passwordPolicy,
+		Mailer:             mailer,
+		Logger:             logger,
+		RequireIfMatch:     viper.GetBool("USERS_REQUIRE_IF_MATCH"),
+	}
+	publicUser := user.New(authGroup, userDeps)
+	publicUser.PublicRoutes()
 
 	// Protected routes
 	// ----------------
-	initJWT(v1)
+	initJWT(v1, keySet, revocationStore, sessionStore, middlewares.APIKeyAuth(apiKeyStore, userStore, roleStore))
+
+	// Auth
+	auth.ProtectedRoutes(v1)
+
+	// API keys
+	apiKeyRoutes := v1.Group("/me/api-keys")
+	apiKey := apikey.New(apiKeyRoutes, apiKeyStore, viper.GetDuration("API_KEY_MAX_LIFETIME")*24*time.Hour)
+	apiKey.Routes()
 
 	// User
 	userRoutes := v1.Group("/users")
-	user := user.New(userRoutes, userStore)
+	user := user.New(userRoutes, userDeps)
 	user.Routes()
+	auth.UserRoutes(userRoutes)
+
+	// Roles
+	roleRoutes := v1.Group("/roles")
+	role := role.New(roleRoutes, roleStore, userStore, revocationStore, auditStore)
+	role.Routes()
+	role.UserRoutes(userRoutes)
+
+	// Audit
+	auditRoutes := v1.Group("/audit")
+	audit := audit.New(auditRoutes, auditStore)
+	audit.Routes()
+
+	return nil
 }
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// sessionStoreStub is a store.SessionStorer whose sessions are listed in terminated.
+type sessionStoreStub struct {
+	terminated map[string]bool
+	seen       []string
+	err        error
+}
+
+func (s *sessionStoreStub) Create(*entities.Session) error { return nil }
+
+func (s *sessionStoreStub) GetUserSessions(string) ([]entities.Session, error) { return nil, nil }
+
+func (s *sessionStoreStub) Refresh(string, string, time.Time) error { return nil }
+
+func (s *sessionStoreStub) Seen(id, _ string) error {
+	s.seen = append(s.seen, id)
+	return nil
+}
+
+func (s *sessionStoreStub) Terminate(string, string) error { return nil }
+
+func (s *sessionStoreStub) IsTerminated(id string) (bool, error) {
+	return s.terminated[id], s.err
+}
+
+// serveWithSession serves a request authenticated with the claims through the jwtSession middleware.
+func serveWithSession(sessionStore *sessionStoreStub, claims *entities.Claims) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	}, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("user", &jwt.Token{Claims: claims, Valid: true})
+			return next(c)
+		}
+	}, jwtSession(sessionStore))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	return rec
+}
+
+func TestJWTSession(t *testing.T) {
+	s := &sessionStoreStub{terminated: map[string]bool{"terminated-id": true}}
+
+	rec := serveWithSession(s, &entities.Claims{UserID: "user-id", SessionID: "session-id"})
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, []string{"session-id"}, s.seen)
+
+	rec = serveWithSession(s, &entities.Claims{UserID: "user-id", SessionID: "terminated-id"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, []string{"session-id"}, s.seen)
+
+	// Tokens without session (Ex.: API keys)
+	rec = serveWithSession(s, &entities.Claims{UserID: "user-id"})
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	s.err = errors.New("database error")
+	rec = serveWithSession(s, &entities.Claims{UserID: "user-id", SessionID: "session-id"})
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
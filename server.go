@@ -1,13 +1,20 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/delivery/middlewares"
 	"github.com/fabienbellanger/echo-boilerplate/delivery/pprof"
 	"github.com/fabienbellanger/echo-boilerplate/utils"
+	"github.com/fabienbellanger/echo-boilerplate/utils/htpasswd"
 	"github.com/fabienbellanger/goutils"
 	"github.com/google/uuid"
 	"github.com/labstack/echo-contrib/prometheus"
@@ -18,16 +25,23 @@ import (
 	"golang.org/x/time/rate"
 )
 
-// Run web server
+// Run web server until an interrupt or a termination signal is received
 func Run(logger *zap.Logger, db *db.DB) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	e := echo.New()
 
 	initConfig(e)
-	initMiddlerwares(e, logger)
+	if err := initMiddlerwares(e, logger); err != nil {
+		e.Logger.Fatal(err)
+	}
 
 	// Routes
 	// ------
-	Routes(e, db, logger)
+	if err := Routes(ctx, e, db, logger); err != nil {
+		e.Logger.Fatal(err)
+	}
 
 	// Start server
 	// ------------
@@ -36,7 +50,20 @@ func Run(logger *zap.Logger, db *db.DB) {
 		// TODO: ReadTimeout:  time.Duration(viper.GetInt("server.readTimeout")) * time.Second,
 		// TODO: WriteTimeout: time.Duration(viper.GetInt("server.writeTimeout")) * time.Second,
 	}
-	e.Logger.Fatal(e.StartServer(s))
+	go func() {
+		if err := e.StartServer(s); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			e.Logger.Fatal(err)
+		}
+	}()
+
+	// Graceful shutdown
+	// -----------------
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		e.Logger.Fatal(err)
+	}
 }
 
 // Initialize server configuration
@@ -59,7 +86,7 @@ func initConfig(e *echo.Echo) {
 }
 
 // Initialize server middlewares
-func initMiddlerwares(e *echo.Echo, logger *zap.Logger) {
+func initMiddlerwares(e *echo.Echo, logger *zap.Logger) error {
 	// Recover
 	// -------
 	e.Use(middleware.Recover())
@@ -90,24 +117,24 @@ func initMiddlerwares(e *echo.Echo, logger *zap.Logger) {
 
 	// Basic Auth
 	// ----------
-	basicAuthConfig := middleware.BasicAuthConfig{
-		Validator: func(username, password string, c echo.Context) (bool, error) {
-			basicAuthUsername := viper.GetString("SERVER_BASICAUTH_USERNAME")
-			basicAuthPassword := viper.GetString("SERVER_BASICAUTH_PASSWORD")
-
-			if username == basicAuthUsername && password == basicAuthPassword {
-				return true, nil
-			}
-			return false, nil
-		},
+	validator, err := basicAuthValidator(logger)
+	if err != nil {
+		return err
 	}
 	protectedGroup := e.Group("/private")
-	protectedGroup.Use(middleware.BasicAuthWithConfig(basicAuthConfig))
+	protectedGroup.Use(middleware.BasicAuthWithConfig(middleware.BasicAuthConfig{
+		Validator: validator,
+	}))
 
 	// Pprof
 	// -----
-	if viper.GetBool("SERVER_PROMETHEUS") {
-		pprof := pprof.New(protectedGroup)
+	if viper.GetBool("SERVER_PPROF") {
+		allowlist, err := middlewares.IPAllowlist(viper.GetStringSlice("SERVER_PPROF_ALLOWED_IPS"), logger)
+		if err != nil {
+			return err
+		}
+		pprofGroup := protectedGroup.Group("", allowlist)
+		pprof := pprof.New(pprofGroup)
 		pprof.Routes()
 	}
 
@@ -154,6 +181,50 @@ func initMiddlerwares(e *echo.Echo, logger *zap.Logger) {
 	// Secure
 	// ------
 	e.Use(middleware.Secure())
+
+	return nil
+}
+
+// basicAuthValidator returns the validator of the /private basic authentication.
+// Operators are read from the SERVER_BASICAUTH_FILE htpasswd file (bcrypt only).
+// The cleartext SERVER_BASICAUTH_USERNAME and SERVER_BASICAUTH_PASSWORD are still accepted when no file is set.
+func basicAuthValidator(logger *zap.Logger) (middleware.BasicAuthValidator, error) {
+	var authenticate func(username, password string) bool
+
+	if path := viper.GetString("SERVER_BASICAUTH_FILE"); path != "" {
+		file, err := htpasswd.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		authenticate = file.Authenticate
+	} else {
+		basicAuthUsername := viper.GetString("SERVER_BASICAUTH_USERNAME")
+		basicAuthPassword := viper.GetString("SERVER_BASICAUTH_PASSWORD")
+		if basicAuthUsername != "" || basicAuthPassword != "" {
+			logger.Warn("Cleartext basic auth credentials are deprecated, use SERVER_BASICAUTH_FILE instead")
+		}
+
+		authenticate = func(username, password string) bool {
+			// Both comparisons are always made
+			usernameOk := htpasswd.Equal(username, basicAuthUsername)
+			passwordOk := htpasswd.Equal(password, basicAuthPassword)
+			return basicAuthUsername != "" && usernameOk && passwordOk
+		}
+	}
+
+	return func(username, password string, c echo.Context) (bool, error) {
+		if authenticate(username, password) {
+			return true, nil
+		}
+
+		logger.Warn("Basic auth failed",
+			zap.String("username", username),
+			zap.String("ip", c.RealIP()),
+			zap.String("path", c.Request().URL.Path),
+			zap.String("requestId", c.Response().Header().Get(echo.HeaderXRequestID)),
+		)
+		return false, nil
+	}, nil
 }
 
 // CustomHTTPErrorHandler
@@ -165,6 +236,19 @@ func customHTTPErrorHandler(err error, c echo.Context) {
 		msg = httpError.Message
 	}
 
+	// Database timeouts and canceled requests
+	// ---------------------------------------
+	// Only the user store runs its queries with the request context and the DB_READ_TIMEOUT
+	// and DB_WRITE_TIMEOUT limits, the handlers attach its errors with SetInternal.
+	// The queries of the other stores are neither bounded nor canceled.
+	if errors.Is(err, context.DeadlineExceeded) {
+		code = http.StatusGatewayTimeout
+		msg = "The request has timed out"
+	} else if errors.Is(err, context.Canceled) {
+		code = http.StatusServiceUnavailable
+		msg = "The request has been canceled"
+	}
+
 	switch code {
 	case http.StatusBadRequest:
 		// 400
@@ -172,13 +256,39 @@ func customHTTPErrorHandler(err error, c echo.Context) {
 	case http.StatusUnauthorized:
 		// 401
 		c.JSON(code, utils.HTTPError{Code: code, Message: "Unauthorized", Details: msg})
+	case http.StatusForbidden:
+		// 403
+		c.JSON(code, utils.HTTPError{Code: code, Message: "Forbidden", Details: msg})
 	case http.StatusNotFound:
 		// 404
 		c.JSON(code, utils.HTTPError{Code: code, Message: "Resource Not Found", Details: msg})
+	case http.StatusConflict:
+		// 409
+		c.JSON(code, utils.HTTPError{Code: code, Message: "Conflict", Details: msg})
+	case http.StatusPreconditionFailed:
+		// 412
+		c.JSON(code, utils.HTTPError{Code: code, Message: "Precondition Failed", Details: msg})
+	case http.StatusUnsupportedMediaType:
+		// 415
+		c.JSON(code, utils.HTTPError{Code: code, Message: "Unsupported Media Type", Details: msg})
+	case http.StatusPreconditionRequired:
+		// 428
+		c.JSON(code, utils.HTTPError{Code: code, Message: "Precondition Required", Details: msg})
+	case http.StatusTooManyRequests:
+		// 429
+		c.JSON(code, utils.HTTPError{Code: code, Message: "Too Many Requests", Details: msg})
 	case http.StatusInternalServerError:
 		// 500
 		c.Logger().Error(err)
 		c.JSON(code, utils.HTTPError{Code: code, Message: "Internal Server Error", Details: msg})
+	case http.StatusServiceUnavailable:
+		// 503
+		c.Logger().Warn(err)
+		c.JSON(code, utils.HTTPError{Code: code, Message: "Service Unavailable", Details: msg})
+	case http.StatusGatewayTimeout:
+		// 504
+		c.Logger().Error(err)
+		c.JSON(code, utils.HTTPError{Code: code, Message: "Gateway Timeout", Details: msg})
 	default:
 		c.Logger().Error(err)
 		c.JSON(code, utils.HTTPError{Code: code, Message: "Error", Details: msg})
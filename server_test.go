@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomHTTPErrorHandler(t *testing.T) {
+	e := echo.New()
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{echo.NewHTTPError(http.StatusNotFound, "No user found"), http.StatusNotFound},
+		{echo.ErrUnsupportedMediaType, http.StatusUnsupportedMediaType},
+		{echo.NewHTTPError(http.StatusInternalServerError, "Error").SetInternal(errors.New("db")), http.StatusInternalServerError},
+		{echo.NewHTTPError(http.StatusInternalServerError, "Error").SetInternal(context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{fmt.Errorf("query: %w", context.Canceled), http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+		customHTTPErrorHandler(tt.err, c)
+		assert.Equal(t, tt.code, rec.Code, tt.err.Error())
+	}
+}
@@ -0,0 +1,107 @@
+package apikey
+
+import (
+	"errors"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/fabienbellanger/echo-boilerplate/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// keyLength is the number of random bytes of a key.
+	keyLength = 32
+
+	// prefixLength is the number of characters of the key stored in clear.
+	prefixLength = 12
+
+	// lastUsedPrecision limits the updates of the last use date.
+	lastUsedPrecision = time.Minute
+)
+
+// APIKeyStore ...
+type APIKeyStore struct {
+	db *db.DB
+}
+
+// New returns a new APIKeyStore
+func New(db *db.DB) APIKeyStore {
+	return APIKeyStore{db: db}
+}
+
+// Create creates a new API key.
+// The returned key is the only one containing the clear key.
+func (s APIKeyStore) Create(userID, name string, scopes []string, expiresAt *time.Time) (entities.APIKey, error) {
+	random, err := utils.GenerateRandomToken(keyLength)
+	if err != nil {
+		return entities.APIKey{}, err
+	}
+	key := entities.APIKeyPrefix + random
+
+	apiKey := entities.APIKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    key[:prefixLength],
+		Hash:      utils.HashToken(key),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	if result := s.db.Create(&apiKey); result.Error != nil {
+		return entities.APIKey{}, result.Error
+	}
+
+	apiKey.Key = key
+	return apiKey, nil
+}
+
+// GetUserAPIKeys lists the API keys of a user.
+func (s APIKeyStore) GetUserAPIKeys(userID string) ([]entities.APIKey, error) {
+	var keys []entities.APIKey
+
+	if result := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys); result.Error != nil {
+		return keys, result.Error
+	}
+	return keys, nil
+}
+
+// Revoke revokes an API key of a user.
+// gorm.ErrRecordNotFound is returned if the user has no active key with this ID.
+func (s APIKeyStore) Revoke(userID, id string) error {
+	result := s.db.Model(&entities.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Authenticate returns the active API key matching the key.
+func (s APIKeyStore) Authenticate(key string) (apiKey entities.APIKey, err error) {
+	if result := s.db.Where("hash = ?", utils.HashToken(key)).First(&apiKey); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return apiKey, store.ErrAPIKeyInvalid
+		}
+		return apiKey, result.Error
+	}
+
+	now := time.Now()
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(now)) {
+		return entities.APIKey{}, store.ErrAPIKeyInvalid
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > lastUsedPrecision {
+		if result := s.db.Model(&apiKey).UpdateColumn("last_used_at", now); result.Error != nil {
+			return apiKey, result.Error
+		}
+	}
+	return apiKey, nil
+}
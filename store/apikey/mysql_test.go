@@ -0,0 +1,103 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/migrations"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/fabienbellanger/echo-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// newTestDB returns an empty in-memory SQLite database, with all the migrations applied.
+func newTestDB(t *testing.T) *db.DB {
+	database, err := db.New(&db.DatabaseConfig{
+		Driver:   db.DriverSQLite,
+		Database: ":memory:",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := db.NewMigrator(database, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return database
+}
+
+func TestAPIKeyCreate(t *testing.T) {
+	database := newTestDB(t)
+	s := New(database)
+
+	apiKey, err := s.Create("user-id", "CI", []string{entities.PermissionUsersRead}, nil)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(apiKey.Key, entities.APIKeyPrefix))
+	assert.Equal(t, apiKey.Key[:prefixLength], apiKey.Prefix)
+
+	var stored entities.APIKey
+	assert.Nil(t, database.First(&stored, "id = ?", apiKey.ID).Error)
+	assert.Equal(t, apiKey.Prefix, stored.Prefix)
+	assert.Equal(t, utils.HashToken(apiKey.Key), stored.Hash, "only the hash of the key is stored")
+	assert.Equal(t, []string{entities.PermissionUsersRead}, stored.Scopes)
+	assert.Empty(t, stored.Key)
+
+	keys, err := s.GetUserAPIKeys("user-id")
+	assert.Nil(t, err)
+	if assert.Len(t, keys, 1) {
+		assert.Equal(t, apiKey.ID, keys[0].ID)
+		assert.Empty(t, keys[0].Key)
+	}
+}
+
+func TestAPIKeyAuthenticate(t *testing.T) {
+	s := New(newTestDB(t))
+	apiKey, err := s.Create("user-id", "CI", []string{entities.PermissionUsersRead}, nil)
+	assert.Nil(t, err)
+
+	authenticated, err := s.Authenticate(apiKey.Key)
+	assert.Nil(t, err)
+	assert.Equal(t, apiKey.ID, authenticated.ID)
+	assert.Equal(t, "user-id", authenticated.UserID)
+	assert.NotNil(t, authenticated.LastUsedAt)
+
+	for _, key := range []string{"", apiKey.Prefix, apiKey.Key + "x", strings.ToUpper(apiKey.Key)} {
+		_, err = s.Authenticate(key)
+		assert.ErrorIs(t, err, store.ErrAPIKeyInvalid, key)
+	}
+}
+
+func TestAPIKeyRevokeAndExpire(t *testing.T) {
+	s := New(newTestDB(t))
+	revoked, err := s.Create("user-id", "Revoked", nil, nil)
+	assert.Nil(t, err)
+	past := time.Now().Add(-time.Minute)
+	expired, err := s.Create("user-id", "Expired", nil, &past)
+	assert.Nil(t, err)
+	future := time.Now().Add(time.Hour)
+	active, err := s.Create("user-id", "Active", nil, &future)
+	assert.Nil(t, err)
+
+	assert.ErrorIs(t, s.Revoke("other-id", revoked.ID), gorm.ErrRecordNotFound, "keys are revoked by their owner")
+	assert.Nil(t, s.Revoke("user-id", revoked.ID))
+	assert.ErrorIs(t, s.Revoke("user-id", revoked.ID), gorm.ErrRecordNotFound, "a key is revoked once")
+
+	_, err = s.Authenticate(revoked.Key)
+	assert.ErrorIs(t, err, store.ErrAPIKeyInvalid)
+	_, err = s.Authenticate(expired.Key)
+	assert.ErrorIs(t, err, store.ErrAPIKeyInvalid)
+	_, err = s.Authenticate(active.Key)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/utils/query"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is the number of audit logs read at once by Export.
+const exportBatchSize = 500
+
+// AuditStore reads the audit trail.
+// Audit logs of changes are written by the audited stores, in the transaction of the change.
+type AuditStore struct {
+	db *db.DB
+}
+
+// New returns a new AuditStore
+func New(db *db.DB) AuditStore {
+	return AuditStore{db: db}
+}
+
+// Create writes an audit log of an operation which is not a store change (Ex.: impersonation).
+func (s AuditStore) Create(log entities.AuditLog) error {
+	return s.db.Create(&log).Error
+}
+
+// GetAuditLogs returns a page of audit logs and the total number of logs matching the filter.
+// Up to params.FetchLimit() logs are returned.
+func (s AuditStore) GetAuditLogs(filter entities.AuditFilter, params query.Params) ([]entities.AuditLog, int64, error) {
+	var logs []entities.AuditLog
+	var total int64
+
+	if result := s.db.Model(&entities.AuditLog{}).Scopes(filterScope(filter)).Count(&total); result.Error != nil {
+		return logs, 0, result.Error
+	}
+
+	q := s.db.Scopes(filterScope(filter), db.OrderBy(params.Sort)).Limit(params.FetchLimit())
+	if params.After != nil {
+		q = q.Scopes(db.After(params.Sort, params.After))
+	} else {
+		q = q.Offset(params.Offset())
+	}
+	if result := q.Find(&logs); result.Error != nil {
+		return logs, 0, result.Error
+	}
+	return logs, total, nil
+}
+
+// Export calls fn for each audit log, oldest first.
+func (s AuditStore) Export(filter entities.AuditFilter, fn func(entities.AuditLog) error) error {
+	for offset := 0; ; offset += exportBatchSize {
+		var logs []entities.AuditLog
+
+		result := s.db.Scopes(filterScope(filter)).
+			Order("created_at").
+			Order("id").
+			Offset(offset).
+			Limit(exportBatchSize).
+			Find(&logs)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		for _, log := range logs {
+			if err := fn(log); err != nil {
+				return err
+			}
+		}
+		if len(logs) < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+// filterScope creates a GORM scope applying the filter.
+func filterScope(filter entities.AuditFilter) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.ActorID != "" {
+			db = db.Where("actor_id = ?", filter.ActorID)
+		}
+		if filter.TargetType != "" {
+			db = db.Where("target_type = ?", filter.TargetType)
+		}
+		if filter.TargetID != "" {
+			db = db.Where("target_id = ?", filter.TargetID)
+		}
+		if filter.Action != "" {
+			db = db.Where("action = ?", filter.Action)
+		}
+		if filter.Since != nil {
+			db = db.Where("created_at >= ?", *filter.Since)
+		}
+		if filter.Until != nil {
+			db = db.Where("created_at < ?", *filter.Until)
+		}
+		return db
+	}
+}
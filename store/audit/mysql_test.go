@@ -0,0 +1,139 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/migrations"
+	"github.com/fabienbellanger/echo-boilerplate/utils/query"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestDB returns an empty in-memory SQLite database, with all the migrations applied.
+func newTestDB(t *testing.T) *db.DB {
+	database, err := db.New(&db.DatabaseConfig{
+		Driver:   db.DriverSQLite,
+		Database: ":memory:",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := db.NewMigrator(database, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return database
+}
+
+// newTestStore returns an AuditStore with n logs, created one minute apart from log-0 to log-(n-1).
+// Even logs are created by actor-0, odd logs by actor-1.
+func newTestStore(t *testing.T, n int) (AuditStore, time.Time) {
+	s := New(newTestDB(t))
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := 0; i < n; i++ {
+		meta := entities.AuditMeta{ActorID: fmt.Sprintf("actor-%d", i%2)}
+		log := entities.NewAuditLog(meta, entities.AuditActionUpdate, entities.AuditTargetUser, "user-id", nil)
+		log.ID = fmt.Sprintf("log-%d", i)
+		log.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		if err := s.Create(log); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s, start
+}
+
+// ids returns the IDs of the logs.
+func ids(logs []entities.AuditLog) []string {
+	list := make([]string, len(logs))
+	for i, log := range logs {
+		list[i] = log.ID
+	}
+	return list
+}
+
+func TestGetAuditLogs(t *testing.T) {
+	s, start := newTestStore(t, 5)
+	sort := []query.Sort{{Field: "created_at", Desc: true}, {Field: "id"}}
+
+	// An extra log is returned if there is a next page
+	logs, total, err := s.GetAuditLogs(entities.AuditFilter{}, query.Params{Page: 1, Limit: 2, Sort: sort})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), total)
+	assert.Equal(t, []string{"log-4", "log-3", "log-2"}, ids(logs))
+
+	logs, _, err = s.GetAuditLogs(entities.AuditFilter{}, query.Params{Page: 3, Limit: 2, Sort: sort})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"log-0"}, ids(logs))
+
+	// Cursor
+	logs, _, err = s.GetAuditLogs(entities.AuditFilter{}, query.Params{
+		Page:  1,
+		Limit: 2,
+		Sort:  sort,
+		After: []interface{}{start.Add(3 * time.Minute), "log-3"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"log-2", "log-1", "log-0"}, ids(logs))
+
+	// Filters
+	since, until := start.Add(time.Minute), start.Add(4*time.Minute)
+	logs, total, err = s.GetAuditLogs(
+		entities.AuditFilter{ActorID: "actor-1", Since: &since, Until: &until},
+		query.Params{Page: 1, Limit: 10, Sort: sort},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, []string{"log-3", "log-1"}, ids(logs))
+
+	_, total, err = s.GetAuditLogs(entities.AuditFilter{Action: entities.AuditActionDelete}, query.Params{Page: 1, Limit: 10, Sort: sort})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), total)
+}
+
+func TestExport(t *testing.T) {
+	s, _ := newTestStore(t, exportBatchSize+2)
+
+	var exported []string
+	err := s.Export(entities.AuditFilter{ActorID: "actor-0"}, func(log entities.AuditLog) error {
+		exported = append(exported, log.ID)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, exported, exportBatchSize/2+1)
+	assert.Equal(t, "log-0", exported[0])
+	assert.Equal(t, fmt.Sprintf("log-%d", exportBatchSize), exported[len(exported)-1])
+
+	// The export stops at the first error
+	errStop := errors.New("stop")
+	n := 0
+	err = s.Export(entities.AuditFilter{}, func(entities.AuditLog) error {
+		n++
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, 1, n)
+}
+
+func TestAuditLogAppendOnly(t *testing.T) {
+	s, _ := newTestStore(t, 1)
+
+	log := entities.AuditLog{ID: "log-0"}
+	assert.ErrorIs(t, s.db.Model(&log).Update("action", entities.AuditActionDelete).Error, entities.ErrAuditLogAppendOnly)
+	assert.ErrorIs(t, s.db.Delete(&log).Error, entities.ErrAuditLogAppendOnly)
+
+	var stored entities.AuditLog
+	assert.Nil(t, s.db.First(&stored, "id = ?", "log-0").Error)
+	assert.Equal(t, entities.AuditActionUpdate, stored.Action)
+}
@@ -0,0 +1,39 @@
+package store
+
+import "errors"
+
+var (
+	// ErrRefreshTokenInvalid is returned when a refresh token does not exist or is expired.
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+
+	// ErrRefreshTokenReused is returned when an already used or revoked refresh token is presented.
+	// The whole token family is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+
+	// ErrOneTimeTokenInvalid is returned when a one-time token does not exist, is expired or has already been used.
+	ErrOneTimeTokenInvalid = errors.New("invalid or expired token")
+
+	// ErrUserNotVerified is returned when a user whose email address is not verified tries to log in.
+	ErrUserNotVerified = errors.New("email address not verified")
+
+	// ErrInvalidPasswordHash is returned when the stored password hash of a user cannot be parsed.
+	ErrInvalidPasswordHash = errors.New("invalid password hash")
+
+	// ErrUsernameTaken is returned when a username is already used by another user, deleted users included.
+	ErrUsernameTaken = errors.New("username already taken")
+
+	// ErrVersionConflict is returned when a user has been changed since the version read by the client.
+	ErrVersionConflict = errors.New("user has been modified")
+
+	// ErrPasswordReused is returned when a new password is one of the last passwords of the user.
+	ErrPasswordReused = errors.New("password already used")
+
+	// ErrAPIKeyInvalid is returned when an API key does not exist, is expired or has been revoked.
+	ErrAPIKeyInvalid = errors.New("invalid API key")
+
+	// ErrUnknownRole is returned when assigning a role which does not exist.
+	ErrUnknownRole = errors.New("unknown role")
+
+	// ErrMissingContext is returned when a store requiring a context is used without WithContext.
+	ErrMissingContext = errors.New("store used without context")
+)
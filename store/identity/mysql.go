@@ -0,0 +1,46 @@
+package identity
+
+import (
+	"errors"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IdentityStore ...
+type IdentityStore struct {
+	db *db.DB
+}
+
+// New returns a new IdentityStore
+func New(db *db.DB) IdentityStore {
+	return IdentityStore{db: db}
+}
+
+// GetUserID returns the ID of the user linked to an external identity.
+// An empty ID is returned if the identity is not linked.
+func (s IdentityStore) GetUserID(provider, subject string) (string, error) {
+	var identity entities.UserIdentity
+	result := s.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", result.Error
+	}
+	return identity.UserID, nil
+}
+
+// Link links a user to an external identity.
+func (s IdentityStore) Link(userID, provider, subject, email string) error {
+	identity := entities.UserIdentity{
+		ID:       uuid.New().String(),
+		UserID:   userID,
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	}
+	return s.db.Create(&identity).Error
+}
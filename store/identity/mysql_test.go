@@ -0,0 +1,70 @@
+package identity
+
+import (
+	"testing"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestDB returns an empty in-memory SQLite database, with all the migrations applied.
+func newTestDB(t *testing.T) *db.DB {
+	database, err := db.New(&db.DatabaseConfig{
+		Driver:   db.DriverSQLite,
+		Database: ":memory:",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := db.NewMigrator(database, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return database
+}
+
+func TestIdentityLink(t *testing.T) {
+	s := New(newTestDB(t))
+
+	userID, err := s.GetUserID("sso", "subject-1")
+	assert.Nil(t, err)
+	assert.Empty(t, userID)
+
+	assert.Nil(t, s.Link("user-1", "sso", "subject-1", "john@test.com"))
+	userID, err = s.GetUserID("sso", "subject-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "user-1", userID)
+
+	// The same subject of another provider is another identity
+	assert.Nil(t, s.Link("user-2", "google", "subject-1", "jane@test.com"))
+	userID, err = s.GetUserID("google", "subject-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "user-2", userID)
+
+	// A user can have several identities
+	assert.Nil(t, s.Link("user-1", "sso", "subject-2", "john@test.com"))
+	userID, err = s.GetUserID("sso", "subject-2")
+	assert.Nil(t, err)
+	assert.Equal(t, "user-1", userID)
+}
+
+func TestIdentityLinkUniqueness(t *testing.T) {
+	s := New(newTestDB(t))
+	assert.Nil(t, s.Link("user-1", "sso", "subject-1", "john@test.com"))
+
+	assert.NotNil(t, s.Link("user-2", "sso", "subject-1", "jane@test.com"), "an identity is linked to a single user")
+	assert.NotNil(t, s.Link("user-1", "sso", "subject-1", "john@test.com"), "an identity is linked once")
+
+	userID, err := s.GetUserID("sso", "subject-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "user-1", userID)
+}
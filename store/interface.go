@@ -1,13 +1,110 @@
 package store
 
-import "github.com/fabienbellanger/echo-boilerplate/entities"
+import (
+	"context"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/utils/query"
+)
 
 // UserStorer interface
 type UserStorer interface {
 	Login(username, password string) (entities.User, error)
 	Register(user *entities.User) error
-	GetAllUsers() ([]entities.User, error)
+	GetUsers(params query.Params) ([]entities.User, int64, error)
 	GetUser(id string) (entities.User, error)
+	GetUserByUsername(username string) (entities.User, error)
 	DeleteUser(id string) error
-	UpdateUser(id string, userForm *entities.UserForm) (entities.User, error)
+	GetDeletedUsers(params query.Params) ([]entities.User, int64, error)
+	RestoreUser(id string) (entities.User, error)
+	PurgeUser(id string) error
+	PurgeDeletedUsers(before time.Time) (int64, error)
+	UpdateUser(id string, userForm *entities.UserForm, version uint) (entities.User, error)
+	PatchUser(id string, patch *entities.UserPatch, version uint) (entities.User, error)
+	UpdatePassword(id, password string) error
+	VerifyUser(id string) error
+	WithAudit(meta entities.AuditMeta) UserStorer
+	WithContext(ctx context.Context) UserStorer
+}
+
+// RefreshTokenStorer interface
+type RefreshTokenStorer interface {
+	Create(userID, familyID string, lifetime time.Duration) (entities.RefreshToken, error)
+	Rotate(token string, lifetime time.Duration) (entities.RefreshToken, error)
+	RevokeFamily(familyID string) error
+	Revoke(token string) error
+}
+
+// OneTimeTokenStorer interface
+type OneTimeTokenStorer interface {
+	Create(userID, purpose string, lifetime time.Duration) (string, error)
+	Peek(token, purpose string) (string, error)
+	Consume(token, purpose string) (string, error)
+	Release(token, purpose string) error
+}
+
+// TokenRevocationStorer interface
+type TokenRevocationStorer interface {
+	Revoke(jti, userID string, expiresAt time.Time) error
+	RevokeAllForUser(userID string) error
+	IsRevoked(jti, userID string, issuedAt time.Time) (bool, error)
+}
+
+// RoleStorer interface
+type RoleStorer interface {
+	InitDefaultRoles() error
+	GetAllRoles() ([]entities.Role, error)
+	GetUserRoles(userID string) ([]entities.Role, error)
+	GetUserRolesAndPermissions(userID string) ([]string, []string, error)
+	AssignRoles(userID string, roleNames []string) error
+}
+
+// MFAStorer interface
+type MFAStorer interface {
+	GetTOTP(userID string) (entities.TOTPFactor, error)
+	CreateTOTP(userID, secret string) error
+	ConfirmTOTP(userID string, step int64) ([]string, error)
+	UseTOTPStep(userID string, step int64) (bool, error)
+	UseRecoveryCode(userID, code string) (bool, error)
+	DisableTOTP(userID string) error
+}
+
+// LoginThrottleStorer interface
+type LoginThrottleStorer interface {
+	Check(username, ip string) (time.Duration, error)
+	RegisterFailure(username, ip string) (entities.LoginFailure, error)
+	RegisterSuccess(username string) error
+	Unlock(username string) error
+}
+
+// APIKeyStorer interface
+type APIKeyStorer interface {
+	Create(userID, name string, scopes []string, expiresAt *time.Time) (entities.APIKey, error)
+	GetUserAPIKeys(userID string) ([]entities.APIKey, error)
+	Revoke(userID, id string) error
+	Authenticate(key string) (entities.APIKey, error)
+}
+
+// IdentityStorer interface
+type IdentityStorer interface {
+	GetUserID(provider, subject string) (string, error)
+	Link(userID, provider, subject, email string) error
+}
+
+// AuditStorer interface
+type AuditStorer interface {
+	Create(log entities.AuditLog) error
+	GetAuditLogs(filter entities.AuditFilter, params query.Params) ([]entities.AuditLog, int64, error)
+	Export(filter entities.AuditFilter, fn func(entities.AuditLog) error) error
+}
+
+// SessionStorer interface
+type SessionStorer interface {
+	Create(session *entities.Session) error
+	GetUserSessions(userID string) ([]entities.Session, error)
+	Refresh(id, ip string, expiresAt time.Time) error
+	Seen(id, ip string) error
+	Terminate(userID, id string) error
+	IsTerminated(id string) (bool, error)
 }
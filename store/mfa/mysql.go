@@ -0,0 +1,155 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// recoveryCodesNumber is the number of recovery codes generated for a user.
+	recoveryCodesNumber = 10
+
+	// recoveryCodeLength is the number of random bytes of a recovery code (80 bits).
+	recoveryCodeLength = 10
+)
+
+// MFAStore ...
+type MFAStore struct {
+	db *db.DB
+}
+
+// New returns a new MFAStore
+func New(db *db.DB) MFAStore {
+	return MFAStore{db: db}
+}
+
+// GetTOTP returns the TOTP factor of a user.
+// The factor UserID is empty if the user has no factor.
+func (m MFAStore) GetTOTP(userID string) (factor entities.TOTPFactor, err error) {
+	if result := m.db.Find(&factor, "user_id = ?", userID); result.Error != nil {
+		return factor, result.Error
+	}
+	return factor, nil
+}
+
+// CreateTOTP creates an unconfirmed TOTP factor, replacing any previous one.
+func (m MFAStore) CreateTOTP(userID, secret string) error {
+	factor := entities.TOTPFactor{
+		UserID: userID,
+		Secret: secret,
+	}
+	result := m.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"secret": secret, "last_used_step": 0, "confirmed_at": nil}),
+	}).Create(&factor)
+	return result.Error
+}
+
+// ConfirmTOTP enables the TOTP factor of a user and returns new recovery codes.
+// The codes are returned in clear only once.
+func (m MFAStore) ConfirmTOTP(userID string, step int64) (codes []string, err error) {
+	err = m.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entities.TOTPFactor{}).
+			Where("user_id = ? AND confirmed_at IS NULL", userID).
+			Updates(map[string]interface{}{"confirmed_at": time.Now(), "last_used_step": step})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		codes, err = m.createRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// UseTOTPStep records the time step of a valid code.
+// It returns false if a code of the same or a later step has already been used.
+func (m MFAStore) UseTOTPStep(userID string, step int64) (bool, error) {
+	result := m.db.Model(&entities.TOTPFactor{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UseRecoveryCode consumes a recovery code.
+// It returns false if the code does not exist or has already been used.
+func (m MFAStore) UseRecoveryCode(userID, code string) (bool, error) {
+	result := m.db.Model(&entities.RecoveryCode{}).
+		Where("user_id = ? AND hash = ? AND used_at IS NULL", userID, utils.HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// DisableTOTP deletes the TOTP factor and the recovery codes of a user.
+func (m MFAStore) DisableTOTP(userID string) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Delete(&entities.TOTPFactor{}, "user_id = ?", userID); result.Error != nil {
+			return result.Error
+		}
+		return tx.Delete(&entities.RecoveryCode{}, "user_id = ?", userID).Error
+	})
+}
+
+// createRecoveryCodes replaces the recovery codes of a user.
+func (m MFAStore) createRecoveryCodes(tx *gorm.DB, userID string) ([]string, error) {
+	if result := tx.Delete(&entities.RecoveryCode{}, "user_id = ?", userID); result.Error != nil {
+		return nil, result.Error
+	}
+
+	codes := make([]string, recoveryCodesNumber)
+	recoveryCodes := make([]entities.RecoveryCode, recoveryCodesNumber)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		recoveryCodes[i] = entities.RecoveryCode{
+			ID:     uuid.New().String(),
+			UserID: userID,
+			Hash:   utils.HashToken(normalizeRecoveryCode(code)),
+		}
+	}
+
+	if result := tx.Create(&recoveryCodes); result.Error != nil {
+		return nil, result.Error
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns a random code formatted as xxxx-xxxx-xxxx-xxxx.
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+	if len(code) != 16 {
+		return "", errors.New("invalid recovery code length")
+	}
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16], nil
+}
+
+// normalizeRecoveryCode removes separators and spaces from a code.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
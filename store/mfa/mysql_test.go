@@ -0,0 +1,154 @@
+package mfa
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/migrations"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// newTestDB returns an empty in-memory SQLite database, with all the migrations applied.
+func newTestDB(t *testing.T) *db.DB {
+	database, err := db.New(&db.DatabaseConfig{
+		Driver:   db.DriverSQLite,
+		Database: ":memory:",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := db.NewMigrator(database, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return database
+}
+
+func TestTOTPEnrolment(t *testing.T) {
+	s := New(newTestDB(t))
+
+	factor, err := s.GetTOTP("user-id")
+	assert.Nil(t, err)
+	assert.Empty(t, factor.UserID)
+
+	// Unconfirmed factor
+	assert.Nil(t, s.CreateTOTP("user-id", "SECRET1"))
+	factor, err = s.GetTOTP("user-id")
+	assert.Nil(t, err)
+	assert.Equal(t, "SECRET1", factor.Secret)
+	assert.False(t, factor.Enabled())
+
+	// Confirmation
+	codes, err := s.ConfirmTOTP("user-id", 100)
+	assert.Nil(t, err)
+	assert.Len(t, codes, recoveryCodesNumber)
+	for _, code := range codes {
+		assert.Regexp(t, `^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$`, code)
+	}
+	factor, err = s.GetTOTP("user-id")
+	assert.Nil(t, err)
+	assert.True(t, factor.Enabled())
+	assert.Equal(t, int64(100), factor.LastUsedStep)
+
+	_, err = s.ConfirmTOTP("user-id", 101)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "a factor is confirmed once")
+	_, err = s.ConfirmTOTP("other-id", 101)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// A new enrolment replaces the factor, which must be confirmed again
+	assert.Nil(t, s.CreateTOTP("user-id", "SECRET2"))
+	factor, err = s.GetTOTP("user-id")
+	assert.Nil(t, err)
+	assert.Equal(t, "SECRET2", factor.Secret)
+	assert.False(t, factor.Enabled())
+	assert.Equal(t, int64(0), factor.LastUsedStep)
+}
+
+func TestUseTOTPStep(t *testing.T) {
+	s := New(newTestDB(t))
+	assert.Nil(t, s.CreateTOTP("user-id", "SECRET"))
+	_, err := s.ConfirmTOTP("user-id", 100)
+	assert.Nil(t, err)
+
+	for _, test := range []struct {
+		step int64
+		ok   bool
+	}{
+		{100, false},
+		{99, false},
+		{101, true},
+		{101, false},
+	} {
+		ok, err := s.UseTOTPStep("user-id", test.step)
+		assert.Nil(t, err)
+		assert.Equal(t, test.ok, ok, test.step)
+	}
+}
+
+func TestUseRecoveryCode(t *testing.T) {
+	database := newTestDB(t)
+	s := New(database)
+	assert.Nil(t, s.CreateTOTP("user-id", "SECRET"))
+	codes, err := s.ConfirmTOTP("user-id", 100)
+	assert.Nil(t, err)
+
+	var stored entities.RecoveryCode
+	assert.Nil(t, database.First(&stored, "user_id = ?", "user-id").Error)
+	for _, code := range codes {
+		assert.NotEqual(t, code, stored.Hash, "codes are not stored in clear")
+	}
+
+	ok, err := s.UseRecoveryCode("other-id", codes[0])
+	assert.Nil(t, err)
+	assert.False(t, ok, "codes belong to their user")
+
+	// Codes are normalized
+	ok, err = s.UseRecoveryCode("user-id", strings.ToUpper(strings.ReplaceAll(codes[0], "-", " ")))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = s.UseRecoveryCode("user-id", codes[0])
+	assert.Nil(t, err)
+	assert.False(t, ok, "codes are single use")
+
+	ok, err = s.UseRecoveryCode("user-id", "aaaa-bbbb-cccc-dddd")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = s.UseRecoveryCode("user-id", codes[1])
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func TestDisableTOTP(t *testing.T) {
+	database := newTestDB(t)
+	s := New(database)
+	assert.Nil(t, s.CreateTOTP("user-id", "SECRET"))
+	codes, err := s.ConfirmTOTP("user-id", 100)
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.DisableTOTP("user-id"))
+	factor, err := s.GetTOTP("user-id")
+	assert.Nil(t, err)
+	assert.Empty(t, factor.UserID)
+
+	ok, err := s.UseRecoveryCode("user-id", codes[0])
+	assert.Nil(t, err)
+	assert.False(t, ok, "recovery codes are deleted with the factor")
+	var count int64
+	assert.Nil(t, database.Model(&entities.RecoveryCode{}).Where("user_id = ?", "user-id").Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+
+	assert.Nil(t, s.DisableTOTP("user-id"), "a disabled factor can be disabled again")
+}
@@ -0,0 +1,111 @@
+package role
+
+import (
+	"fmt"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"gorm.io/gorm"
+)
+
+// RoleStore ...
+type RoleStore struct {
+	db *db.DB
+}
+
+// New returns a new RoleStore
+func New(db *db.DB) RoleStore {
+	return RoleStore{db: db}
+}
+
+// InitDefaultRoles creates the default roles and permissions if they do not exist.
+func (r RoleStore) InitDefaultRoles() error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for name, permissionNames := range entities.DefaultRoles {
+			role := entities.Role{Name: name}
+			if result := tx.Where(&role).FirstOrCreate(&role); result.Error != nil {
+				return result.Error
+			}
+
+			permissions := make([]entities.Permission, len(permissionNames))
+			for i, permissionName := range permissionNames {
+				permissions[i] = entities.Permission{Name: permissionName}
+				if result := tx.Where(&permissions[i]).FirstOrCreate(&permissions[i]); result.Error != nil {
+					return result.Error
+				}
+			}
+
+			if err := tx.Model(&role).Association("Permissions").Append(permissions); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetAllRoles lists all roles with their permissions.
+func (r RoleStore) GetAllRoles() ([]entities.Role, error) {
+	var roles []entities.Role
+
+	if result := r.db.Preload("Permissions").Order("name").Find(&roles); result.Error != nil {
+		return roles, result.Error
+	}
+	return roles, nil
+}
+
+// GetUserRoles returns the roles of a user.
+func (r RoleStore) GetUserRoles(userID string) ([]entities.Role, error) {
+	var roles []entities.Role
+
+	result := r.db.Preload("Permissions").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.name").
+		Find(&roles)
+	if result.Error != nil {
+		return roles, result.Error
+	}
+	return roles, nil
+}
+
+// GetUserRolesAndPermissions returns the role names and the permission names of a user.
+func (r RoleStore) GetUserRolesAndPermissions(userID string) (roles []string, permissions []string, err error) {
+	userRoles, err := r.GetUserRoles(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, role := range userRoles {
+		roles = append(roles, role.Name)
+		for _, permission := range role.Permissions {
+			if !seen[permission.Name] {
+				seen[permission.Name] = true
+				permissions = append(permissions, permission.Name)
+			}
+		}
+	}
+	return roles, permissions, nil
+}
+
+// AssignRoles replaces the roles of a user.
+func (r RoleStore) AssignRoles(userID string, roleNames []string) error {
+	names := make(map[string]bool, len(roleNames))
+	for _, name := range roleNames {
+		names[name] = true
+	}
+
+	var roles []entities.Role
+	if len(roleNames) > 0 {
+		if result := r.db.Where("name IN ?", roleNames).Find(&roles); result.Error != nil {
+			return result.Error
+		}
+		if len(roles) != len(names) {
+			return fmt.Errorf("%w: %v", store.ErrUnknownRole, roleNames)
+		}
+	}
+
+	user := entities.User{ID: userID}
+	return r.db.Model(&user).Association("Roles").Replace(roles)
+}
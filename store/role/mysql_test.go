@@ -0,0 +1,114 @@
+package role
+
+import (
+	"testing"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/migrations"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestDB returns an empty in-memory SQLite database, with all the migrations applied.
+func newTestDB(t *testing.T) *db.DB {
+	database, err := db.New(&db.DatabaseConfig{
+		Driver:   db.DriverSQLite,
+		Database: ":memory:",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := db.NewMigrator(database, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return database
+}
+
+// newTestStore returns a RoleStore with the default roles and a user.
+func newTestStore(t *testing.T) (RoleStore, string) {
+	database := newTestDB(t)
+	user := entities.User{ID: "user-id", Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	assert.Nil(t, database.Create(&user).Error)
+
+	s := New(database)
+	assert.Nil(t, s.InitDefaultRoles())
+	return s, user.ID
+}
+
+func TestInitDefaultRoles(t *testing.T) {
+	s, _ := newTestStore(t)
+	assert.Nil(t, s.InitDefaultRoles(), "default roles can be initialized twice")
+
+	roles, err := s.GetAllRoles()
+	assert.Nil(t, err)
+	if assert.Len(t, roles, len(entities.DefaultRoles)) {
+		for _, role := range roles {
+			var permissions []string
+			for _, permission := range role.Permissions {
+				permissions = append(permissions, permission.Name)
+			}
+			assert.ElementsMatch(t, entities.DefaultRoles[role.Name], permissions, role.Name)
+		}
+	}
+
+	var count int64
+	assert.Nil(t, s.db.Model(&entities.Permission{}).Count(&count).Error)
+	assert.Equal(t, int64(len(entities.DefaultRoles[entities.RoleAdmin])), count, "permissions are shared by roles")
+}
+
+func TestAssignRoles(t *testing.T) {
+	s, userID := newTestStore(t)
+
+	assert.Nil(t, s.AssignRoles(userID, []string{entities.RoleUser, entities.RoleUser}))
+	roles, err := s.GetUserRoles(userID)
+	assert.Nil(t, err)
+	if assert.Len(t, roles, 1, "duplicates are assigned once") {
+		assert.Equal(t, entities.RoleUser, roles[0].Name)
+	}
+
+	err = s.AssignRoles(userID, []string{entities.RoleAdmin, "unknown"})
+	assert.ErrorIs(t, err, store.ErrUnknownRole)
+	roles, err = s.GetUserRoles(userID)
+	assert.Nil(t, err)
+	if assert.Len(t, roles, 1, "roles are unchanged on error") {
+		assert.Equal(t, entities.RoleUser, roles[0].Name)
+	}
+
+	assert.Nil(t, s.AssignRoles(userID, []string{entities.RoleAdmin}))
+	roles, err = s.GetUserRoles(userID)
+	assert.Nil(t, err)
+	if assert.Len(t, roles, 1, "roles are replaced") {
+		assert.Equal(t, entities.RoleAdmin, roles[0].Name)
+	}
+
+	assert.Nil(t, s.AssignRoles(userID, nil))
+	roles, err = s.GetUserRoles(userID)
+	assert.Nil(t, err)
+	assert.Empty(t, roles)
+}
+
+func TestGetUserRolesAndPermissions(t *testing.T) {
+	s, userID := newTestStore(t)
+
+	roles, permissions, err := s.GetUserRolesAndPermissions(userID)
+	assert.Nil(t, err)
+	assert.Empty(t, roles)
+	assert.Empty(t, permissions)
+
+	assert.Nil(t, s.AssignRoles(userID, []string{entities.RoleUser, entities.RoleAdmin}))
+	roles, permissions, err = s.GetUserRolesAndPermissions(userID)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{entities.RoleAdmin, entities.RoleUser}, roles)
+
+	assert.ElementsMatch(t, entities.DefaultRoles[entities.RoleAdmin], permissions, "permissions of several roles are listed once")
+}
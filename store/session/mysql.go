@@ -0,0 +1,187 @@
+package session
+
+import (
+	"sync"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"gorm.io/gorm"
+)
+
+// seenPrecision limits the updates of the last seen date.
+const seenPrecision = time.Minute
+
+// SessionStore stores user sessions in database.
+// Terminated sessions are kept in memory, like revoked tokens, so that the JWT middleware
+// does not query the database on each request. The cache is reloaded every refreshInterval.
+type SessionStore struct {
+	db              *db.DB
+	refreshInterval time.Duration
+
+	mu         sync.RWMutex
+	lastLoad   time.Time
+	terminated map[string]time.Time // Session ID => expiration time
+	seen       map[string]time.Time // Session ID => last update of the last seen date
+	loadFailed bool
+}
+
+// New returns a new SessionStore
+func New(db *db.DB, refreshInterval time.Duration) *SessionStore {
+	return &SessionStore{
+		db:              db,
+		refreshInterval: refreshInterval,
+		terminated:      make(map[string]time.Time),
+		seen:            make(map[string]time.Time),
+	}
+}
+
+// Create creates a new session.
+func (s *SessionStore) Create(session *entities.Session) error {
+	if session.LastSeenAt.IsZero() {
+		session.LastSeenAt = time.Now()
+	}
+	return s.db.Create(session).Error
+}
+
+// GetUserSessions lists the active sessions of a user, most recently seen first.
+func (s *SessionStore) GetUserSessions(userID string) ([]entities.Session, error) {
+	var sessions []entities.Session
+
+	result := s.db.Where("user_id = ? AND terminated_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions)
+	if result.Error != nil {
+		return sessions, result.Error
+	}
+	return sessions, nil
+}
+
+// Refresh extends an active session when its refresh token is rotated.
+func (s *SessionStore) Refresh(id, ip string, expiresAt time.Time) error {
+	result := s.db.Model(&entities.Session{}).
+		Where("id = ? AND terminated_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"ip":           ip,
+			"last_seen_at": time.Now(),
+			"expires_at":   expiresAt,
+		})
+	return result.Error
+}
+
+// Seen updates the last seen date and IP address of a session, at most once per minute.
+func (s *SessionStore) Seen(id, ip string) error {
+	now := time.Now()
+
+	s.mu.RLock()
+	last, ok := s.seen[id]
+	s.mu.RUnlock()
+	if ok && now.Sub(last) < seenPrecision {
+		return nil
+	}
+
+	result := s.db.Model(&entities.Session{}).
+		Where("id = ? AND terminated_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"ip":           ip,
+			"last_seen_at": now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	s.mu.Lock()
+	s.seen[id] = now
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Terminate terminates an active session of a user and revokes its refresh tokens.
+// gorm.ErrRecordNotFound is returned if the user has no active session with this ID.
+func (s *SessionStore) Terminate(userID, id string) error {
+	var session entities.Session
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ? AND terminated_at IS NULL", id, userID).First(&session)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		now := time.Now()
+		if result := tx.Model(&session).Update("terminated_at", now); result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Model(&entities.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", now).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.terminated[id] = session.ExpiresAt
+	delete(s.seen, id)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// IsTerminated returns true if the session has been terminated.
+func (s *SessionStore) IsTerminated(id string) (bool, error) {
+	if err := s.reloadIfNeeded(); err != nil {
+		return false, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.terminated[id]
+	return ok, nil
+}
+
+// reloadIfNeeded reloads terminated sessions from database if the cache is too old.
+// Expired sessions are deleted.
+func (s *SessionStore) reloadIfNeeded() error {
+	s.mu.RLock()
+	upToDate := !s.loadFailed && time.Since(s.lastLoad) < s.refreshInterval
+	s.mu.RUnlock()
+	if upToDate {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Another goroutine may have reloaded the cache in the meantime
+	if !s.loadFailed && time.Since(s.lastLoad) < s.refreshInterval {
+		return nil
+	}
+
+	now := time.Now()
+	if result := s.db.Where("expires_at < ?", now).Delete(&entities.Session{}); result.Error != nil {
+		s.loadFailed = true
+		return result.Error
+	}
+
+	var sessions []entities.Session
+	if result := s.db.Where("terminated_at IS NOT NULL").Find(&sessions); result.Error != nil {
+		s.loadFailed = true
+		return result.Error
+	}
+
+	s.terminated = make(map[string]time.Time, len(sessions))
+	for _, session := range sessions {
+		s.terminated[session.ID] = session.ExpiresAt
+	}
+	for id, last := range s.seen {
+		if now.Sub(last) > seenPrecision {
+			delete(s.seen, id)
+		}
+	}
+	s.lastLoad = now
+	s.loadFailed = false
+
+	return nil
+}
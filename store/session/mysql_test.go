@@ -0,0 +1,150 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/migrations"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// newTestDB returns an empty in-memory SQLite database, with all the migrations applied.
+func newTestDB(t *testing.T) *db.DB {
+	database, err := db.New(&db.DatabaseConfig{
+		Driver:   db.DriverSQLite,
+		Database: ":memory:",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := db.NewMigrator(database, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return database
+}
+
+func TestSessionStoreCreateAndList(t *testing.T) {
+	s := New(newTestDB(t), time.Hour)
+	now := time.Now()
+
+	older := entities.Session{ID: "session-1", UserID: "user-id", IP: "1.1.1.1", LastSeenAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)}
+	assert.Nil(t, s.Create(&older))
+	recent := entities.Session{ID: "session-2", UserID: "user-id", IP: "2.2.2.2", ExpiresAt: now.Add(time.Hour)}
+	assert.Nil(t, s.Create(&recent))
+	assert.False(t, recent.LastSeenAt.IsZero())
+
+	expired := entities.Session{ID: "session-3", UserID: "user-id", ExpiresAt: now.Add(-time.Minute)}
+	assert.Nil(t, s.Create(&expired))
+	other := entities.Session{ID: "session-4", UserID: "other-id", ExpiresAt: now.Add(time.Hour)}
+	assert.Nil(t, s.Create(&other))
+
+	sessions, err := s.GetUserSessions("user-id")
+	assert.Nil(t, err)
+	if assert.Len(t, sessions, 2) {
+		assert.Equal(t, "session-2", sessions[0].ID)
+		assert.Equal(t, "session-1", sessions[1].ID)
+	}
+
+	// Duplicate ID
+	assert.NotNil(t, s.Create(&entities.Session{ID: "session-1", UserID: "user-id", ExpiresAt: now.Add(time.Hour)}))
+}
+
+func TestSessionStoreTerminate(t *testing.T) {
+	database := newTestDB(t)
+	s := New(database, time.Hour)
+	expiresAt := time.Now().Add(time.Hour)
+
+	assert.Nil(t, s.Create(&entities.Session{ID: "session-id", UserID: "user-id", ExpiresAt: expiresAt}))
+	assert.Nil(t, database.Create(&entities.RefreshToken{ID: "token-1", UserID: "user-id", FamilyID: "session-id", Hash: "hash-1", ExpiresAt: expiresAt}).Error)
+	assert.Nil(t, database.Create(&entities.RefreshToken{ID: "token-2", UserID: "user-id", FamilyID: "other-id", Hash: "hash-2", ExpiresAt: expiresAt}).Error)
+
+	terminated, err := s.IsTerminated("session-id")
+	assert.Nil(t, err)
+	assert.False(t, terminated)
+
+	// Session of another user
+	assert.ErrorIs(t, s.Terminate("other-id", "session-id"), gorm.ErrRecordNotFound)
+
+	assert.Nil(t, s.Terminate("user-id", "session-id"))
+	assert.ErrorIs(t, s.Terminate("user-id", "session-id"), gorm.ErrRecordNotFound)
+
+	terminated, err = s.IsTerminated("session-id")
+	assert.Nil(t, err)
+	assert.True(t, terminated)
+
+	sessions, err := s.GetUserSessions("user-id")
+	assert.Nil(t, err)
+	assert.Empty(t, sessions)
+
+	// Only the refresh tokens of the session are revoked
+	var tokens []entities.RefreshToken
+	assert.Nil(t, database.Order("id").Find(&tokens).Error)
+	if assert.Len(t, tokens, 2) {
+		assert.NotNil(t, tokens[0].RevokedAt)
+		assert.Nil(t, tokens[1].RevokedAt)
+	}
+}
+
+func TestSessionStoreTerminatedCache(t *testing.T) {
+	database := newTestDB(t)
+	now := time.Now()
+	assert.Nil(t, New(database, time.Hour).Create(&entities.Session{ID: "session-id", UserID: "user-id", ExpiresAt: now.Add(time.Hour)}))
+
+	// The cache is loaded on first use, then kept during the refresh interval
+	s := New(database, time.Hour)
+	terminated, err := s.IsTerminated("session-id")
+	assert.Nil(t, err)
+	assert.False(t, terminated)
+
+	// Termination by another instance
+	assert.Nil(t, New(database, time.Hour).Terminate("user-id", "session-id"))
+	terminated, err = s.IsTerminated("session-id")
+	assert.Nil(t, err)
+	assert.False(t, terminated)
+
+	s.lastLoad = now.Add(-2 * time.Hour)
+	terminated, err = s.IsTerminated("session-id")
+	assert.Nil(t, err)
+	assert.True(t, terminated)
+
+	// Expired sessions are deleted on reload
+	assert.Nil(t, s.Create(&entities.Session{ID: "expired-id", UserID: "user-id", ExpiresAt: now.Add(-time.Minute)}))
+	s.lastLoad = now.Add(-2 * time.Hour)
+	_, err = s.IsTerminated("session-id")
+	assert.Nil(t, err)
+
+	var count int64
+	assert.Nil(t, database.Model(&entities.Session{}).Where("id = ?", "expired-id").Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestSessionStoreSeen(t *testing.T) {
+	database := newTestDB(t)
+	s := New(database, time.Hour)
+	lastSeenAt := time.Now().Add(-time.Hour)
+	assert.Nil(t, s.Create(&entities.Session{ID: "session-id", UserID: "user-id", IP: "1.1.1.1", LastSeenAt: lastSeenAt, ExpiresAt: time.Now().Add(time.Hour)}))
+
+	assert.Nil(t, s.Seen("session-id", "2.2.2.2"))
+
+	var session entities.Session
+	assert.Nil(t, database.First(&session, "id = ?", "session-id").Error)
+	assert.Equal(t, "2.2.2.2", session.IP)
+	assert.True(t, session.LastSeenAt.After(lastSeenAt))
+
+	// Updates are limited to one per minute
+	assert.Nil(t, s.Seen("session-id", "3.3.3.3"))
+	assert.Nil(t, database.First(&session, "id = ?", "session-id").Error)
+	assert.Equal(t, "2.2.2.2", session.IP)
+}
@@ -0,0 +1,129 @@
+package throttle
+
+import (
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginThrottleStore tracks failed login attempts per username and per IP address.
+type LoginThrottleStore struct {
+	db         *db.DB
+	userPolicy Policy
+	ipPolicy   Policy
+	scope      string
+}
+
+// New returns a new LoginThrottleStore
+func New(db *db.DB, userPolicy, ipPolicy Policy) LoginThrottleStore {
+	return LoginThrottleStore{
+		db:         db,
+		userPolicy: userPolicy,
+		ipPolicy:   ipPolicy,
+	}
+}
+
+// WithScope returns a store throttling another action than the login with its own policies.
+// The attempts of a scope are counted separately (Ex.: entities.ThrottleScopeEmail).
+func (s LoginThrottleStore) WithScope(scope string, userPolicy, ipPolicy Policy) LoginThrottleStore {
+	s.scope = scope
+	s.userPolicy = userPolicy
+	s.ipPolicy = ipPolicy
+	return s
+}
+
+// Check returns how long login attempts are still blocked for the username or the IP address.
+func (s LoginThrottleStore) Check(username, ip string) (time.Duration, error) {
+	var throttles []entities.LoginThrottle
+	if result := s.db.Where("identifier IN ?", []string{s.usernameKey(username), s.ipKey(ip)}).Find(&throttles); result.Error != nil {
+		return 0, result.Error
+	}
+
+	var blockedFor time.Duration
+	now := time.Now()
+	for _, t := range throttles {
+		if t.BlockedUntil != nil && t.BlockedUntil.After(now) && t.BlockedUntil.Sub(now) > blockedFor {
+			blockedFor = t.BlockedUntil.Sub(now)
+		}
+	}
+	return blockedFor, nil
+}
+
+// RegisterFailure records a failed login attempt for the username and the IP address.
+func (s LoginThrottleStore) RegisterFailure(username, ip string) (failure entities.LoginFailure, err error) {
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		t, err := s.registerFailure(tx, s.usernameKey(username), s.userPolicy)
+		if err != nil {
+			return err
+		}
+		failure.Failures = t.Failures
+		failure.Locked = t.Locked
+		if t.BlockedUntil != nil {
+			failure.BlockedFor = time.Until(*t.BlockedUntil)
+		}
+
+		t, err = s.registerFailure(tx, s.ipKey(ip), s.ipPolicy)
+		if err != nil {
+			return err
+		}
+		if t.BlockedUntil != nil {
+			failure.IPBlockedFor = time.Until(*t.BlockedUntil)
+		}
+		return nil
+	})
+	return failure, err
+}
+
+// RegisterSuccess forgets the failed attempts of a username.
+// IP address failures are kept to prevent an attacker from resetting them with its own account.
+func (s LoginThrottleStore) RegisterSuccess(username string) error {
+	return s.Unlock(username)
+}
+
+// Unlock removes the lockout and the failed attempts of a username.
+func (s LoginThrottleStore) Unlock(username string) error {
+	return s.db.Delete(&entities.LoginThrottle{}, "identifier = ?", s.usernameKey(username)).Error
+}
+
+// usernameKey returns the identifier of a username in the store scope.
+func (s LoginThrottleStore) usernameKey(username string) string {
+	return entities.ThrottleScopedKey(s.scope, entities.LoginThrottleUsernameKey(username))
+}
+
+// ipKey returns the identifier of an IP address in the store scope.
+func (s LoginThrottleStore) ipKey(ip string) string {
+	return entities.ThrottleScopedKey(s.scope, entities.LoginThrottleIPKey(ip))
+}
+
+// registerFailure increments the failures of a key and computes the new blocking time.
+func (s LoginThrottleStore) registerFailure(tx *gorm.DB, key string, policy Policy) (entities.LoginThrottle, error) {
+	t := entities.LoginThrottle{Identifier: key}
+	if result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&t); result.Error != nil {
+		return t, result.Error
+	}
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, "identifier = ?", key); result.Error != nil {
+		return t, result.Error
+	}
+
+	now := time.Now()
+	if policy.Window > 0 && now.Sub(t.LastFailedAt) > policy.Window {
+		t.Failures = 0
+	}
+	t.Failures++
+	t.LastFailedAt = now
+
+	delay, locked := policy.delay(t.Failures)
+	if delay > 0 {
+		blockedUntil := now.Add(delay)
+		t.BlockedUntil = &blockedUntil
+	}
+	t.Locked = locked
+
+	if result := tx.Save(&t); result.Error != nil {
+		return t, result.Error
+	}
+	return t, nil
+}
@@ -0,0 +1,69 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestDB returns an empty in-memory SQLite database, with all the migrations applied.
+func newTestDB(t *testing.T) *db.DB {
+	database, err := db.New(&db.DatabaseConfig{
+		Driver:   db.DriverSQLite,
+		Database: ":memory:",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := db.NewMigrator(database, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return database
+}
+
+func TestLoginThrottleStoreScope(t *testing.T) {
+	policy := Policy{FreeAttempts: 1, BackoffBase: time.Minute, Window: time.Hour}
+	login := New(newTestDB(t), policy, Policy{FreeAttempts: 10, BackoffBase: time.Minute})
+	email := login.WithScope(entities.ThrottleScopeEmail, policy, Policy{FreeAttempts: 2, BackoffBase: time.Minute})
+
+	// Per username
+	for i := 0; i < 2; i++ {
+		_, err := email.RegisterFailure("john@test.com", "127.0.0.1")
+		assert.Nil(t, err)
+	}
+	blockedFor, err := email.Check("JOHN@test.com", "127.0.0.2")
+	assert.Nil(t, err)
+	assert.Greater(t, blockedFor, time.Duration(0))
+
+	blockedFor, err = login.Check("john@test.com", "127.0.0.1")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), blockedFor, "the attempts of a scope do not block the login")
+
+	assert.Nil(t, login.Unlock("john@test.com"))
+	blockedFor, err = email.Check("john@test.com", "127.0.0.2")
+	assert.Nil(t, err)
+	assert.Greater(t, blockedFor, time.Duration(0), "unlocking the login does not unlock the scope")
+
+	// Per IP address
+	_, err = email.RegisterFailure("jane@test.com", "127.0.0.1")
+	assert.Nil(t, err)
+	blockedFor, err = email.Check("alice@test.com", "127.0.0.1")
+	assert.Nil(t, err)
+	assert.Greater(t, blockedFor, time.Duration(0))
+	blockedFor, err = email.Check("alice@test.com", "127.0.0.2")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), blockedFor)
+}
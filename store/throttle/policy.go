@@ -0,0 +1,38 @@
+package throttle
+
+import (
+	"time"
+)
+
+// Policy defines how failed login attempts are throttled.
+type Policy struct {
+	FreeAttempts     int           // Failed attempts allowed before backoff
+	BackoffBase      time.Duration // Delay after the first throttled attempt, doubled after each new failure
+	BackoffMax       time.Duration // Maximum backoff delay
+	LockoutThreshold int           // Failed attempts before lockout (0 disables lockout)
+	LockoutDuration  time.Duration
+	Window           time.Duration // Failures are forgotten after this period without failure
+}
+
+// delay returns how long new attempts are blocked after the given number of failures
+// and whether the failures trigger a lockout.
+func (p Policy) delay(failures int) (time.Duration, bool) {
+	if p.LockoutThreshold > 0 && failures >= p.LockoutThreshold {
+		return p.LockoutDuration, true
+	}
+	if failures <= p.FreeAttempts || p.BackoffBase <= 0 {
+		return 0, false
+	}
+
+	d := p.BackoffBase
+	for i := p.FreeAttempts + 1; i < failures; i++ {
+		d *= 2
+		if p.BackoffMax > 0 && d >= p.BackoffMax {
+			return p.BackoffMax, false
+		}
+	}
+	if p.BackoffMax > 0 && d > p.BackoffMax {
+		d = p.BackoffMax
+	}
+	return d, false
+}
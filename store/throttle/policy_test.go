@@ -0,0 +1,45 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	p := Policy{
+		FreeAttempts:     3,
+		BackoffBase:      time.Second,
+		BackoffMax:       10 * time.Second,
+		LockoutThreshold: 10,
+		LockoutDuration:  30 * time.Minute,
+	}
+
+	cases := []struct {
+		failures int
+		delay    time.Duration
+		locked   bool
+	}{
+		{1, 0, false},
+		{3, 0, false},
+		{4, time.Second, false},
+		{5, 2 * time.Second, false},
+		{6, 4 * time.Second, false},
+		{7, 8 * time.Second, false},
+		{8, 10 * time.Second, false},
+		{9, 10 * time.Second, false},
+		{10, 30 * time.Minute, true},
+		{15, 30 * time.Minute, true},
+	}
+	for _, c := range cases {
+		delay, locked := p.delay(c.failures)
+		assert.Equal(t, c.delay, delay, c.failures)
+		assert.Equal(t, c.locked, locked, c.failures)
+	}
+
+	p.LockoutThreshold = 0
+	delay, locked := p.delay(100)
+	assert.Equal(t, 10*time.Second, delay)
+	assert.False(t, locked)
+}
@@ -0,0 +1,122 @@
+package token
+
+import (
+	"errors"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/fabienbellanger/echo-boilerplate/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// refreshTokenLength is the number of random bytes of a refresh token.
+const refreshTokenLength = 32
+
+// RefreshTokenStore ...
+type RefreshTokenStore struct {
+	db *db.DB
+}
+
+// New returns a new RefreshTokenStore
+func New(db *db.DB) RefreshTokenStore {
+	return RefreshTokenStore{db: db}
+}
+
+// Create creates the first refresh token of a new family.
+func (s RefreshTokenStore) Create(userID, familyID string, lifetime time.Duration) (entities.RefreshToken, error) {
+	return s.create(s.db.DB, userID, familyID, lifetime)
+}
+
+// Rotate exchanges a valid refresh token for a new one of the same family.
+// If the token has already been used or revoked, the whole family is revoked
+// and store.ErrRefreshTokenReused is returned.
+func (s RefreshTokenStore) Rotate(token string, lifetime time.Duration) (newToken entities.RefreshToken, err error) {
+	var current entities.RefreshToken
+	if result := s.db.Where("hash = ?", utils.HashToken(token)).First(&current); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return newToken, store.ErrRefreshTokenInvalid
+		}
+		return newToken, result.Error
+	}
+
+	// Reuse detection
+	// ---------------
+	if current.UsedAt != nil || current.RevokedAt != nil {
+		if err := s.RevokeFamily(current.FamilyID); err != nil {
+			return newToken, err
+		}
+		return newToken, store.ErrRefreshTokenReused
+	}
+
+	if current.ExpiresAt.Before(time.Now()) {
+		return newToken, store.ErrRefreshTokenInvalid
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// The condition on used_at prevents two concurrent rotations of the same token.
+		result := tx.Model(&entities.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", current.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return store.ErrRefreshTokenReused
+		}
+
+		newToken, err = s.create(tx, current.UserID, current.FamilyID, lifetime)
+		return err
+	})
+	if errors.Is(err, store.ErrRefreshTokenReused) {
+		if errRevoke := s.RevokeFamily(current.FamilyID); errRevoke != nil {
+			return newToken, errRevoke
+		}
+	}
+	return newToken, err
+}
+
+// RevokeFamily revokes all the tokens of a family.
+func (s RefreshTokenStore) RevokeFamily(familyID string) error {
+	result := s.db.Model(&entities.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now())
+	return result.Error
+}
+
+// Revoke revokes the family of a refresh token.
+// Unknown tokens are ignored.
+func (s RefreshTokenStore) Revoke(token string) error {
+	var refreshToken entities.RefreshToken
+	if result := s.db.Where("hash = ?", utils.HashToken(token)).First(&refreshToken); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return result.Error
+	}
+	return s.RevokeFamily(refreshToken.FamilyID)
+}
+
+// create inserts a new refresh token in database.
+func (s RefreshTokenStore) create(tx *gorm.DB, userID, familyID string, lifetime time.Duration) (entities.RefreshToken, error) {
+	token, err := utils.GenerateRandomToken(refreshTokenLength)
+	if err != nil {
+		return entities.RefreshToken{}, err
+	}
+
+	refreshToken := entities.RefreshToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		Hash:      utils.HashToken(token),
+		ExpiresAt: time.Now().Add(lifetime),
+	}
+	if result := tx.Create(&refreshToken); result.Error != nil {
+		return entities.RefreshToken{}, result.Error
+	}
+
+	refreshToken.Token = token
+	return refreshToken, nil
+}
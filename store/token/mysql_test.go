@@ -0,0 +1,95 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshTokenRotate(t *testing.T) {
+	database := newTestDB(t)
+	s := New(database)
+
+	first, err := s.Create("user-id", "family-id", time.Hour)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, first.Token)
+	assert.Equal(t, "family-id", first.FamilyID)
+
+	second, err := s.Rotate(first.Token, time.Hour)
+	assert.Nil(t, err)
+	assert.NotEqual(t, first.Token, second.Token)
+	assert.Equal(t, "user-id", second.UserID)
+	assert.Equal(t, first.FamilyID, second.FamilyID)
+
+	third, err := s.Rotate(second.Token, time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, first.FamilyID, third.FamilyID)
+
+	_, err = s.Rotate("unknown", time.Hour)
+	assert.ErrorIs(t, err, store.ErrRefreshTokenInvalid)
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	database := newTestDB(t)
+	s := New(database)
+
+	first, err := s.Create("user-id", "family-id", time.Hour)
+	assert.Nil(t, err)
+	other, err := s.Create("user-id", "other-id", time.Hour)
+	assert.Nil(t, err)
+
+	second, err := s.Rotate(first.Token, time.Hour)
+	assert.Nil(t, err)
+
+	// The used token is presented again: the whole family is revoked
+	_, err = s.Rotate(first.Token, time.Hour)
+	assert.ErrorIs(t, err, store.ErrRefreshTokenReused)
+
+	_, err = s.Rotate(second.Token, time.Hour)
+	assert.ErrorIs(t, err, store.ErrRefreshTokenReused)
+
+	var tokens []entities.RefreshToken
+	assert.Nil(t, database.Where("family_id = ?", "family-id").Find(&tokens).Error)
+	assert.Len(t, tokens, 2)
+	for _, token := range tokens {
+		assert.NotNil(t, token.RevokedAt)
+	}
+
+	// Other families are not revoked
+	_, err = s.Rotate(other.Token, time.Hour)
+	assert.Nil(t, err)
+}
+
+func TestRefreshTokenExpiry(t *testing.T) {
+	database := newTestDB(t)
+	s := New(database)
+
+	token, err := s.Create("user-id", "family-id", time.Hour)
+	assert.Nil(t, err)
+	assert.Nil(t, database.Model(&entities.RefreshToken{}).Where("family_id = ?", "family-id").Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+	_, err = s.Rotate(token.Token, time.Hour)
+	assert.ErrorIs(t, err, store.ErrRefreshTokenInvalid)
+
+	var count int64
+	assert.Nil(t, database.Model(&entities.RefreshToken{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestRefreshTokenRevoke(t *testing.T) {
+	s := New(newTestDB(t))
+
+	first, err := s.Create("user-id", "family-id", time.Hour)
+	assert.Nil(t, err)
+	second, err := s.Rotate(first.Token, time.Hour)
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.Revoke(first.Token))
+	assert.Nil(t, s.Revoke("unknown"))
+
+	_, err = s.Rotate(second.Token, time.Hour)
+	assert.ErrorIs(t, err, store.ErrRefreshTokenReused)
+}
@@ -0,0 +1,107 @@
+package token
+
+import (
+	"errors"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/fabienbellanger/echo-boilerplate/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// oneTimeTokenLength is the number of random bytes of a one-time token.
+const oneTimeTokenLength = 32
+
+// OneTimeTokenStore stores single use tokens sent to users.
+type OneTimeTokenStore struct {
+	db *db.DB
+}
+
+// NewOneTimeTokenStore returns a new OneTimeTokenStore
+func NewOneTimeTokenStore(db *db.DB) OneTimeTokenStore {
+	return OneTimeTokenStore{db: db}
+}
+
+// Create creates a new token for a purpose and returns it in clear.
+// The previous unused tokens of the user for the same purpose are invalidated.
+func (s OneTimeTokenStore) Create(userID, purpose string, lifetime time.Duration) (string, error) {
+	token, err := utils.GenerateRandomToken(oneTimeTokenLength)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Delete(&entities.OneTimeToken{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Create(&entities.OneTimeToken{
+			ID:        uuid.New().String(),
+			UserID:    userID,
+			Purpose:   purpose,
+			Hash:      utils.HashToken(token),
+			ExpiresAt: time.Now().Add(lifetime),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Peek returns the user ID of a valid token without using it.
+func (s OneTimeTokenStore) Peek(token, purpose string) (string, error) {
+	t, err := s.find(token, purpose)
+	if err != nil {
+		return "", err
+	}
+	return t.UserID, nil
+}
+
+// Consume marks a valid token as used and returns its user ID.
+func (s OneTimeTokenStore) Consume(token, purpose string) (string, error) {
+	t, err := s.find(token, purpose)
+	if err != nil {
+		return "", err
+	}
+
+	// The condition on used_at prevents two concurrent uses of the same token.
+	result := s.db.Model(&entities.OneTimeToken{}).
+		Where("id = ? AND used_at IS NULL", t.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", store.ErrOneTimeTokenInvalid
+	}
+	return t.UserID, nil
+}
+
+// Release makes a consumed token usable again, when the operation it has been consumed for has failed.
+// Expired tokens are not released.
+func (s OneTimeTokenStore) Release(token, purpose string) error {
+	return s.db.Model(&entities.OneTimeToken{}).
+		Where("hash = ? AND purpose = ? AND used_at IS NOT NULL AND expires_at > ?", utils.HashToken(token), purpose, time.Now()).
+		Update("used_at", nil).Error
+}
+
+// find returns a valid token.
+func (s OneTimeTokenStore) find(token, purpose string) (t entities.OneTimeToken, err error) {
+	result := s.db.Where("hash = ? AND purpose = ?", utils.HashToken(token), purpose).First(&t)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return t, store.ErrOneTimeTokenInvalid
+		}
+		return t, result.Error
+	}
+	if t.UsedAt != nil || t.ExpiresAt.Before(time.Now()) {
+		return t, store.ErrOneTimeTokenInvalid
+	}
+	return t, nil
+}
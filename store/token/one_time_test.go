@@ -0,0 +1,99 @@
+package token
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/migrations"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestDB returns an empty in-memory SQLite database, with all the migrations applied.
+func newTestDB(t *testing.T) *db.DB {
+	database, err := db.New(&db.DatabaseConfig{
+		Driver:   db.DriverSQLite,
+		Database: ":memory:",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := db.NewMigrator(database, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return database
+}
+
+func TestOneTimeToken(t *testing.T) {
+	s := NewOneTimeTokenStore(newTestDB(t))
+
+	token, err := s.Create("user-id", entities.TokenPurposePasswordReset, time.Minute)
+	assert.Nil(t, err)
+
+	_, err = s.Peek(token, entities.TokenPurposeEmailVerification)
+	assert.ErrorIs(t, err, store.ErrOneTimeTokenInvalid)
+	userID, err := s.Peek(token, entities.TokenPurposePasswordReset)
+	assert.Nil(t, err)
+	assert.Equal(t, "user-id", userID)
+
+	userID, err = s.Consume(token, entities.TokenPurposePasswordReset)
+	assert.Nil(t, err)
+	assert.Equal(t, "user-id", userID)
+	_, err = s.Consume(token, entities.TokenPurposePasswordReset)
+	assert.ErrorIs(t, err, store.ErrOneTimeTokenInvalid)
+
+	// A released token can be used again
+	assert.Nil(t, s.Release(token, entities.TokenPurposePasswordReset))
+	_, err = s.Consume(token, entities.TokenPurposePasswordReset)
+	assert.Nil(t, err)
+
+	// A new token invalidates the previous unused one
+	first, err := s.Create("user-id", entities.TokenPurposePasswordReset, time.Minute)
+	assert.Nil(t, err)
+	_, err = s.Create("user-id", entities.TokenPurposePasswordReset, time.Minute)
+	assert.Nil(t, err)
+	_, err = s.Peek(first, entities.TokenPurposePasswordReset)
+	assert.ErrorIs(t, err, store.ErrOneTimeTokenInvalid)
+
+	// Expired tokens cannot be used nor released
+	expired, err := s.Create("other-id", entities.TokenPurposePasswordReset, -time.Minute)
+	assert.Nil(t, err)
+	_, err = s.Consume(expired, entities.TokenPurposePasswordReset)
+	assert.ErrorIs(t, err, store.ErrOneTimeTokenInvalid)
+}
+
+func TestOneTimeTokenConcurrentConsume(t *testing.T) {
+	s := NewOneTimeTokenStore(newTestDB(t))
+	token, err := s.Create("user-id", entities.TokenPurposePasswordReset, time.Minute)
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	consumed := 0
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Consume(token, entities.TokenPurposePasswordReset); err == nil {
+				mu.Lock()
+				consumed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, consumed, "a token can only be consumed once")
+}
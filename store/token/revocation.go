@@ -0,0 +1,169 @@
+package token
+
+import (
+	"sync"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevocationStore stores revoked access tokens in database and keeps them in memory
+// so that the JWT middleware does not query the database on each request.
+// The cache is reloaded from the database every refreshInterval to take into account
+// revocations made by other instances.
+type RevocationStore struct {
+	db              *db.DB
+	refreshInterval time.Duration
+
+	mu         sync.RWMutex
+	lastLoad   time.Time
+	tokens     map[string]time.Time // JWT ID => expiration time
+	users      map[string]time.Time // User ID => revocation time
+	loadFailed bool
+}
+
+// NewRevocationStore returns a new RevocationStore
+func NewRevocationStore(db *db.DB, refreshInterval time.Duration) *RevocationStore {
+	return &RevocationStore{
+		db:              db,
+		refreshInterval: refreshInterval,
+		tokens:          make(map[string]time.Time),
+		users:           make(map[string]time.Time),
+	}
+}
+
+// Revoke revokes an access token.
+func (s *RevocationStore) Revoke(jti, userID string, expiresAt time.Time) error {
+	token := entities.RevokedToken{
+		ID:        jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}
+	if result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&token); result.Error != nil {
+		return result.Error
+	}
+
+	s.mu.Lock()
+	s.tokens[jti] = expiresAt
+	s.mu.Unlock()
+
+	return nil
+}
+
+// RevokeAllForUser revokes all the access tokens issued until now and all the refresh tokens of a user.
+// All the user sessions are terminated.
+// Revocations have a millisecond precision: the method returns once the millisecond of the revocation is over,
+// so that the tokens issued afterwards (Ex.: login after a password change) are valid.
+func (s *RevocationStore) RevokeAllForUser(userID string) error {
+	now := time.Now().Truncate(time.Millisecond)
+	revocation := entities.UserTokensRevocation{
+		UserID:    userID,
+		RevokedAt: now,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"revoked_at"}),
+		}).Create(&revocation)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		result = tx.Model(&entities.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Model(&entities.Session{}).
+			Where("user_id = ? AND terminated_at IS NULL", userID).
+			Update("terminated_at", now).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.users[userID] = now
+	s.mu.Unlock()
+
+	time.Sleep(time.Until(now.Add(time.Millisecond)))
+
+	return nil
+}
+
+// IsRevoked returns true if the access token has been revoked,
+// either individually or because all the tokens of its user have been revoked.
+func (s *RevocationStore) IsRevoked(jti, userID string, issuedAt time.Time) (bool, error) {
+	if err := s.reloadIfNeeded(); err != nil {
+		return false, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.tokens[jti]; ok {
+		return true, nil
+	}
+	// Tokens issued in the millisecond of the revocation are revoked, see RevokeAllForUser.
+	// Tokens without a millisecond issue time are revoked in the whole second of the revocation.
+	if revokedAt, ok := s.users[userID]; ok && !issuedAt.After(revokedAt) {
+		return true, nil
+	}
+	return false, nil
+}
+
+// reloadIfNeeded reloads revocations from database if the cache is too old.
+// Expired revoked tokens are deleted.
+func (s *RevocationStore) reloadIfNeeded() error {
+	s.mu.RLock()
+	upToDate := !s.loadFailed && time.Since(s.lastLoad) < s.refreshInterval
+	s.mu.RUnlock()
+	if upToDate {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Another goroutine may have reloaded the cache in the meantime
+	if !s.loadFailed && time.Since(s.lastLoad) < s.refreshInterval {
+		return nil
+	}
+
+	now := time.Now()
+	if result := s.db.Where("expires_at < ?", now).Delete(&entities.RevokedToken{}); result.Error != nil {
+		s.loadFailed = true
+		return result.Error
+	}
+
+	var revokedTokens []entities.RevokedToken
+	if result := s.db.Find(&revokedTokens); result.Error != nil {
+		s.loadFailed = true
+		return result.Error
+	}
+
+	var revocations []entities.UserTokensRevocation
+	if result := s.db.Find(&revocations); result.Error != nil {
+		s.loadFailed = true
+		return result.Error
+	}
+
+	s.tokens = make(map[string]time.Time, len(revokedTokens))
+	for _, t := range revokedTokens {
+		s.tokens[t.ID] = t.ExpiresAt
+	}
+	s.users = make(map[string]time.Time, len(revocations))
+	for _, r := range revocations {
+		s.users[r.UserID] = r.RevokedAt
+	}
+	s.lastLoad = now
+	s.loadFailed = false
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevocationStoreRevoke(t *testing.T) {
+	database := newTestDB(t)
+	s := NewRevocationStore(database, time.Hour)
+	now := time.Now()
+
+	assert.Nil(t, s.Revoke("jti-1", "user-id", now.Add(time.Hour)))
+	assert.Nil(t, s.Revoke("jti-1", "user-id", now.Add(time.Hour)), "a token can be revoked twice")
+
+	revoked, err := s.IsRevoked("jti-1", "user-id", now)
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+	revoked, err = s.IsRevoked("jti-2", "user-id", now)
+	assert.Nil(t, err)
+	assert.False(t, revoked)
+
+	// Revocations made by another instance are loaded from the database
+	other := NewRevocationStore(database, time.Hour)
+	revoked, err = other.IsRevoked("jti-1", "user-id", now)
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+
+	// Expired revoked tokens are deleted when revocations are loaded
+	assert.Nil(t, s.Revoke("jti-expired", "user-id", now.Add(-time.Minute)))
+	_, err = NewRevocationStore(database, time.Hour).IsRevoked("jti-2", "user-id", now)
+	assert.Nil(t, err)
+	var count int64
+	assert.Nil(t, database.Model(&entities.RevokedToken{}).Where("id = ?", "jti-expired").Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestRevocationStoreRevokeAllForUser(t *testing.T) {
+	database := newTestDB(t)
+	s := NewRevocationStore(database, time.Hour)
+
+	refreshToken := entities.RefreshToken{ID: "refresh-id", UserID: "user-id", FamilyID: "family-id", Hash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+	assert.Nil(t, database.Create(&refreshToken).Error)
+	session := entities.Session{ID: "family-id", UserID: "user-id", ExpiresAt: time.Now().Add(time.Hour)}
+	assert.Nil(t, database.Create(&session).Error)
+
+	assert.Nil(t, s.RevokeAllForUser("user-id"))
+	var revocation entities.UserTokensRevocation
+	assert.Nil(t, database.First(&revocation, "user_id = ?", "user-id").Error)
+	revokedAt := revocation.RevokedAt
+
+	for _, test := range []struct {
+		name     string
+		userID   string
+		issuedAt time.Time
+		revoked  bool
+	}{
+		{"issued before", "user-id", revokedAt.Add(-time.Second), true},
+		{"issued in the same second, before", "user-id", revokedAt.Add(-time.Millisecond), true},
+		{"issued in the same millisecond", "user-id", revokedAt, true},
+		{"issued in the same second, after", "user-id", revokedAt.Add(time.Millisecond), false},
+		{"issued once revoked", "user-id", time.Now(), false},
+		{"issued in seconds, in the same second", "user-id", time.Unix(revokedAt.Unix(), 0), true},
+		{"other user", "other-id", revokedAt.Add(-time.Second), false},
+	} {
+		revoked, err := s.IsRevoked("jti", test.userID, test.issuedAt)
+		assert.Nil(t, err)
+		assert.Equal(t, test.revoked, revoked, test.name)
+
+		// Same result once loaded from the database
+		revoked, err = NewRevocationStore(database, time.Hour).IsRevoked("jti", test.userID, test.issuedAt)
+		assert.Nil(t, err)
+		assert.Equal(t, test.revoked, revoked, test.name+" (database)")
+	}
+
+	assert.Nil(t, database.First(&refreshToken, "id = ?", "refresh-id").Error)
+	assert.NotNil(t, refreshToken.RevokedAt)
+	assert.Nil(t, database.First(&session, "id = ?", "family-id").Error)
+	assert.NotNil(t, session.TerminatedAt)
+}
@@ -0,0 +1,650 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/fabienbellanger/echo-boilerplate/utils/password"
+	"github.com/fabienbellanger/echo-boilerplate/utils/query"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MemoryStore is a thread-safe in-memory UserStore, for tests and demos.
+// It has the same semantics as the database store: usernames are unique, deleted users included,
+// users are soft deleted and passwords are hashed.
+type MemoryStore struct {
+	data    *memoryData
+	hasher  password.PasswordHasher
+	dummy   *password.Dummy
+	options Options
+	audit   *entities.AuditMeta
+	ctx     context.Context
+}
+
+// memoryData is shared by the copies of a MemoryStore.
+type memoryData struct {
+	mu        sync.RWMutex
+	users     map[string]entities.User
+	history   map[string][]entities.PasswordHistory // User ID => previous passwords, the newest first
+	auditLogs []entities.AuditLog
+}
+
+// NewMemory returns a new empty MemoryStore.
+// Timeouts are not used, but canceled contexts are honored.
+func NewMemory(hasher password.PasswordHasher, options Options) MemoryStore {
+	return MemoryStore{
+		data: &memoryData{
+			users:   make(map[string]entities.User),
+			history: make(map[string][]entities.PasswordHistory),
+		},
+		hasher:  hasher,
+		dummy:   password.NewDummy(hasher),
+		options: options,
+	}
+}
+
+// WithAudit returns a store recording its user changes in the audit trail.
+func (u MemoryStore) WithAudit(meta entities.AuditMeta) store.UserStorer {
+	u.audit = &meta
+	return u
+}
+
+// WithContext returns a store whose operations fail once the context is done.
+// It must be called before any operation, store.ErrMissingContext is returned otherwise.
+func (u MemoryStore) WithContext(ctx context.Context) store.UserStorer {
+	u.ctx = ctx
+	return u
+}
+
+// AuditLogs returns the audit logs recorded by the store.
+func (u MemoryStore) AuditLogs() []entities.AuditLog {
+	u.data.mu.RLock()
+	defer u.data.mu.RUnlock()
+
+	return append([]entities.AuditLog(nil), u.data.auditLogs...)
+}
+
+// contextErr returns the error of the store context, if it is missing or done.
+func (u MemoryStore) contextErr() error {
+	if u.ctx == nil {
+		return store.ErrMissingContext
+	}
+	return u.ctx.Err()
+}
+
+// recordAudit adds an audit log of a user change if the store is audited.
+// The lock must be held.
+func (u MemoryStore) recordAudit(action, userID string, before, after map[string]interface{}) {
+	if u.audit == nil {
+		return
+	}
+
+	log := entities.NewAuditLog(*u.audit, action, entities.AuditTargetUser, userID,
+		entities.NewAuditChanges(before, after, entities.AuditSecretFields...))
+	u.data.auditLogs = append(u.data.auditLogs, log)
+}
+
+// find returns a user which has not been deleted.
+// The lock must be held.
+func (u MemoryStore) find(id string) (entities.User, bool) {
+	user, ok := u.data.users[id]
+	if !ok || user.DeletedAt.Valid {
+		return entities.User{}, false
+	}
+	return user, true
+}
+
+// checkUsername returns store.ErrUsernameTaken if the username is used by another user than id.
+// The lock must be held.
+func (u MemoryStore) checkUsername(username, id string) error {
+	for _, user := range u.data.users {
+		if user.Username == username && user.ID != id {
+			return store.ErrUsernameTaken
+		}
+	}
+	return nil
+}
+
+// Login authenticate a user.
+// If the stored hash has been produced by an outdated algorithm, it is replaced.
+// store.ErrUserNotVerified is returned if the email address must be verified
+// and store.ErrInvalidPasswordHash if the stored hash cannot be parsed.
+// The password of an unknown user is verified against a dummy hash, so that the response time is the same.
+func (u MemoryStore) Login(username, pwd string) (entities.User, error) {
+	if err := u.contextErr(); err != nil {
+		return entities.User{}, err
+	}
+
+	user, err := u.GetUserByUsername(username)
+	if err != nil {
+		return user, err
+	}
+	if user.ID == "" {
+		u.dummy.Verify(pwd)
+		return user, gorm.ErrRecordNotFound
+	}
+
+	// Check password
+	// --------------
+	ok, err := u.hasher.Verify(pwd, user.Password)
+	if err != nil {
+		return entities.User{}, fmt.Errorf("%w: %v", store.ErrInvalidPasswordHash, err)
+	}
+	if !ok {
+		return entities.User{}, gorm.ErrRecordNotFound
+	}
+
+	if u.options.RequireVerifiedEmail && user.VerifiedAt == nil {
+		return entities.User{}, store.ErrUserNotVerified
+	}
+
+	// Rehash password
+	// ---------------
+	if u.hasher.NeedsRehash(user.Password) {
+		hashedPassword, err := u.hasher.Hash(pwd)
+		if err != nil {
+			return user, err
+		}
+
+		u.data.mu.Lock()
+		defer u.data.mu.Unlock()
+
+		if stored, ok := u.find(user.ID); ok {
+			stored.Password = hashedPassword
+			u.data.users[user.ID] = stored
+		}
+		user.Password = hashedPassword
+	}
+
+	return user, nil
+}
+
+// Register creates a new user, with the default role.
+// store.ErrUsernameTaken is returned if the username is already used
+// and store.ErrUnknownRole if the default role is not one of entities.DefaultRoles.
+func (u MemoryStore) Register(user *entities.User) error {
+	if err := u.contextErr(); err != nil {
+		return err
+	}
+
+	// UUID
+	// ----
+	user.ID = uuid.New().String()
+
+	// Hash password
+	// -------------
+	hashedPassword, err := u.hasher.Hash(user.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+
+	u.data.mu.Lock()
+	defer u.data.mu.Unlock()
+
+	if err := u.checkUsername(user.Username, user.ID); err != nil {
+		return err
+	}
+	if u.options.DefaultRole != "" {
+		if _, ok := entities.DefaultRoles[u.options.DefaultRole]; !ok {
+			return fmt.Errorf("%w: %s", store.ErrUnknownRole, u.options.DefaultRole)
+		}
+		user.Roles = []entities.Role{{Name: u.options.DefaultRole}}
+	}
+
+	now := time.Now()
+	user.Version = 1
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	stored := *user
+	stored.Roles = nil
+	u.data.users[user.ID] = stored
+
+	u.recordAudit(entities.AuditActionCreate, user.ID, nil, user.AuditFields())
+	return nil
+}
+
+// GetUsers returns a page of users and the total number of users matching the filters.
+// Up to params.FetchLimit() users are returned.
+// Filters are username, lastname and firstname (containing the value, case insensitive),
+// created_after and created_before.
+func (u MemoryStore) GetUsers(params query.Params) ([]entities.User, int64, error) {
+	return u.listUsers(params, false)
+}
+
+// GetDeletedUsers returns a page of soft deleted users and the total number of deleted users matching the filters.
+// Filters are those of GetUsers.
+func (u MemoryStore) GetDeletedUsers(params query.Params) ([]entities.User, int64, error) {
+	return u.listUsers(params, true)
+}
+
+// listUsers returns a page of users, deleted or not, and the total number of users matching the filters.
+func (u MemoryStore) listUsers(params query.Params, deleted bool) ([]entities.User, int64, error) {
+	var users []entities.User
+	if err := u.contextErr(); err != nil {
+		return users, 0, err
+	}
+
+	u.data.mu.RLock()
+	for _, user := range u.data.users {
+		if user.DeletedAt.Valid == deleted && matchUserFilters(user, params.Filters) {
+			users = append(users, user)
+		}
+	}
+	u.data.mu.RUnlock()
+
+	total := int64(len(users))
+	sort.Slice(users, func(i, j int) bool {
+		return compareUsers(users[i], userValues(users[j], params.Sort), params.Sort) < 0
+	})
+
+	// Pagination
+	// ----------
+	start := 0
+	if params.After != nil {
+		if len(params.After) == len(params.Sort) {
+			for start < len(users) && compareUsers(users[start], params.After, params.Sort) <= 0 {
+				start++
+			}
+		}
+	} else if params.Offset() > 0 {
+		start = params.Offset()
+	}
+	if start > len(users) {
+		start = len(users)
+	}
+	users = users[start:]
+	if params.Limit > 0 && len(users) > params.FetchLimit() {
+		users = users[:params.FetchLimit()]
+	}
+
+	return users, total, nil
+}
+
+// matchUserFilters checks that a user matches the filters of GetUsers.
+func matchUserFilters(user entities.User, filters map[string]interface{}) bool {
+	for column, value := range map[string]string{
+		"username":  user.Username,
+		"lastname":  user.Lastname,
+		"firstname": user.Firstname,
+	} {
+		if v, ok := filters[column].(string); ok && !strings.Contains(strings.ToLower(value), strings.ToLower(v)) {
+			return false
+		}
+	}
+	if v, ok := filters["created_after"].(time.Time); ok && user.CreatedAt.Before(v) {
+		return false
+	}
+	if v, ok := filters["created_before"].(time.Time); ok && !user.CreatedAt.Before(v) {
+		return false
+	}
+	return true
+}
+
+// userValues returns the values of the sorted fields of a user.
+func userValues(user entities.User, sort []query.Sort) []interface{} {
+	values := make([]interface{}, len(sort))
+	for i, s := range sort {
+		switch s.Field {
+		case "id":
+			values[i] = user.ID
+		case "username":
+			values[i] = user.Username
+		case "lastname":
+			values[i] = user.Lastname
+		case "firstname":
+			values[i] = user.Firstname
+		case "created_at":
+			values[i] = user.CreatedAt
+		case "updated_at":
+			values[i] = user.UpdatedAt
+		case "deleted_at":
+			values[i] = user.DeletedAt.Time
+		}
+	}
+	return values
+}
+
+// compareUsers compares a user to sort values, returning -1 if the user comes first, 1 if it comes after.
+func compareUsers(user entities.User, values []interface{}, sort []query.Sort) int {
+	for i, v := range userValues(user, sort) {
+		c := compareValues(v, values[i])
+		if sort[i].Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareValues compares two strings or two times.
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b)
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			if a.Before(b) {
+				return -1
+			}
+			if a.After(b) {
+				return 1
+			}
+		}
+	}
+	return 0
+}
+
+// GetUser returns a user from its ID.
+// The user ID is empty if no user is found.
+func (u MemoryStore) GetUser(id string) (entities.User, error) {
+	if err := u.contextErr(); err != nil {
+		return entities.User{}, err
+	}
+
+	u.data.mu.RLock()
+	defer u.data.mu.RUnlock()
+
+	user, _ := u.find(id)
+	return user, nil
+}
+
+// GetUserByUsername returns a user from its username.
+// The user ID is empty if no user is found.
+func (u MemoryStore) GetUserByUsername(username string) (entities.User, error) {
+	if err := u.contextErr(); err != nil {
+		return entities.User{}, err
+	}
+
+	u.data.mu.RLock()
+	defer u.data.mu.RUnlock()
+
+	for _, user := range u.data.users {
+		if user.Username == username && !user.DeletedAt.Valid {
+			return user, nil
+		}
+	}
+	return entities.User{}, nil
+}
+
+// DeleteUser soft deletes a user.
+func (u MemoryStore) DeleteUser(id string) error {
+	if err := u.contextErr(); err != nil {
+		return err
+	}
+
+	u.data.mu.Lock()
+	defer u.data.mu.Unlock()
+
+	user, ok := u.find(id)
+	if !ok {
+		return nil
+	}
+	user.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	u.data.users[id] = user
+
+	u.recordAudit(entities.AuditActionDelete, id, user.AuditFields(), nil)
+	return nil
+}
+
+// RestoreUser restores a soft deleted user.
+// The user ID is empty if no deleted user is found.
+func (u MemoryStore) RestoreUser(id string) (entities.User, error) {
+	if err := u.contextErr(); err != nil {
+		return entities.User{}, err
+	}
+
+	u.data.mu.Lock()
+	defer u.data.mu.Unlock()
+
+	user, ok := u.data.users[id]
+	if !ok || !user.DeletedAt.Valid {
+		return entities.User{}, nil
+	}
+	user.DeletedAt = gorm.DeletedAt{}
+	user.Version++
+	user.UpdatedAt = time.Now()
+	u.data.users[id] = user
+
+	u.recordAudit(entities.AuditActionRestore, id, nil, user.AuditFields())
+	return user, nil
+}
+
+// PurgeUser permanently deletes a user, soft deleted or not, with their password history.
+// The values of the user changes are redacted from the audit logs and the audit log of the purge
+// does not keep the user information. gorm.ErrRecordNotFound is returned if the user does not exist.
+func (u MemoryStore) PurgeUser(id string) error {
+	if err := u.contextErr(); err != nil {
+		return err
+	}
+
+	u.data.mu.Lock()
+	defer u.data.mu.Unlock()
+
+	if _, ok := u.data.users[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	u.purge(id)
+	return nil
+}
+
+// PurgeDeletedUsers permanently deletes the users soft deleted before a date and returns their number.
+func (u MemoryStore) PurgeDeletedUsers(before time.Time) (int64, error) {
+	if err := u.contextErr(); err != nil {
+		return 0, err
+	}
+
+	u.data.mu.Lock()
+	defer u.data.mu.Unlock()
+
+	var n int64
+	for id, user := range u.data.users {
+		if user.DeletedAt.Valid && user.DeletedAt.Time.Before(before) {
+			u.purge(id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// purge permanently deletes an existing user. The lock must be held.
+func (u MemoryStore) purge(id string) {
+	delete(u.data.users, id)
+	delete(u.data.history, id)
+
+	for i, log := range u.data.auditLogs {
+		if log.TargetType == entities.AuditTargetUser && log.TargetID == id {
+			u.data.auditLogs[i].Changes = log.Changes.Redacted()
+		}
+	}
+	u.recordAudit(entities.AuditActionPurge, id, nil, nil)
+}
+
+// UpdateUser updates user information.
+// The user ID is empty if no user is found.
+// version is the version of the user read by the client (0 to skip the check),
+// store.ErrVersionConflict is returned if the user has been changed since.
+// store.ErrPasswordReused is returned if the password is in the user history
+// and store.ErrUsernameTaken if the username is used by another user.
+func (u MemoryStore) UpdateUser(id string, userForm *entities.UserForm, version uint) (entities.User, error) {
+	return u.PatchUser(id, userForm.Patch(), version)
+}
+
+// PatchUser updates the user fields given by the patch, the password is only hashed if it is given.
+// The email address verification is reset when the username changes.
+// The user ID is empty if no user is found. Errors are those of UpdateUser.
+func (u MemoryStore) PatchUser(id string, patch *entities.UserPatch, version uint) (entities.User, error) {
+	if err := u.contextErr(); err != nil {
+		return entities.User{}, err
+	}
+
+	u.data.mu.Lock()
+	defer u.data.mu.Unlock()
+
+	before, ok := u.find(id)
+	if !ok {
+		return entities.User{}, nil
+	}
+	if version != 0 && before.Version != version {
+		return entities.User{}, store.ErrVersionConflict
+	}
+	pwd := patch.Password
+	if pwd != nil {
+		same, err := samePassword(u.hasher, *pwd, before.Password)
+		if err != nil {
+			return entities.User{}, err
+		}
+		if same {
+			pwd = nil
+		}
+	}
+	if patch.Username == nil && patch.Lastname == nil && patch.Firstname == nil && pwd == nil {
+		return before, nil
+	}
+
+	user := before
+	if patch.Username != nil {
+		if err := u.checkUsername(*patch.Username, id); err != nil {
+			return entities.User{}, err
+		}
+		if *patch.Username != before.Username {
+			user.VerifiedAt = nil
+		}
+		user.Username = *patch.Username
+	}
+	if patch.Lastname != nil {
+		user.Lastname = *patch.Lastname
+	}
+	if patch.Firstname != nil {
+		user.Firstname = *patch.Firstname
+	}
+	if pwd != nil {
+		hashedPassword, err := u.changePassword(before, *pwd)
+		if err != nil {
+			return entities.User{}, err
+		}
+		user.Password = hashedPassword
+	}
+	user.Version++
+	user.UpdatedAt = time.Now()
+	u.data.users[id] = user
+
+	u.recordAudit(entities.AuditActionUpdate, id, before.AuditFields(), user.AuditFields())
+	return user, nil
+}
+
+// VerifyUser marks the email address of a user as verified.
+func (u MemoryStore) VerifyUser(id string) error {
+	if err := u.contextErr(); err != nil {
+		return err
+	}
+
+	u.data.mu.Lock()
+	defer u.data.mu.Unlock()
+
+	user, ok := u.find(id)
+	if !ok || user.VerifiedAt != nil {
+		return nil
+	}
+	before := user.AuditFields()
+	now := time.Now()
+	user.VerifiedAt = &now
+	user.Version++
+	user.UpdatedAt = now
+	u.data.users[id] = user
+
+	u.recordAudit(entities.AuditActionUpdate, id, before, user.AuditFields())
+	return nil
+}
+
+// UpdatePassword replaces the password of a user.
+// store.ErrPasswordReused is returned if the password is in the user history.
+func (u MemoryStore) UpdatePassword(id, pwd string) error {
+	if err := u.contextErr(); err != nil {
+		return err
+	}
+
+	u.data.mu.Lock()
+	defer u.data.mu.Unlock()
+
+	user, ok := u.find(id)
+	if !ok {
+		return nil
+	}
+
+	hashedPassword, err := u.changePassword(user, pwd)
+	if err != nil {
+		return err
+	}
+	before := user.AuditFields()
+	user.Password = hashedPassword
+	user.Version++
+	user.UpdatedAt = time.Now()
+	u.data.users[id] = user
+
+	u.recordAudit(entities.AuditActionUpdate, id, before, user.AuditFields())
+	return nil
+}
+
+// changePassword checks that a new password is not in the user history and returns its hash.
+// The current password is moved to the history. The lock must be held.
+func (u MemoryStore) changePassword(user entities.User, pwd string) (string, error) {
+	if u.options.PasswordHistorySize <= 0 {
+		return u.hasher.Hash(pwd)
+	}
+
+	// The current password and the last ones
+	history := u.data.history[user.ID]
+	hashes := []string{user.Password}
+	for i := 0; i < len(history) && len(hashes) < u.options.PasswordHistorySize; i++ {
+		hashes = append(hashes, history[i].Hash)
+	}
+	for _, hash := range hashes {
+		same, err := samePassword(u.hasher, pwd, hash)
+		if err != nil {
+			return "", err
+		}
+		if same {
+			return "", store.ErrPasswordReused
+		}
+	}
+
+	hashedPassword, err := u.hasher.Hash(pwd)
+	if err != nil {
+		return "", err
+	}
+
+	// History
+	// -------
+	// The new entry and the kept ones make the history, the current password excepted
+	keep := u.options.PasswordHistorySize - 2
+	if keep < 0 {
+		keep = 0
+	}
+	if len(history) > keep {
+		history = history[:keep]
+	}
+	if u.options.PasswordHistorySize > 1 {
+		previous := entities.PasswordHistory{
+			ID:        uuid.New().String(),
+			UserID:    user.ID,
+			Hash:      user.Password,
+			CreatedAt: time.Now(),
+		}
+		history = append([]entities.PasswordHistory{previous}, history...)
+	}
+	u.data.history[user.ID] = history
+
+	return hashedPassword, nil
+}
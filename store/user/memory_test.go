@@ -0,0 +1,60 @@
+package user
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testUserStorer(t, func(t *testing.T, options Options) store.UserStorer {
+		return NewMemory(testHasher, options)
+	})
+}
+
+func TestMemoryStoreAudit(t *testing.T) {
+	m := NewMemory(testHasher, Options{})
+	s := m.WithContext(context.Background())
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	assert.Nil(t, s.WithAudit(entities.AuditMeta{ActorID: "admin-id"}).Register(&user))
+	assert.Nil(t, s.DeleteUser(user.ID))
+
+	logs := m.AuditLogs()
+	assert.Len(t, logs, 1)
+	assert.Equal(t, entities.AuditActionCreate, logs[0].Action)
+	assert.Equal(t, "admin-id", logs[0].ActorID)
+	assert.Equal(t, user.ID, logs[0].TargetID)
+
+	// Email verification and password reset
+	other := entities.User{Username: "jane@test.com", Password: "00000000", Lastname: "Doe", Firstname: "Jane"}
+	assert.Nil(t, s.Register(&other))
+	audited := s.WithAudit(entities.AuditMeta{ActorID: other.ID})
+	assert.Nil(t, audited.VerifyUser(other.ID))
+	assert.Nil(t, audited.VerifyUser(other.ID))
+	assert.Nil(t, audited.UpdatePassword(other.ID, "11111111"))
+
+	logs = m.AuditLogs()[1:]
+	if assert.Len(t, logs, 2) {
+		assert.Equal(t, entities.AuditActionUpdate, logs[0].Action)
+		assert.Equal(t, other.ID, logs[0].ActorID)
+		assert.Contains(t, logs[0].Changes, "verified_at")
+		assert.Equal(t, entities.AuditChanges{"password": {Before: entities.AuditRedacted, After: entities.AuditRedacted}}, logs[1].Changes)
+	}
+}
+
+func TestMemoryStorePurgeRedactsAudit(t *testing.T) {
+	s := NewMemory(testHasher, Options{})
+	audited := s.WithContext(context.Background()).WithAudit(entities.AuditMeta{ActorID: "admin-id"})
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	assert.Nil(t, audited.Register(&user))
+	assert.Nil(t, audited.PurgeUser(user.ID))
+
+	logs := s.AuditLogs()
+	if assert.Len(t, logs, 2) {
+		assert.Equal(t, entities.AuditChange{After: entities.AuditRedacted}, logs[0].Changes["username"])
+		assert.Equal(t, entities.AuditActionPurge, logs[1].Action)
+	}
+}
@@ -1,67 +1,283 @@
 package user
 
 import (
-	"crypto/sha512"
-	"encoding/hex"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/fabienbellanger/echo-boilerplate/db"
 	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/fabienbellanger/echo-boilerplate/utils/password"
+	"github.com/fabienbellanger/echo-boilerplate/utils/query"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+// Options of the UserStore.
+// Timeouts cancel the database queries: password hashing is not interrupted, but its duration counts.
+type Options struct {
+	RequireVerifiedEmail bool          // Users must have verified their email address to log in
+	PasswordHistorySize  int           // Number of last passwords, including the current one, which cannot be reused
+	ReadTimeout          time.Duration // Maximum duration of a read operation (0: no timeout)
+	WriteTimeout         time.Duration // Maximum duration of a write operation (0: no timeout)
+	DefaultRole          string        // Role assigned to registered users (empty: no role), MemoryStore only knows the default roles
+}
+
 // UserStore ...
 type UserStore struct {
-	db *db.DB
+	db      *db.DB
+	hasher  password.PasswordHasher
+	dummy   *password.Dummy
+	options Options
+	audit   *entities.AuditMeta
+	ctx     context.Context
 }
 
 // New returns a new UserStore
-func New(db *db.DB) UserStore {
-	return UserStore{db: db}
+func New(db *db.DB, hasher password.PasswordHasher, options Options) UserStore {
+	return UserStore{db: db, hasher: hasher, dummy: password.NewDummy(hasher), options: options}
 }
 
-// Login authenticate a user
-func (u UserStore) Login(username, password string) (user entities.User, err error) {
-	// Hash password
-	// -------------
-	passwordBytes := sha512.Sum512([]byte(password))
-	password = hex.EncodeToString(passwordBytes[:])
+// WithAudit returns a store recording its user changes in the audit trail.
+// Audit logs are written in the same transaction as the changes.
+func (u UserStore) WithAudit(meta entities.AuditMeta) store.UserStorer {
+	u.audit = &meta
+	return u
+}
+
+// WithContext returns a store whose database operations are canceled with the context.
+// It must be called before any operation, store.ErrMissingContext is returned otherwise.
+func (u UserStore) WithContext(ctx context.Context) store.UserStorer {
+	u.ctx = ctx
+	return u
+}
+
+// session returns a database session bound to the store context and limited to timeout.
+// The returned function must be called to release the context resources.
+func (u UserStore) session(timeout time.Duration) (*gorm.DB, context.CancelFunc, error) {
+	if u.ctx == nil {
+		return nil, nil, store.ErrMissingContext
+	}
+	if timeout <= 0 {
+		return u.db.WithContext(u.ctx), func() {}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(u.ctx, timeout)
+	return u.db.WithContext(ctx), cancel, nil
+}
+
+// recordAudit writes an audit log of a user change if the store is audited.
+func (u UserStore) recordAudit(tx *gorm.DB, action, userID string, before, after map[string]interface{}) error {
+	if u.audit == nil {
+		return nil
+	}
+
+	log := entities.NewAuditLog(*u.audit, action, entities.AuditTargetUser, userID,
+		entities.NewAuditChanges(before, after, entities.AuditSecretFields...))
+	return tx.Create(&log).Error
+}
+
+// Login authenticate a user.
+// If the stored hash has been produced by an outdated algorithm, it is replaced.
+// store.ErrUserNotVerified is returned if the email address must be verified
+// and store.ErrInvalidPasswordHash if the stored hash cannot be parsed.
+// The password of an unknown user is verified against a dummy hash, so that the response time is the same.
+func (u UserStore) Login(username, pwd string) (user entities.User, err error) {
+	sess, cancel, err := u.session(u.options.WriteTimeout)
+	if err != nil {
+		return user, err
+	}
+	defer cancel()
 
-	if result := u.db.Where(&entities.User{Username: username, Password: password}).First(&user); result.Error != nil {
+	if result := sess.Where(&entities.User{Username: username}).First(&user); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			u.dummy.Verify(pwd)
+		}
 		return user, result.Error
 	}
-	return user, err
+
+	// Check password
+	// --------------
+	ok, err := u.hasher.Verify(pwd, user.Password)
+	if err != nil {
+		return entities.User{}, fmt.Errorf("%w: %v", store.ErrInvalidPasswordHash, err)
+	}
+	if !ok {
+		return entities.User{}, gorm.ErrRecordNotFound
+	}
+
+	if u.options.RequireVerifiedEmail && user.VerifiedAt == nil {
+		return entities.User{}, store.ErrUserNotVerified
+	}
+
+	// Rehash password
+	// ---------------
+	if u.hasher.NeedsRehash(user.Password) {
+		hashedPassword, err := u.hasher.Hash(pwd)
+		if err != nil {
+			return user, err
+		}
+		if result := sess.Model(&user).UpdateColumn("password", hashedPassword); result.Error != nil {
+			return user, result.Error
+		}
+	}
+
+	return user, nil
 }
 
-// Register creates a new user in database
+// Register creates a new user in database, with the default role.
+// store.ErrUsernameTaken is returned if the username is already used
+// and store.ErrUnknownRole if the default role does not exist.
 func (u UserStore) Register(user *entities.User) error {
+	sess, cancel, err := u.session(u.options.WriteTimeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
 	// UUID
 	// ----
 	user.ID = uuid.New().String()
 
 	// Hash password
 	// -------------
-	passwordBytes := sha512.Sum512([]byte(user.Password))
-	user.Password = hex.EncodeToString(passwordBytes[:])
+	hashedPassword, err := u.hasher.Hash(user.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+	user.Version = 1
+
+	return sess.Transaction(func(tx *gorm.DB) error {
+		if err := checkUsername(tx, user.Username, user.ID); err != nil {
+			return err
+		}
+		if u.options.DefaultRole != "" {
+			var role entities.Role
+			if result := tx.Where("name = ?", u.options.DefaultRole).Limit(1).Find(&role); result.Error != nil {
+				return result.Error
+			}
+			if role.ID == 0 {
+				return fmt.Errorf("%w: %s", store.ErrUnknownRole, u.options.DefaultRole)
+			}
+			user.Roles = []entities.Role{role}
+		}
+		if result := tx.Create(&user); result.Error != nil {
+			return result.Error
+		}
+		return u.recordAudit(tx, entities.AuditActionCreate, user.ID, nil, user.AuditFields())
+	})
+}
 
-	if result := u.db.Create(&user); result.Error != nil {
+// checkUsername returns store.ErrUsernameTaken if the username is used by another user than id.
+// Deleted users keep their username, as in the unique index.
+func checkUsername(tx *gorm.DB, username, id string) error {
+	var count int64
+	result := tx.Unscoped().Model(&entities.User{}).Where("username = ? AND id <> ?", username, id).Count(&count)
+	if result.Error != nil {
 		return result.Error
 	}
+	if count > 0 {
+		return store.ErrUsernameTaken
+	}
 	return nil
 }
 
-// GetAllUsers lists all users.
-func (u UserStore) GetAllUsers() ([]entities.User, error) {
+// GetUsers returns a page of users and the total number of users matching the filters.
+// Up to params.FetchLimit() users are returned.
+// Filters are username, lastname and firstname (containing the value), created_after and created_before.
+func (u UserStore) GetUsers(params query.Params) ([]entities.User, int64, error) {
+	sess, cancel, err := u.session(u.options.ReadTimeout)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cancel()
+
+	return listUsers(sess, params)
+}
+
+// GetDeletedUsers returns a page of soft deleted users and the total number of deleted users matching the filters.
+// Filters are those of GetUsers.
+func (u UserStore) GetDeletedUsers(params query.Params) ([]entities.User, int64, error) {
+	sess, cancel, err := u.session(u.options.ReadTimeout)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cancel()
+
+	return listUsers(sess.Unscoped().Where("deleted_at IS NOT NULL").Session(&gorm.Session{}), params)
+}
+
+// listUsers returns a page of users and the total number of users matching the filters.
+func listUsers(tx *gorm.DB, params query.Params) ([]entities.User, int64, error) {
 	var users []entities.User
+	var total int64
 
-	if response := u.db.Find(&users); response.Error != nil {
-		return users, response.Error
+	filters := userFilters(params.Filters)
+	if result := tx.Model(&entities.User{}).Scopes(filters).Count(&total); result.Error != nil {
+		return users, 0, result.Error
+	}
+
+	q := tx.Scopes(filters, db.OrderBy(params.Sort)).Limit(params.FetchLimit())
+	if params.After != nil {
+		q = q.Scopes(db.After(params.Sort, params.After))
+	} else {
+		q = q.Offset(params.Offset())
+	}
+	if result := q.Find(&users); result.Error != nil {
+		return users, 0, result.Error
 	}
-	return users, nil
+	return users, total, nil
 }
 
+// userFilters creates a GORM scope filtering users.
+func userFilters(filters map[string]interface{}) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, column := range []string{"username", "lastname", "firstname"} {
+			if v, ok := filters[column].(string); ok {
+				db = db.Where(column+" LIKE ? ESCAPE '!'", "%"+likeEscaper.Replace(v)+"%")
+			}
+		}
+		if v, ok := filters["created_after"].(time.Time); ok {
+			db = db.Where("created_at >= ?", v)
+		}
+		if v, ok := filters["created_before"].(time.Time); ok {
+			db = db.Where("created_at < ?", v)
+		}
+		return db
+	}
+}
+
+// likeEscaper escapes the LIKE wildcards with "!", which is not an escape character in MySQL strings.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
 // GetUser returns a user from its ID.
 func (u UserStore) GetUser(id string) (user entities.User, err error) {
-	if result := u.db.Find(&user, "id = ?", id); result.Error != nil {
+	sess, cancel, err := u.session(u.options.ReadTimeout)
+	if err != nil {
+		return user, err
+	}
+	defer cancel()
+
+	if result := sess.Find(&user, "id = ?", id); result.Error != nil {
+		return user, result.Error
+	}
+	return user, err
+}
+
+// GetUserByUsername returns a user from its username.
+// The user ID is empty if no user is found.
+func (u UserStore) GetUserByUsername(username string) (user entities.User, err error) {
+	sess, cancel, err := u.session(u.options.ReadTimeout)
+	if err != nil {
+		return user, err
+	}
+	defer cancel()
+
+	if result := sess.Find(&user, "username = ?", username); result.Error != nil {
 		return user, result.Error
 	}
 	return user, err
@@ -69,32 +285,383 @@ func (u UserStore) GetUser(id string) (user entities.User, err error) {
 
 // DeleteUser deletes a user from database.
 func (u UserStore) DeleteUser(id string) error {
-	result := u.db.Delete(&entities.User{}, "id = ?", id)
+	sess, cancel, err := u.session(u.options.WriteTimeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return sess.Transaction(func(tx *gorm.DB) error {
+		var user entities.User
+		if result := tx.Find(&user, "id = ?", id); result.Error != nil {
+			return result.Error
+		}
+
+		if result := tx.Delete(&entities.User{}, "id = ?", id); result.Error != nil {
+			return result.Error
+		}
+		if user.ID == "" {
+			return nil
+		}
+		return u.recordAudit(tx, entities.AuditActionDelete, id, user.AuditFields(), nil)
+	})
+}
+
+// RestoreUser restores a soft deleted user.
+// The user ID is empty if no deleted user is found.
+func (u UserStore) RestoreUser(id string) (user entities.User, err error) {
+	sess, cancel, err := u.session(u.options.WriteTimeout)
+	if err != nil {
+		return user, err
+	}
+	defer cancel()
+
+	err = sess.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Model(&entities.User{}).Where("id = ? AND deleted_at IS NOT NULL", id).Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"version":    gorm.Expr("version + 1"),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if result := tx.Find(&user, "id = ?", id); result.Error != nil {
+			return result.Error
+		}
+		return u.recordAudit(tx, entities.AuditActionRestore, id, nil, user.AuditFields())
+	})
+	return user, err
+}
+
+// userDataModels lists the user data permanently deleted with the user.
+// Token revocations are kept: the access tokens issued before the purge must stay rejected until they expire.
+var userDataModels = []interface{}{
+	&entities.PasswordHistory{},
+	&entities.RefreshToken{},
+	&entities.OneTimeToken{},
+	&entities.TOTPFactor{},
+	&entities.RecoveryCode{},
+	&entities.APIKey{},
+	&entities.UserIdentity{},
+	&entities.Session{},
+}
+
+// PurgeUser permanently deletes a user, soft deleted or not, with their roles, refresh tokens, sessions, etc.
+// The values of the user changes are redacted from the audit trail and the audit log of the purge
+// does not keep the user information. gorm.ErrRecordNotFound is returned if the user does not exist.
+func (u UserStore) PurgeUser(id string) error {
+	sess, cancel, err := u.session(u.options.WriteTimeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return sess.Transaction(func(tx *gorm.DB) error {
+		var user entities.User
+		if result := tx.Unscoped().Find(&user, "id = ?", id); result.Error != nil {
+			return result.Error
+		}
+		if user.ID == "" {
+			return gorm.ErrRecordNotFound
+		}
+
+		if result := tx.Unscoped().Delete(&entities.User{}, "id = ?", id); result.Error != nil {
+			return result.Error
+		}
+		if result := tx.Exec("DELETE FROM user_roles WHERE user_id = ?", id); result.Error != nil {
+			return result.Error
+		}
+		for _, model := range userDataModels {
+			if result := tx.Delete(model, "user_id = ?", id); result.Error != nil {
+				return result.Error
+			}
+		}
+		usernameKey := entities.LoginThrottleUsernameKey(user.Username)
+		throttleKeys := []string{usernameKey, entities.ThrottleScopedKey(entities.ThrottleScopeEmail, usernameKey)}
+		if result := tx.Delete(&entities.LoginThrottle{}, "identifier IN ?", throttleKeys); result.Error != nil {
+			return result.Error
+		}
+		if err := redactAuditLogs(tx, id); err != nil {
+			return err
+		}
+		return u.recordAudit(tx, entities.AuditActionPurge, id, nil, nil)
+	})
+}
+
+// redactAuditLogs redacts the values of the changes of a user in the audit trail.
+// Audit logs are append-only: this erasure is the only update allowed, the hooks are skipped on purpose.
+func redactAuditLogs(tx *gorm.DB, userID string) error {
+	var logs []entities.AuditLog
+	result := tx.Where("target_type = ? AND target_id = ?", entities.AuditTargetUser, userID).Find(&logs)
 	if result.Error != nil {
 		return result.Error
 	}
+
+	erasure := tx.Session(&gorm.Session{SkipHooks: true})
+	for _, log := range logs {
+		if len(log.Changes) == 0 {
+			continue
+		}
+		if result := erasure.Model(&log).Select("changes").Updates(entities.AuditLog{Changes: log.Changes.Redacted()}); result.Error != nil {
+			return result.Error
+		}
+	}
 	return nil
 }
 
-// UpdateUser updates user information.
-func (u UserStore) UpdateUser(id string, userForm *entities.UserForm) (user entities.User, err error) {
-	// Hash password
-	// -------------
-	hashedPassword := sha512.Sum512([]byte(userForm.Password))
+// PurgeDeletedUsers permanently deletes the users soft deleted before a date and returns their number.
+// Each user is purged in its own transaction.
+func (u UserStore) PurgeDeletedUsers(before time.Time) (int64, error) {
+	sess, cancel, err := u.session(u.options.ReadTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer cancel()
 
-	result := u.db.Model(&entities.User{}).Where("id = ?", id).Select("lastname", "firstname", "username", "password").Updates(entities.User{
-		Lastname:  userForm.Lastname,
-		Firstname: userForm.Firstname,
-		Username:  userForm.Username,
-		Password:  hex.EncodeToString(hashedPassword[:]),
-	})
-	if result.Error != nil {
-		return user, result.Error
+	var ids []string
+	if result := sess.Unscoped().Model(&entities.User{}).Where("deleted_at < ?", before).Pluck("id", &ids); result.Error != nil {
+		return 0, result.Error
 	}
 
-	user, err = u.GetUser(id)
+	var n int64
+	for _, id := range ids {
+		if err := u.PurgeUser(id); err != nil {
+			// Already purged by another process
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// UpdateUser updates user information.
+// version is the version of the user read by the client (0 to skip the check),
+// store.ErrVersionConflict is returned if the user has been changed since.
+// store.ErrPasswordReused is returned if the password is in the user history
+// and store.ErrUsernameTaken if the username is used by another user.
+func (u UserStore) UpdateUser(id string, userForm *entities.UserForm, version uint) (entities.User, error) {
+	return u.PatchUser(id, userForm.Patch(), version)
+}
+
+// PatchUser updates the user fields given by the patch, the password is only hashed if it is given and changed.
+// The email address verification is reset when the username changes.
+// The user ID is empty if no user is found. Errors are those of UpdateUser.
+func (u UserStore) PatchUser(id string, patch *entities.UserPatch, version uint) (user entities.User, err error) {
+	sess, cancel, err := u.session(u.options.WriteTimeout)
 	if err != nil {
 		return user, err
 	}
+	defer cancel()
+
+	err = sess.Transaction(func(tx *gorm.DB) error {
+		var before entities.User
+		if result := tx.Find(&before, "id = ?", id); result.Error != nil {
+			return result.Error
+		}
+		if before.ID == "" {
+			return nil
+		}
+		if version != 0 && before.Version != version {
+			return store.ErrVersionConflict
+		}
+
+		changes := make(map[string]interface{})
+		if patch.Username != nil {
+			if err := checkUsername(tx, *patch.Username, id); err != nil {
+				return err
+			}
+			changes["username"] = *patch.Username
+			// A new email address must be verified again
+			if *patch.Username != before.Username {
+				changes["verified_at"] = nil
+			}
+		}
+		if patch.Lastname != nil {
+			changes["lastname"] = *patch.Lastname
+		}
+		if patch.Firstname != nil {
+			changes["firstname"] = *patch.Firstname
+		}
+		if patch.Password != nil {
+			same, err := samePassword(u.hasher, *patch.Password, before.Password)
+			if err != nil {
+				return err
+			}
+			if !same {
+				hashedPassword, err := u.changePassword(tx, before, *patch.Password)
+				if err != nil {
+					return err
+				}
+				changes["password"] = hashedPassword
+			}
+		}
+		if len(changes) == 0 {
+			user = before
+			return nil
+		}
+		changes["version"] = before.Version + 1
+
+		// The version condition prevents concurrent updates
+		result := tx.Model(&entities.User{}).Where("id = ? AND version = ?", id, before.Version).Updates(changes)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return store.ErrVersionConflict
+		}
+
+		if result := tx.Find(&user, "id = ?", id); result.Error != nil {
+			return result.Error
+		}
+		return u.recordAudit(tx, entities.AuditActionUpdate, id, before.AuditFields(), user.AuditFields())
+	})
 	return user, err
 }
+
+// VerifyUser marks the email address of a user as verified.
+func (u UserStore) VerifyUser(id string) error {
+	sess, cancel, err := u.session(u.options.WriteTimeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return sess.Transaction(func(tx *gorm.DB) error {
+		var before entities.User
+		if result := tx.Find(&before, "id = ?", id); result.Error != nil {
+			return result.Error
+		}
+		if before.ID == "" || before.VerifiedAt != nil {
+			return nil
+		}
+
+		result := tx.Model(&entities.User{}).Where("id = ? AND verified_at IS NULL", id).Updates(map[string]interface{}{
+			"verified_at": time.Now(),
+			"version":     gorm.Expr("version + 1"),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		// Already verified concurrently
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		var user entities.User
+		if result := tx.Find(&user, "id = ?", id); result.Error != nil {
+			return result.Error
+		}
+		return u.recordAudit(tx, entities.AuditActionUpdate, id, before.AuditFields(), user.AuditFields())
+	})
+}
+
+// UpdatePassword replaces the password of a user.
+// store.ErrPasswordReused is returned if the password is in the user history.
+func (u UserStore) UpdatePassword(id, pwd string) error {
+	sess, cancel, err := u.session(u.options.WriteTimeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return sess.Transaction(func(tx *gorm.DB) error {
+		var user entities.User
+		if result := tx.Find(&user, "id = ?", id); result.Error != nil {
+			return result.Error
+		}
+		if user.ID == "" {
+			return nil
+		}
+
+		hashedPassword, err := u.changePassword(tx, user, pwd)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&entities.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"password": hashedPassword,
+			"version":  gorm.Expr("version + 1"),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		before := user.AuditFields()
+		user.Password = hashedPassword
+		return u.recordAudit(tx, entities.AuditActionUpdate, id, before, user.AuditFields())
+	})
+}
+
+// samePassword returns true if the password is the one of the hash.
+// Malformed hashes and hashes of unknown algorithms do not match.
+func samePassword(hasher password.PasswordHasher, pwd, hash string) (bool, error) {
+	ok, err := hasher.Verify(pwd, hash)
+	if err != nil && !errors.Is(err, password.ErrUnknownHashFormat) && !errors.Is(err, password.ErrInvalidHash) {
+		return false, err
+	}
+	return ok, nil
+}
+
+// changePassword checks that a new password is not in the user history and returns its hash.
+// The current password is moved to the history.
+func (u UserStore) changePassword(tx *gorm.DB, user entities.User, pwd string) (string, error) {
+	if u.options.PasswordHistorySize <= 0 {
+		return u.hasher.Hash(pwd)
+	}
+
+	var history []entities.PasswordHistory
+	if result := tx.Where("user_id = ?", user.ID).Order("created_at DESC").Find(&history); result.Error != nil {
+		return "", result.Error
+	}
+
+	// The current password and the last ones
+	hashes := []string{user.Password}
+	for i := 0; i < len(history) && len(hashes) < u.options.PasswordHistorySize; i++ {
+		hashes = append(hashes, history[i].Hash)
+	}
+	for _, hash := range hashes {
+		same, err := samePassword(u.hasher, pwd, hash)
+		if err != nil {
+			return "", err
+		}
+		if same {
+			return "", store.ErrPasswordReused
+		}
+	}
+
+	// History
+	// -------
+	if u.options.PasswordHistorySize > 1 {
+		previous := entities.PasswordHistory{
+			ID:     uuid.New().String(),
+			UserID: user.ID,
+			Hash:   user.Password,
+		}
+		if result := tx.Create(&previous); result.Error != nil {
+			return "", result.Error
+		}
+	}
+
+	// The new entry and the kept ones make the history, the current password excepted
+	keep := u.options.PasswordHistorySize - 2
+	if keep < 0 {
+		keep = 0
+	}
+	if len(history) > keep {
+		ids := make([]string, 0, len(history)-keep)
+		for _, h := range history[keep:] {
+			ids = append(ids, h.ID)
+		}
+		if result := tx.Delete(&entities.PasswordHistory{}, "id IN ?", ids); result.Error != nil {
+			return "", result.Error
+		}
+	}
+
+	return u.hasher.Hash(pwd)
+}
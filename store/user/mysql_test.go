@@ -1 +1,202 @@
 package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/db"
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/migrations"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	storeToken "github.com/fabienbellanger/echo-boilerplate/store/token"
+	"github.com/fabienbellanger/echo-boilerplate/utils/password"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// newTestDB returns an empty in-memory SQLite database, with all the migrations applied.
+func newTestDB(t *testing.T) *db.DB {
+	database, err := db.New(&db.DatabaseConfig{
+		Driver:   db.DriverSQLite,
+		Database: ":memory:",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := db.NewMigrator(database, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return database
+}
+
+func TestUserStore(t *testing.T) {
+	testUserStorer(t, func(t *testing.T, options Options) store.UserStorer {
+		database := newTestDB(t)
+		for name := range entities.DefaultRoles {
+			assert.Nil(t, database.Create(&entities.Role{Name: name}).Error)
+		}
+		return New(database, testHasher, options)
+	})
+}
+
+func TestUserStoreAudit(t *testing.T) {
+	database := newTestDB(t)
+	s := New(database, testHasher, Options{}).WithContext(context.Background())
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	assert.Nil(t, s.WithAudit(entities.AuditMeta{ActorID: "admin-id"}).Register(&user))
+	assert.Nil(t, s.DeleteUser(user.ID))
+
+	var logs []entities.AuditLog
+	assert.Nil(t, database.Order("created_at").Find(&logs).Error)
+	assert.Len(t, logs, 1)
+	assert.Equal(t, entities.AuditActionCreate, logs[0].Action)
+	assert.Equal(t, "admin-id", logs[0].ActorID)
+	assert.Equal(t, user.ID, logs[0].TargetID)
+
+	// Email verification and password reset
+	other := entities.User{Username: "jane@test.com", Password: "00000000", Lastname: "Doe", Firstname: "Jane"}
+	assert.Nil(t, s.Register(&other))
+	audited := s.WithAudit(entities.AuditMeta{ActorID: other.ID})
+	assert.Nil(t, audited.VerifyUser(other.ID))
+	assert.Nil(t, audited.VerifyUser(other.ID))
+	assert.Nil(t, audited.UpdatePassword(other.ID, "11111111"))
+
+	logs = nil
+	assert.Nil(t, database.Order("created_at").Find(&logs, "target_id = ?", other.ID).Error)
+	if assert.Len(t, logs, 2) {
+		assert.Equal(t, entities.AuditActionUpdate, logs[0].Action)
+		assert.Equal(t, other.ID, logs[0].ActorID)
+		assert.Contains(t, logs[0].Changes, "verified_at")
+		assert.Equal(t, entities.AuditChanges{"password": {Before: entities.AuditRedacted, After: entities.AuditRedacted}}, logs[1].Changes)
+	}
+}
+
+// countingHasher counts the password verifications.
+type countingHasher struct {
+	password.PasswordHasher
+	verifications int
+}
+
+func (h *countingHasher) Verify(pwd, encoded string) (bool, error) {
+	h.verifications++
+	return h.PasswordHasher.Verify(pwd, encoded)
+}
+
+func TestUserStoreLoginHashes(t *testing.T) {
+	database := newTestDB(t)
+	hasher := &countingHasher{PasswordHasher: testHasher}
+	s := New(database, hasher, Options{}).WithContext(context.Background())
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	assert.Nil(t, s.Register(&user))
+
+	// The password of an unknown user is hashed too
+	_, err := s.Login("unknown@test.com", "00000000")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Equal(t, 1, hasher.verifications)
+
+	assert.Nil(t, database.Model(&entities.User{}).Where("id = ?", user.ID).UpdateColumn("password", "$2a$malformed").Error)
+	_, err = s.Login("john@test.com", "00000000")
+	assert.ErrorIs(t, err, store.ErrInvalidPasswordHash)
+}
+
+func TestUserStorePurgeUserData(t *testing.T) {
+	database := newTestDB(t)
+	s := New(database, testHasher, Options{PasswordHistorySize: 3}).WithContext(context.Background()).WithAudit(entities.AuditMeta{ActorID: "admin-id"})
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	assert.Nil(t, s.Register(&user))
+	assert.Nil(t, s.UpdatePassword(user.ID, "11111111"))
+	assert.Nil(t, database.Exec("INSERT INTO user_roles (user_id, role_id) VALUES (?, 1)", user.ID).Error)
+	usernameKey := entities.LoginThrottleUsernameKey(user.Username)
+	for _, identifier := range []string{usernameKey, entities.ThrottleScopedKey(entities.ThrottleScopeEmail, usernameKey), entities.LoginThrottleIPKey("127.0.0.1")} {
+		assert.Nil(t, database.Create(&entities.LoginThrottle{Identifier: identifier, Failures: 1}).Error)
+	}
+
+	issuedAt := time.Now().Add(-time.Minute)
+	revocations := storeToken.NewRevocationStore(database, 0)
+	assert.Nil(t, revocations.RevokeAllForUser(user.ID))
+
+	assert.Nil(t, s.PurgeUser(user.ID))
+	assert.ErrorIs(t, s.PurgeUser(user.ID), gorm.ErrRecordNotFound)
+
+	// The tokens revoked before the purge are still rejected
+	revoked, err := storeToken.NewRevocationStore(database, 0).IsRevoked("jti", user.ID, issuedAt)
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+
+	var count int64
+	assert.Nil(t, database.Unscoped().Model(&entities.User{}).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+	assert.Nil(t, database.Model(&entities.PasswordHistory{}).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+	assert.Nil(t, database.Table("user_roles").Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+
+	// Only the IP address throttle is kept
+	var throttles []entities.LoginThrottle
+	assert.Nil(t, database.Find(&throttles).Error)
+	if assert.Len(t, throttles, 1) {
+		assert.Equal(t, entities.LoginThrottleIPKey("127.0.0.1"), throttles[0].Identifier)
+	}
+
+	// The audit trail keeps the actions, not the user data
+	var logs []entities.AuditLog
+	assert.Nil(t, database.Order("created_at").Find(&logs, "target_id = ?", user.ID).Error)
+	if assert.Len(t, logs, 3) {
+		assert.Equal(t, entities.AuditActionCreate, logs[0].Action)
+		assert.Equal(t, entities.AuditChange{After: entities.AuditRedacted}, logs[0].Changes["username"])
+		assert.Equal(t, entities.AuditChange{After: entities.AuditRedacted}, logs[0].Changes["lastname"])
+		assert.Equal(t, entities.AuditActionUpdate, logs[1].Action)
+		assert.Equal(t, entities.AuditChanges{"password": {Before: entities.AuditRedacted, After: entities.AuditRedacted}}, logs[1].Changes)
+		assert.Equal(t, entities.AuditActionPurge, logs[2].Action)
+		assert.Empty(t, logs[2].Changes)
+	}
+
+	// Audit logs are still append-only
+	assert.ErrorIs(t, database.Delete(&logs[0]).Error, entities.ErrAuditLogAppendOnly)
+}
+
+func TestBackfillUsersVerifiedAt(t *testing.T) {
+	database, err := db.New(&db.DatabaseConfig{Driver: db.DriverSQLite, Database: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := db.NewMigrator(database, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = migrator.Up(2)
+	assert.Nil(t, err)
+
+	// A user created before email verification and a user waiting for its verification
+	s := New(database, testHasher, Options{}).WithContext(context.Background())
+	former := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	assert.Nil(t, s.Register(&former))
+	pending := entities.User{Username: "jane@test.com", Password: "00000000", Lastname: "Doe", Firstname: "Jane"}
+	assert.Nil(t, s.Register(&pending))
+	assert.Nil(t, database.Create(&entities.OneTimeToken{
+		ID: "token-id", UserID: pending.ID, Purpose: entities.TokenPurposeEmailVerification, Hash: "hash",
+	}).Error)
+
+	applied, err := migrator.Up(0)
+	assert.Nil(t, err)
+	if assert.Len(t, applied, 1) {
+		assert.Equal(t, "backfill_users_verified_at", applied[0].Name)
+	}
+
+	user, err := s.GetUser(former.ID)
+	assert.Nil(t, err)
+	assert.NotNil(t, user.VerifiedAt)
+	user, err = s.GetUser(pending.ID)
+	assert.Nil(t, err)
+	assert.Nil(t, user.VerifiedAt)
+}
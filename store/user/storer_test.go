@@ -0,0 +1,458 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/entities"
+	"github.com/fabienbellanger/echo-boilerplate/store"
+	"github.com/fabienbellanger/echo-boilerplate/utils/password"
+	"github.com/fabienbellanger/echo-boilerplate/utils/query"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// testHasher is fast, tests do not need a strong hash.
+var testHasher = password.NewBcrypt(4)
+
+// storeFactory returns a new empty store, without context.
+type storeFactory func(t *testing.T, options Options) store.UserStorer
+
+// testUserStorer is the conformance suite of the store.UserStorer implementations.
+func testUserStorer(t *testing.T, factory storeFactory) {
+	newStore := func(t *testing.T, options Options) store.UserStorer {
+		return factory(t, options).WithContext(context.Background())
+	}
+	register := func(t *testing.T, s store.UserStorer, username, lastname, firstname string) entities.User {
+		user := entities.User{Username: username, Password: "password-" + username, Lastname: lastname, Firstname: firstname}
+		assert.Nil(t, s.Register(&user))
+		return user
+	}
+
+	t.Run("Register", func(t *testing.T) {
+		s := newStore(t, Options{})
+		user := register(t, s, "john@test.com", "Doe", "John")
+		assert.NotEmpty(t, user.ID)
+
+		stored, err := s.GetUser(user.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, "john@test.com", stored.Username)
+		assert.Equal(t, "Doe", stored.Lastname)
+		assert.Nil(t, stored.VerifiedAt)
+
+		ok, err := testHasher.Verify("password-john@test.com", stored.Password)
+		assert.Nil(t, err)
+		assert.True(t, ok, "the password must be hashed")
+
+		duplicate := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "Jane"}
+		assert.ErrorIs(t, s.Register(&duplicate), store.ErrUsernameTaken)
+	})
+
+	t.Run("RegisterDefaultRole", func(t *testing.T) {
+		s := newStore(t, Options{DefaultRole: "unknown"})
+		user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+		assert.ErrorIs(t, s.Register(&user), store.ErrUnknownRole)
+		found, err := s.GetUserByUsername("john@test.com")
+		assert.Nil(t, err)
+		assert.Empty(t, found.ID, "the user is not created")
+
+		s = newStore(t, Options{DefaultRole: entities.RoleUser})
+		user = register(t, s, "john@test.com", "Doe", "John")
+		if assert.Len(t, user.Roles, 1) {
+			assert.Equal(t, entities.RoleUser, user.Roles[0].Name)
+		}
+
+		s = newStore(t, Options{})
+		user = register(t, s, "john@test.com", "Doe", "John")
+		assert.Empty(t, user.Roles)
+	})
+
+	t.Run("GetUser", func(t *testing.T) {
+		s := newStore(t, Options{})
+		user := register(t, s, "john@test.com", "Doe", "John")
+
+		found, err := s.GetUserByUsername("john@test.com")
+		assert.Nil(t, err)
+		assert.Equal(t, user.ID, found.ID)
+
+		found, err = s.GetUser("unknown")
+		assert.Nil(t, err)
+		assert.Empty(t, found.ID)
+
+		found, err = s.GetUserByUsername("unknown@test.com")
+		assert.Nil(t, err)
+		assert.Empty(t, found.ID)
+	})
+
+	t.Run("Login", func(t *testing.T) {
+		s := newStore(t, Options{RequireVerifiedEmail: true})
+		user := register(t, s, "john@test.com", "Doe", "John")
+
+		_, err := s.Login("john@test.com", "bad password")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		_, err = s.Login("unknown@test.com", "password-john@test.com")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		_, err = s.Login("john@test.com", "password-john@test.com")
+		assert.ErrorIs(t, err, store.ErrUserNotVerified)
+
+		assert.Nil(t, s.VerifyUser(user.ID))
+		logged, err := s.Login("john@test.com", "password-john@test.com")
+		assert.Nil(t, err)
+		assert.Equal(t, user.ID, logged.ID)
+		assert.NotNil(t, logged.VerifiedAt)
+	})
+
+	t.Run("DeleteUser", func(t *testing.T) {
+		s := newStore(t, Options{})
+		user := register(t, s, "john@test.com", "Doe", "John")
+		register(t, s, "jane@test.com", "Doe", "Jane")
+
+		assert.Nil(t, s.DeleteUser(user.ID))
+		assert.Nil(t, s.DeleteUser("unknown"))
+
+		found, err := s.GetUser(user.ID)
+		assert.Nil(t, err)
+		assert.Empty(t, found.ID)
+
+		_, err = s.Login("john@test.com", "password-john@test.com")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+		users, total, err := s.GetUsers(query.Params{Page: 1, Limit: 10, Sort: []query.Sort{{Field: "id"}}})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Len(t, users, 1)
+
+		// Soft deleted users keep their username
+		duplicate := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+		assert.ErrorIs(t, s.Register(&duplicate), store.ErrUsernameTaken)
+	})
+
+	t.Run("RestoreUser", func(t *testing.T) {
+		s := newStore(t, Options{})
+		user := register(t, s, "john@test.com", "Doe", "John")
+		register(t, s, "jane@test.com", "Doe", "Jane")
+
+		restored, err := s.RestoreUser(user.ID)
+		assert.Nil(t, err)
+		assert.Empty(t, restored.ID, "only deleted users can be restored")
+
+		assert.Nil(t, s.DeleteUser(user.ID))
+		deleted, total, err := s.GetDeletedUsers(query.Params{Page: 1, Limit: 10, Sort: []query.Sort{{Field: "deleted_at", Desc: true}, {Field: "id"}}})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), total)
+		if assert.Len(t, deleted, 1) {
+			assert.Equal(t, user.ID, deleted[0].ID)
+			assert.True(t, deleted[0].DeletedAt.Valid)
+		}
+
+		restored, err = s.RestoreUser(user.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, user.ID, restored.ID)
+		assert.False(t, restored.DeletedAt.Valid)
+
+		_, err = s.Login("john@test.com", "password-john@test.com")
+		assert.Nil(t, err)
+		_, total, err = s.GetDeletedUsers(query.Params{Page: 1, Limit: 10, Sort: []query.Sort{{Field: "id"}}})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), total)
+	})
+
+	t.Run("PurgeUser", func(t *testing.T) {
+		s := newStore(t, Options{})
+		user := register(t, s, "john@test.com", "Doe", "John")
+		other := register(t, s, "jane@test.com", "Doe", "Jane")
+
+		assert.Nil(t, s.PurgeUser(user.ID))
+		assert.ErrorIs(t, s.PurgeUser("unknown"), gorm.ErrRecordNotFound)
+		assert.ErrorIs(t, s.PurgeUser(user.ID), gorm.ErrRecordNotFound)
+
+		restored, err := s.RestoreUser(user.ID)
+		assert.Nil(t, err)
+		assert.Empty(t, restored.ID)
+
+		// The username can be used again
+		register(t, s, "john@test.com", "Doe", "John")
+
+		found, err := s.GetUser(other.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, other.ID, found.ID)
+	})
+
+	t.Run("PurgeDeletedUsers", func(t *testing.T) {
+		s := newStore(t, Options{})
+		deleted := register(t, s, "john@test.com", "Doe", "John")
+		active := register(t, s, "jane@test.com", "Doe", "Jane")
+		assert.Nil(t, s.DeleteUser(deleted.ID))
+
+		n, err := s.PurgeDeletedUsers(time.Now().Add(-time.Hour))
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), n)
+
+		n, err = s.PurgeDeletedUsers(time.Now().Add(time.Hour))
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), n)
+
+		_, total, err := s.GetDeletedUsers(query.Params{Page: 1, Limit: 10, Sort: []query.Sort{{Field: "id"}}})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), total)
+
+		found, err := s.GetUser(active.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, active.ID, found.ID)
+	})
+
+	t.Run("UpdateUser", func(t *testing.T) {
+		s := newStore(t, Options{PasswordHistorySize: 2})
+		user := register(t, s, "john@test.com", "Doe", "John")
+		register(t, s, "jane@test.com", "Doe", "Jane")
+
+		updated, err := s.UpdateUser(user.ID, &entities.UserForm{
+			Username: "johnny@test.com", Password: "new password", Lastname: "Smith", Firstname: "Johnny",
+		}, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, user.ID, updated.ID)
+		assert.Equal(t, "johnny@test.com", updated.Username)
+		assert.Equal(t, "Smith", updated.Lastname)
+		assert.Equal(t, "Johnny", updated.Firstname)
+
+		_, err = s.Login("johnny@test.com", "new password")
+		assert.Nil(t, err)
+
+		_, err = s.UpdateUser(user.ID, &entities.UserForm{
+			Username: "johnny@test.com", Password: "password-john@test.com", Lastname: "Smith", Firstname: "Johnny",
+		}, 0)
+		assert.ErrorIs(t, err, store.ErrPasswordReused)
+
+		_, err = s.UpdateUser(user.ID, &entities.UserForm{
+			Username: "jane@test.com", Password: "other password", Lastname: "Smith", Firstname: "Johnny",
+		}, 0)
+		assert.ErrorIs(t, err, store.ErrUsernameTaken)
+
+		updated, err = s.UpdateUser("unknown", &entities.UserForm{Username: "unknown@test.com", Password: "other password"}, 0)
+		assert.Nil(t, err)
+		assert.Empty(t, updated.ID)
+	})
+
+	t.Run("UsernameChangeResetsVerification", func(t *testing.T) {
+		s := newStore(t, Options{})
+		user := register(t, s, "john@test.com", "Doe", "John")
+		assert.Nil(t, s.VerifyUser(user.ID))
+
+		firstname := "Johnny"
+		patched, err := s.PatchUser(user.ID, &entities.UserPatch{Firstname: &firstname}, 0)
+		assert.Nil(t, err)
+		assert.NotNil(t, patched.VerifiedAt)
+
+		username := "john@test.com"
+		patched, err = s.PatchUser(user.ID, &entities.UserPatch{Username: &username}, 0)
+		assert.Nil(t, err)
+		assert.NotNil(t, patched.VerifiedAt, "an unchanged username stays verified")
+
+		username = "johnny@test.com"
+		patched, err = s.PatchUser(user.ID, &entities.UserPatch{Username: &username}, 0)
+		assert.Nil(t, err)
+		assert.Nil(t, patched.VerifiedAt)
+	})
+
+	t.Run("Version", func(t *testing.T) {
+		s := newStore(t, Options{})
+		user := register(t, s, "john@test.com", "Doe", "John")
+		assert.Equal(t, uint(1), user.Version)
+
+		form := &entities.UserForm{Username: "john@test.com", Password: "new password", Lastname: "Doe", Firstname: "Johnny"}
+		updated, err := s.UpdateUser(user.ID, form, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(2), updated.Version)
+
+		// Update of an outdated version
+		form.Password = "other password"
+		_, err = s.UpdateUser(user.ID, form, 1)
+		assert.ErrorIs(t, err, store.ErrVersionConflict)
+
+		assert.Nil(t, s.VerifyUser(user.ID))
+		assert.Nil(t, s.UpdatePassword(user.ID, "password 3"))
+		found, err := s.GetUser(user.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(4), found.Version)
+		assert.Equal(t, "Johnny", found.Firstname)
+
+		assert.Nil(t, s.DeleteUser(user.ID))
+		restored, err := s.RestoreUser(user.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(5), restored.Version)
+	})
+
+	t.Run("PatchUser", func(t *testing.T) {
+		s := newStore(t, Options{PasswordHistorySize: 2})
+		user := register(t, s, "john@test.com", "Doe", "John")
+		stored, err := s.GetUser(user.ID)
+		assert.Nil(t, err)
+
+		firstname := "Johnny"
+		patched, err := s.PatchUser(user.ID, &entities.UserPatch{Firstname: &firstname}, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, "Johnny", patched.Firstname)
+		assert.Equal(t, "Doe", patched.Lastname)
+		assert.Equal(t, "john@test.com", patched.Username)
+		assert.Equal(t, stored.Password, patched.Password, "the password must not be hashed again")
+		assert.Equal(t, uint(2), patched.Version)
+
+		// Empty patch
+		patched, err = s.PatchUser(user.ID, &entities.UserPatch{}, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(2), patched.Version)
+
+		// Unchanged password
+		pwd := "password-john@test.com"
+		patched, err = s.PatchUser(user.ID, &entities.UserPatch{Password: &pwd}, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, stored.Password, patched.Password)
+		assert.Equal(t, uint(2), patched.Version)
+
+		pwd = "new password"
+		_, err = s.PatchUser(user.ID, &entities.UserPatch{Password: &pwd}, 1)
+		assert.ErrorIs(t, err, store.ErrVersionConflict)
+		patched, err = s.PatchUser(user.ID, &entities.UserPatch{Password: &pwd}, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, "Johnny", patched.Firstname)
+		_, err = s.Login("john@test.com", "new password")
+		assert.Nil(t, err)
+
+		pwd = "password-john@test.com"
+		_, err = s.PatchUser(user.ID, &entities.UserPatch{Password: &pwd}, 0)
+		assert.ErrorIs(t, err, store.ErrPasswordReused)
+
+		patched, err = s.PatchUser("unknown", &entities.UserPatch{Firstname: &firstname}, 0)
+		assert.Nil(t, err)
+		assert.Empty(t, patched.ID)
+	})
+
+	t.Run("UpdatePassword", func(t *testing.T) {
+		s := newStore(t, Options{PasswordHistorySize: 3})
+		user := register(t, s, "john@test.com", "Doe", "John")
+
+		assert.Nil(t, s.UpdatePassword(user.ID, "password 2"))
+		assert.Nil(t, s.UpdatePassword(user.ID, "password 3"))
+		assert.ErrorIs(t, s.UpdatePassword(user.ID, "password 3"), store.ErrPasswordReused)
+		assert.ErrorIs(t, s.UpdatePassword(user.ID, "password-john@test.com"), store.ErrPasswordReused)
+
+		// Only the last 3 passwords are kept
+		assert.Nil(t, s.UpdatePassword(user.ID, "password 4"))
+		assert.Nil(t, s.UpdatePassword(user.ID, "password-john@test.com"))
+
+		_, err := s.Login("john@test.com", "password-john@test.com")
+		assert.Nil(t, err)
+		assert.Nil(t, s.UpdatePassword("unknown", "password"))
+	})
+
+	t.Run("PasswordHistory", func(t *testing.T) {
+		// Without history, only the current password cannot be reused
+		s := newStore(t, Options{PasswordHistorySize: 1})
+		user := register(t, s, "john@test.com", "Doe", "John")
+		assert.ErrorIs(t, s.UpdatePassword(user.ID, "password-john@test.com"), store.ErrPasswordReused)
+		assert.Nil(t, s.UpdatePassword(user.ID, "password 2"))
+		assert.Nil(t, s.UpdatePassword(user.ID, "password-john@test.com"))
+
+		// Disabled
+		s = newStore(t, Options{})
+		user = register(t, s, "john@test.com", "Doe", "John")
+		assert.Nil(t, s.UpdatePassword(user.ID, "password-john@test.com"))
+
+		// Password changes of UpdateUser and PatchUser use the same history
+		s = newStore(t, Options{PasswordHistorySize: 3})
+		user = register(t, s, "john@test.com", "Doe", "John")
+		assert.Nil(t, s.UpdatePassword(user.ID, "password 2"))
+		pwd := "password 3"
+		_, err := s.PatchUser(user.ID, &entities.UserPatch{Password: &pwd}, 0)
+		assert.Nil(t, err)
+		_, err = s.UpdateUser(user.ID, &entities.UserForm{
+			Username: "john@test.com", Password: "password 2", Lastname: "Doe", Firstname: "John",
+		}, 0)
+		assert.ErrorIs(t, err, store.ErrPasswordReused)
+		assert.ErrorIs(t, s.UpdatePassword(user.ID, "password-john@test.com"), store.ErrPasswordReused)
+		assert.Nil(t, s.UpdatePassword(user.ID, "password 4"))
+		assert.Nil(t, s.UpdatePassword(user.ID, "password-john@test.com"))
+	})
+
+	t.Run("GetUsers", func(t *testing.T) {
+		s := newStore(t, Options{})
+		john := register(t, s, "john@test.com", "Doe", "John")
+		register(t, s, "jane@test.com", "Doe", "Jane")
+		register(t, s, "alice@test.com", "Martin", "Alice")
+		register(t, s, "bob@example.com", "Brown", "Bob")
+
+		names := func(users []entities.User) []string {
+			list := make([]string, len(users))
+			for i, u := range users {
+				list[i] = u.Firstname
+			}
+			return list
+		}
+		sort := []query.Sort{{Field: "lastname"}, {Field: "firstname", Desc: true}, {Field: "id"}}
+
+		// An extra user is returned if there is a next page
+		users, total, err := s.GetUsers(query.Params{Page: 1, Limit: 2, Sort: sort})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(4), total)
+		assert.Equal(t, []string{"Bob", "John", "Jane"}, names(users))
+
+		users, _, err = s.GetUsers(query.Params{Page: 2, Limit: 2, Sort: sort})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"Jane", "Alice"}, names(users))
+
+		// Cursor
+		users, _, err = s.GetUsers(query.Params{Page: 1, Limit: 2, Sort: sort, After: []interface{}{"Doe", "John", john.ID}})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"Jane", "Alice"}, names(users))
+
+		// Filters
+		users, total, err = s.GetUsers(query.Params{Page: 1, Limit: 3, Sort: sort, Filters: map[string]interface{}{
+			"username": "@test.",
+			"lastname": "o",
+		}})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, []string{"John", "Jane"}, names(users))
+
+		_, total, err = s.GetUsers(query.Params{Page: 1, Limit: 3, Sort: sort, Filters: map[string]interface{}{
+			"firstname": "%",
+		}})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), total)
+
+		_, total, err = s.GetUsers(query.Params{Page: 1, Limit: 3, Sort: sort, Filters: map[string]interface{}{
+			"created_after": time.Now().Add(-time.Hour),
+		}})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(4), total)
+
+		_, total, err = s.GetUsers(query.Params{Page: 1, Limit: 3, Sort: sort, Filters: map[string]interface{}{
+			"created_before": time.Now().Add(-time.Hour),
+		}})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), total)
+	})
+
+	t.Run("WithContext", func(t *testing.T) {
+		s := newStore(t, Options{})
+		user := register(t, s, "john@test.com", "Doe", "John")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := s.WithContext(ctx).GetUser(user.ID)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, s.WithContext(ctx).DeleteUser(user.ID), context.Canceled)
+
+		found, err := s.WithContext(context.Background()).GetUser(user.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, user.ID, found.ID)
+
+		// The context is required
+		s = factory(t, Options{})
+		_, err = s.GetUser(user.ID)
+		assert.ErrorIs(t, err, store.ErrMissingContext)
+		assert.ErrorIs(t, s.Register(&entities.User{Username: "jane@test.com", Password: "00000000"}), store.ErrMissingContext)
+	})
+}
@@ -0,0 +1,93 @@
+// Package htpasswd authenticates users against an Apache htpasswd file.
+// Only bcrypt hashes are supported (htpasswd -B).
+package htpasswd
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnsupportedHash is returned when a line does not contain a bcrypt hash.
+var ErrUnsupportedHash = errors.New("unsupported htpasswd hash, only bcrypt is allowed")
+
+// dummyHash is compared when the user is unknown, so the response time does not reveal valid usernames.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// File contains the users of a htpasswd file.
+type File struct {
+	users map[string][]byte
+}
+
+// Load reads a htpasswd file.
+func Load(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Parse reads htpasswd lines (username:hash).
+// Empty lines and lines starting with # are ignored.
+func Parse(r io.Reader) (*File, error) {
+	users := make(map[string][]byte)
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		username, hash, ok := strings.Cut(line, ":")
+		if !ok || username == "" {
+			return nil, fmt.Errorf("invalid htpasswd line %d", n)
+		}
+		if !strings.HasPrefix(hash, "$2a$") && !strings.HasPrefix(hash, "$2b$") && !strings.HasPrefix(hash, "$2y$") {
+			return nil, fmt.Errorf("%w (line %d)", ErrUnsupportedHash, n)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("invalid htpasswd hash (line %d): %w", n, err)
+		}
+
+		users[username] = []byte(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &File{users: users}, nil
+}
+
+// Len returns the number of users.
+func (f *File) Len() int {
+	return len(f.users)
+}
+
+// Authenticate returns true if the password matches the hash of the user.
+func (f *File) Authenticate(username, password string) bool {
+	hash, ok := f.users[username]
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
+
+// Equal compares two cleartext secrets in constant time.
+// Secrets are hashed first so that their length is not leaked either.
+func Equal(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
@@ -0,0 +1,41 @@
+package htpasswd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestParseAndAuthenticate(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	// htpasswd -B produces $2y$ hashes
+	apacheHash := "$2y$" + string(hash[4:])
+
+	f, err := Parse(strings.NewReader("# Operators\n\nalice:" + string(hash) + "\nbob:" + apacheHash + "\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, f.Len())
+
+	assert.True(t, f.Authenticate("alice", "secret"))
+	assert.True(t, f.Authenticate("bob", "secret"))
+	assert.False(t, f.Authenticate("alice", "wrong"))
+	assert.False(t, f.Authenticate("unknown", "secret"))
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse(strings.NewReader("alice:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g="))
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+
+	_, err = Parse(strings.NewReader("alice"))
+	assert.NotNil(t, err)
+
+	_, err = Parse(strings.NewReader("alice:$2y$invalid"))
+	assert.NotNil(t, err)
+}
+
+func TestEqual(t *testing.T) {
+	assert.True(t, Equal("secret", "secret"))
+	assert.False(t, Equal("secret", "secret2"))
+	assert.False(t, Equal("", "secret"))
+}
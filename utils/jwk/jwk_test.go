@@ -0,0 +1,124 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func writePrivateKey(t *testing.T, dir, name string, key interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+
+	file := path.Join(dir, name)
+	err = os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	assert.Nil(t, err)
+	return file
+}
+
+func TestKeySetHMAC(t *testing.T) {
+	s, err := New(Config{Algorithm: "HS512", Secret: "mySecretKeyForJWT"})
+	assert.Nil(t, err)
+
+	token, err := s.Sign(jwt.StandardClaims{Subject: "test"})
+	assert.Nil(t, err)
+
+	parsed, err := jwt.Parse(token, s.KeyFunc)
+	assert.Nil(t, err)
+	assert.True(t, parsed.Valid)
+	assert.Empty(t, s.JWKS().Keys)
+
+	_, err = New(Config{Algorithm: "HS512"})
+	assert.NotNil(t, err)
+}
+
+func TestKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	rsaFile := writePrivateKey(t, dir, "rsa.pem", rsaKey)
+	ecFile := writePrivateKey(t, dir, "ec.pem", ecKey)
+	edFile := writePrivateKey(t, dir, "ed.pem", edKey)
+
+	// Old key set signs with RSA
+	oldSet, err := New(Config{Algorithm: "RS256", PrivateKeyFiles: []string{rsaFile}})
+	assert.Nil(t, err)
+	oldToken, err := oldSet.Sign(jwt.StandardClaims{Subject: "old"})
+	assert.Nil(t, err)
+
+	// New key set signs with Ed25519 and still accepts RSA and ECDSA tokens
+	s, err := New(Config{Algorithm: "EdDSA", PrivateKeyFiles: []string{rsaFile, ecFile, edFile}})
+	assert.Nil(t, err)
+	token, err := s.Sign(jwt.StandardClaims{Subject: "new"})
+	assert.Nil(t, err)
+
+	parsed, err := jwt.Parse(token, s.KeyFunc)
+	assert.Nil(t, err)
+	assert.Equal(t, "EdDSA", parsed.Method.Alg())
+
+	parsed, err = jwt.Parse(oldToken, s.KeyFunc)
+	assert.Nil(t, err)
+	assert.True(t, parsed.Valid)
+
+	jwks := s.JWKS()
+	assert.Len(t, jwks.Keys, 3)
+	assert.Equal(t, "RSA", jwks.Keys[0].Kty)
+	assert.Equal(t, "EC", jwks.Keys[1].Kty)
+	assert.Equal(t, "P-256", jwks.Keys[1].Crv)
+	assert.Equal(t, "OKP", jwks.Keys[2].Kty)
+
+	// Published keys verify tokens
+	for _, k := range jwks.Keys {
+		_, err := k.PublicKey()
+		assert.Nil(t, err)
+	}
+	edPublic, _ := jwks.Keys[2].PublicKey()
+	_, err = jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return edPublic, nil })
+	assert.Nil(t, err)
+
+	// The signing key must match the algorithm
+	_, err = New(Config{Algorithm: "ES256", PrivateKeyFiles: []string{rsaFile}})
+	assert.ErrorIs(t, err, ErrUnexpectedAlgorithm)
+}
+
+func TestKeyFuncRejectsUnknownKeys(t *testing.T) {
+	s, _ := New(Config{Algorithm: "HS512", Secret: "mySecretKeyForJWT"})
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.StandardClaims{})
+	token.Header["kid"] = "unknown"
+	signed, _ := token.SignedString([]byte("mySecretKeyForJWT"))
+
+	_, err := jwt.Parse(signed, s.KeyFunc)
+	assert.NotNil(t, err)
+
+	token = jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{})
+	signed, _ = token.SignedString([]byte("mySecretKeyForJWT"))
+
+	_, err = jwt.Parse(signed, s.KeyFunc)
+	assert.NotNil(t, err)
+}
+
+func TestThumbprint(t *testing.T) {
+	// RFC 7638 section 3.1 example
+	jwk := JWK{
+		Kty: "RSA",
+		N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:   "AQAB",
+	}
+	thumbprint, err := jwk.Thumbprint()
+	assert.Nil(t, err)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", thumbprint)
+}
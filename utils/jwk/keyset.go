@@ -0,0 +1,184 @@
+package jwk
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// hmacKeyID is the key ID used for the shared secret.
+const hmacKeyID = "hmac"
+
+var (
+	// ErrUnknownKey is returned when a token references an unknown key.
+	ErrUnknownKey = errors.New("unknown JWT key")
+
+	// ErrUnexpectedAlgorithm is returned when a token algorithm does not match its key.
+	ErrUnexpectedAlgorithm = errors.New("unexpected JWT algorithm")
+)
+
+// Config represents the JWT keys configuration.
+type Config struct {
+	Algorithm       string   // HS512 | RS256 | ES256 | EdDSA
+	Secret          string   // Used with HS512
+	PrivateKeyFiles []string // PEM files, the last one is the signing key
+	PublicKeyFiles  []string // PEM files of verification only keys
+}
+
+// Key represents a JWT key.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+// KeySet holds all the keys accepted to verify tokens.
+// Only the signing key is used to sign new tokens.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+	ordered []*Key
+}
+
+// New loads the keys from the configuration.
+func New(config Config) (*KeySet, error) {
+	s := &KeySet{keys: make(map[string]*Key)}
+
+	if config.Algorithm == jwt.SigningMethodHS512.Alg() {
+		if config.Secret == "" {
+			return nil, errors.New("no JWT secret defined")
+		}
+		s.signing = &Key{
+			ID:      hmacKeyID,
+			Method:  jwt.SigningMethodHS512,
+			private: []byte(config.Secret),
+			public:  []byte(config.Secret),
+		}
+		s.add(s.signing)
+	}
+
+	for _, file := range config.PublicKeyFiles {
+		data, err := os.ReadFile(path.Clean(file))
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParsePublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		s.add(key)
+	}
+
+	for _, file := range config.PrivateKeyFiles {
+		data, err := os.ReadFile(path.Clean(file))
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		s.add(key)
+
+		if config.Algorithm != jwt.SigningMethodHS512.Alg() {
+			s.signing = key
+		}
+	}
+
+	if s.signing == nil {
+		return nil, errors.New("no JWT signing key defined")
+	}
+	if s.signing.Method.Alg() != config.Algorithm {
+		return nil, fmt.Errorf("%w: signing key algorithm is %s, expected %s", ErrUnexpectedAlgorithm, s.signing.Method.Alg(), config.Algorithm)
+	}
+
+	return s, nil
+}
+
+// Sign signs the claims with the signing key.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.Method, claims)
+	token.Header["kid"] = s.signing.ID
+	return token.SignedString(s.signing.private)
+}
+
+// KeyFunc returns the key used to verify a token.
+// Tokens without kid header are verified with the signing key.
+func (s *KeySet) KeyFunc(token *jwt.Token) (interface{}, error) {
+	key := s.signing
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok = s.keys[kid]; !ok {
+			return nil, ErrUnknownKey
+		}
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrUnexpectedAlgorithm
+	}
+	return key.public, nil
+}
+
+// JWKS returns the public keys as a JSON Web Key Set.
+// Shared secrets are never exported.
+func (s *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range s.ordered {
+		if jwk, ok := key.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// add adds a key to the set.
+func (s *KeySet) add(key *Key) {
+	if _, ok := s.keys[key.ID]; ok {
+		return
+	}
+	s.keys[key.ID] = key
+	s.ordered = append(s.ordered, key)
+}
+
+// newKey builds a key from a public key and an optional private key.
+// The key ID is the RFC 7638 thumbprint of the public key.
+func newKey(public crypto.PublicKey, private crypto.PrivateKey) (*Key, error) {
+	key := &Key{public: public, private: private}
+
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			key.Method = jwt.SigningMethodES256
+		case elliptic.P384():
+			key.Method = jwt.SigningMethodES384
+		case elliptic.P521():
+			key.Method = jwt.SigningMethodES512
+		default:
+			return nil, errors.New("unsupported elliptic curve")
+		}
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+
+	jwk, _ := key.JWK()
+	thumbprint, err := jwk.Thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = thumbprint
+
+	return key, nil
+}
@@ -0,0 +1,64 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// FileMailer writes emails as .eml files in a directory.
+// It is useful to test emails locally without a mail server.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFile returns a new FileMailer.
+func NewFile(dir, from string) (*FileMailer, error) {
+	dir = path.Clean(dir)
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+// Send writes the message in a new file.
+func (m *FileMailer) Send(msg Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+
+	name := fmt.Sprintf("%s_%s.eml", time.Now().Format("20060102150405"), uuid.New().String())
+	return os.WriteFile(path.Join(m.dir, name), msg.bytes(), 0600)
+}
+
+// LogMailer writes emails in the logs.
+// It must not be used in production because emails can contain secrets.
+type LogMailer struct {
+	logger *zap.Logger
+	from   string
+}
+
+// NewLog returns a new LogMailer.
+func NewLog(logger *zap.Logger, from string) *LogMailer {
+	return &LogMailer{logger: logger, from: from}
+}
+
+// Send logs the message.
+func (m *LogMailer) Send(msg Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+
+	m.logger.Info("Email",
+		zap.String("from", msg.From),
+		zap.Strings("to", msg.To),
+		zap.String("subject", msg.Subject),
+		zap.String("body", msg.Body),
+	)
+	return nil
+}
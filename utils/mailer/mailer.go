@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Message represents an email.
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Body    string // Plain text
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Config represents the mailer configuration.
+type Config struct {
+	Driver   string // smtp | file | log
+	From     string
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Path     string // Directory used by the file driver
+}
+
+// New returns the Mailer of the configured driver.
+func New(config Config, logger *zap.Logger) (Mailer, error) {
+	switch config.Driver {
+	case "smtp":
+		return NewSMTP(config.Host, config.Port, config.Username, config.Password, config.From), nil
+	case "file":
+		return NewFile(config.Path, config.From)
+	case "", "log":
+		return NewLog(logger, config.From), nil
+	default:
+		return nil, fmt.Errorf("unsupported mailer driver: %s", config.Driver)
+	}
+}
+
+// SendAsync sends an email in a new goroutine and logs the errors.
+func SendAsync(m Mailer, msg Message, logger *zap.Logger) {
+	go func() {
+		if err := m.Send(msg); err != nil {
+			logger.Error("Error when sending email",
+				zap.Error(err),
+				zap.Strings("to", msg.To),
+				zap.String("subject", msg.Subject),
+			)
+		}
+	}()
+}
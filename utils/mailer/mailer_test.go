@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+
+	m, err := NewFile(dir, "noreply@example.com")
+	assert.Nil(t, err)
+
+	err = m.Send(Message{
+		To:      []string{"test@gmail.com"},
+		Subject: "Password reset",
+		Body:    "Line 1\nLine 2",
+	})
+	assert.Nil(t, err)
+
+	files, _ := os.ReadDir(dir)
+	assert.Len(t, files, 1)
+
+	content, _ := os.ReadFile(path.Join(dir, files[0].Name()))
+	assert.True(t, strings.HasPrefix(string(content), "From: noreply@example.com\r\nTo: test@gmail.com\r\nSubject: Password reset\r\n"))
+	assert.True(t, strings.HasSuffix(string(content), "\r\n\r\nLine 1\r\nLine 2"))
+
+	_, err = NewFile(path.Join(dir, "unknown"), "")
+	assert.NotNil(t, err)
+}
+
+func TestNew(t *testing.T) {
+	m, err := New(Config{Driver: "log"}, zap.NewNop())
+	assert.Nil(t, err)
+	assert.IsType(t, &LogMailer{}, m)
+
+	m, err = New(Config{Driver: "smtp", Host: "localhost", Port: 25}, zap.NewNop())
+	assert.Nil(t, err)
+	assert.IsType(t, &SMTPMailer{}, m)
+
+	_, err = New(Config{Driver: "unknown"}, zap.NewNop())
+	assert.NotNil(t, err)
+}
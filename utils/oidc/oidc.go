@@ -0,0 +1,248 @@
+// Package oidc implements the OpenID Connect authorization code flow with PKCE (RFC 7636).
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/utils/jwk"
+)
+
+const (
+	// discoveryPath is the path of the provider metadata, relative to the issuer.
+	discoveryPath = "/.well-known/openid-configuration"
+
+	// keysRefreshInterval limits the JWKS downloads when an unknown key ID is received.
+	keysRefreshInterval = time.Minute
+
+	// httpTimeout is the default timeout of the requests sent to the provider.
+	httpTimeout = 10 * time.Second
+)
+
+var (
+	// ErrInvalidConfig is returned when a provider configuration is incomplete.
+	ErrInvalidConfig = errors.New("invalid OIDC provider configuration")
+
+	// ErrInvalidIDToken is returned when an ID token cannot be trusted.
+	ErrInvalidIDToken = errors.New("invalid ID token")
+)
+
+// Config of an OpenID Connect provider.
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string // Empty for public clients
+	RedirectURL  string
+	Scopes       []string // Default: openid email profile
+}
+
+// Metadata is the provider configuration returned by the discovery endpoint.
+type Metadata struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	JWKSURI                       string   `json:"jwks_uri"`
+	IDTokenSigningAlgValues       []string `json:"id_token_signing_alg_values_supported"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+}
+
+// Provider is an OpenID Connect provider.
+// Metadata and keys are discovered at first use.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu            sync.Mutex
+	metadata      *Metadata
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+// New returns a new Provider.
+// If client is nil, a client with a default timeout is used.
+func New(config Config, client *http.Client) (*Provider, error) {
+	if config.Name == "" || config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidConfig, config.Name)
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	if client == nil {
+		client = &http.Client{Timeout: httpTimeout}
+	}
+
+	return &Provider{
+		config: config,
+		client: client,
+		keys:   make(map[string]interface{}),
+	}, nil
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// Metadata returns the provider metadata, fetched from the discovery endpoint at first call.
+func (p *Provider) Metadata() (Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return *p.metadata, nil
+	}
+
+	var m Metadata
+	if err := p.getJSON(strings.TrimSuffix(p.config.Issuer, "/")+discoveryPath, &m); err != nil {
+		return m, err
+	}
+	if m.Issuer != p.config.Issuer {
+		return m, fmt.Errorf("OIDC issuer mismatch: %s", m.Issuer)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return m, errors.New("incomplete OIDC provider metadata")
+	}
+
+	p.metadata = &m
+	return m, nil
+}
+
+// AuthCodeURL returns the URL of the provider authorization endpoint.
+// The verifier is only sent as a S256 challenge.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	m, err := p.Metadata()
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(m.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.config.ClientID)
+	q.Set("redirect_uri", p.config.RedirectURL)
+	q.Set("scope", strings.Join(p.config.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", Challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// tokenResponse is the response of the token endpoint.
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange exchanges an authorization code for an ID token and returns its verified claims.
+func (p *Provider) Exchange(code, verifier, nonce string) (*IDTokenClaims, error) {
+	m, err := p.Metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequest(http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tr tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return nil, fmt.Errorf("invalid OIDC token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || tr.Error != "" {
+		return nil, fmt.Errorf("OIDC token request failed: %s %s", tr.Error, tr.ErrorDescription)
+	}
+	if tr.IDToken == "" {
+		return nil, fmt.Errorf("%w: missing ID token", ErrInvalidIDToken)
+	}
+
+	return p.VerifyIDToken(tr.IDToken, nonce)
+}
+
+// publicKey returns the provider key matching the key ID.
+// Keys are downloaded again when the ID is unknown, to follow the provider key rotation.
+func (p *Provider) publicKey(kid string) (interface{}, error) {
+	m, err := p.Metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < keysRefreshInterval {
+		return nil, fmt.Errorf("%w: unknown key", ErrInvalidIDToken)
+	}
+
+	var set jwk.JWKS
+	if err := p.getJSON(m.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	p.keysFetchedAt = time.Now()
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// Unsupported keys are ignored
+		if key, err := k.PublicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	p.keys = keys
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key", ErrInvalidIDToken)
+}
+
+// getJSON decodes the JSON response of a GET request.
+func (p *Provider) getJSON(url string, v interface{}) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("OIDC request %s failed with status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
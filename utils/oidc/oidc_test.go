@@ -0,0 +1,117 @@
+package oidc_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/fabienbellanger/echo-boilerplate/utils/oidc"
+	"github.com/fabienbellanger/echo-boilerplate/utils/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+)
+
+// authorize follows the authorization URL and returns the code sent to the redirect URL.
+func authorize(t *testing.T, authURL string) (code, state string) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	assert.Nil(t, err)
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	mock, err := oidctest.NewProvider("client", "secret")
+	assert.Nil(t, err)
+	defer mock.Close()
+	mock.SetIdentity(oidctest.Identity{Subject: "42", Email: "john@example.com", EmailVerified: true})
+
+	p, err := oidc.New(oidc.Config{
+		Name:         "mock",
+		Issuer:       mock.Issuer(),
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/callback",
+	}, nil)
+	assert.Nil(t, err)
+
+	verifier, err := oidc.NewVerifier()
+	assert.Nil(t, err)
+	authURL, err := p.AuthCodeURL("state", "nonce", verifier)
+	assert.Nil(t, err)
+
+	code, state := authorize(t, authURL)
+	assert.Equal(t, "state", state)
+
+	claims, err := p.Exchange(code, verifier, "nonce")
+	assert.Nil(t, err)
+	assert.Equal(t, "42", claims.Subject)
+	assert.Equal(t, "john@example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+
+	// A code can only be used once
+	_, err = p.Exchange(code, verifier, "nonce")
+	assert.NotNil(t, err)
+
+	// Wrong PKCE verifier
+	code, _ = authorize(t, authURL)
+	_, err = p.Exchange(code, "wrong-verifier", "nonce")
+	assert.NotNil(t, err)
+
+	// Wrong nonce
+	code, _ = authorize(t, authURL)
+	_, err = p.Exchange(code, verifier, "other")
+	assert.True(t, errors.Is(err, oidc.ErrInvalidIDToken))
+}
+
+func TestUnknownClient(t *testing.T) {
+	mock, err := oidctest.NewProvider("client", "")
+	assert.Nil(t, err)
+	defer mock.Close()
+
+	p, err := oidc.New(oidc.Config{
+		Name:        "mock",
+		Issuer:      mock.Issuer(),
+		ClientID:    "client",
+		RedirectURL: "http://localhost/callback",
+	}, nil)
+	assert.Nil(t, err)
+
+	other, err := oidc.New(oidc.Config{
+		Name:        "mock",
+		Issuer:      mock.Issuer(),
+		ClientID:    "other",
+		RedirectURL: "http://localhost/callback",
+	}, nil)
+	assert.Nil(t, err)
+
+	authURL, err := p.AuthCodeURL("state", "nonce", "verifier")
+	assert.Nil(t, err)
+	code, _ := authorize(t, authURL)
+
+	// The token endpoint authenticates the client with its ID
+	_, err = other.Exchange(code, "verifier", "nonce")
+	assert.NotNil(t, err)
+}
+
+func TestAudienceUnmarshal(t *testing.T) {
+	var a oidc.Audience
+	assert.Nil(t, json.Unmarshal([]byte(`"client"`), &a))
+	assert.Equal(t, oidc.Audience{"client"}, a)
+
+	assert.Nil(t, json.Unmarshal([]byte(`["client","other"]`), &a))
+	assert.True(t, a.Contains("other"))
+	assert.False(t, a.Contains("unknown"))
+}
+
+func TestInvalidConfig(t *testing.T) {
+	_, err := oidc.New(oidc.Config{Name: "mock"}, nil)
+	assert.ErrorIs(t, err, oidc.ErrInvalidConfig)
+}
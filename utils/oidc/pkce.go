@@ -0,0 +1,19 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+
+	"github.com/fabienbellanger/echo-boilerplate/utils"
+)
+
+// NewVerifier returns a random PKCE code verifier (43 characters).
+func NewVerifier() (string, error) {
+	return utils.GenerateRandomToken(32)
+}
+
+// Challenge returns the S256 PKCE code challenge of a verifier.
+func Challenge(verifier string) string {
+	h := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
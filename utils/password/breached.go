@@ -0,0 +1,122 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hashPrefixLength is the length of the SHA-1 prefixes of the k-anonymity ranges.
+const hashPrefixLength = 5
+
+// BreachedList checks passwords against a list of breached passwords.
+type BreachedList interface {
+	Contains(password string) (bool, error)
+}
+
+// LoadBreachedList opens a list of SHA-1 hashes of breached passwords, in the Have I Been Pwned formats:
+//   - a file of full hashes (HASH:COUNT lines), loaded in memory and indexed by hash prefix,
+//   - a directory of k-anonymity range files (<PREFIX>.txt files of SUFFIX:COUNT lines), read on demand.
+func LoadBreachedList(path string) (BreachedList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return breachedRanges{dir: path}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseBreachedList(f)
+}
+
+// ParseBreachedList reads a list of full SHA-1 hashes (HASH or HASH:COUNT lines).
+func ParseBreachedList(r io.Reader) (BreachedList, error) {
+	list := breachedHashes{ranges: make(map[string]map[string]struct{})}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("invalid SHA-1 hash line %d", n)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("invalid SHA-1 hash line %d", n)
+		}
+
+		prefix, suffix := hash[:hashPrefixLength], hash[hashPrefixLength:]
+		if list.ranges[prefix] == nil {
+			list.ranges[prefix] = make(map[string]struct{})
+		}
+		list.ranges[prefix][suffix] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// passwordHashRange returns the prefix and the suffix of the SHA-1 hash of a password.
+func passwordHashRange(password string) (prefix, suffix string) {
+	h := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(h[:]))
+	return hash[:hashPrefixLength], hash[hashPrefixLength:]
+}
+
+// breachedHashes is an in-memory list of hashes indexed by prefix.
+type breachedHashes struct {
+	ranges map[string]map[string]struct{}
+}
+
+// Contains returns true if the password is in the list.
+func (l breachedHashes) Contains(password string) (bool, error) {
+	prefix, suffix := passwordHashRange(password)
+	_, ok := l.ranges[prefix][suffix]
+	return ok, nil
+}
+
+// breachedRanges is a directory of k-anonymity range files.
+type breachedRanges struct {
+	dir string
+}
+
+// Contains returns true if the password is in the range file of its hash prefix.
+func (l breachedRanges) Contains(password string) (bool, error) {
+	prefix, suffix := passwordHashRange(password)
+
+	f, err := os.Open(filepath.Join(l.dir, prefix+".txt"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		s, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(s, suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,143 @@
+package password
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrUnknownHashFormat is returned when a stored hash is not recognized by any hasher.
+	ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+	// ErrInvalidHash is returned when a stored hash is recognized but malformed.
+	ErrInvalidHash = errors.New("invalid password hash")
+)
+
+// PasswordHasher hashes passwords and verifies them against stored hashes.
+type PasswordHasher interface {
+	// Hash returns the encoded hash of the password.
+	Hash(password string) (string, error)
+
+	// Verify checks if the password matches the encoded hash.
+	Verify(password, encoded string) (bool, error)
+
+	// Matches returns true if the encoded hash has been produced by this hasher.
+	Matches(encoded string) bool
+
+	// NeedsRehash returns true if the encoded hash should be replaced
+	// by a new one computed with the current algorithm and parameters.
+	NeedsRehash(encoded string) bool
+}
+
+// Config represents the password hashing configuration.
+type Config struct {
+	Algorithm         string // argon2id | bcrypt
+	BcryptCost        int
+	Argon2Memory      uint32 // In KiB
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+// New returns a PasswordHasher which hashes new passwords with the configured algorithm
+// and is still able to verify hashes produced by the other supported algorithms,
+// including legacy unsalted SHA-512 digests.
+func New(config Config) (PasswordHasher, error) {
+	bcryptHasher := NewBcrypt(config.BcryptCost)
+	argon2Hasher := NewArgon2id(config.Argon2Memory, config.Argon2Iterations, config.Argon2Parallelism)
+	legacyHasher := NewSHA512()
+
+	switch config.Algorithm {
+	case "", "argon2id":
+		return NewChain(argon2Hasher, bcryptHasher, legacyHasher), nil
+	case "bcrypt":
+		return NewChain(bcryptHasher, argon2Hasher, legacyHasher), nil
+	default:
+		return nil, fmt.Errorf("unsupported password hasher: %s", config.Algorithm)
+	}
+}
+
+// Chain hashes with a current hasher and verifies with any of its hashers.
+type Chain struct {
+	current PasswordHasher
+	others  []PasswordHasher
+}
+
+// NewChain returns a new Chain.
+func NewChain(current PasswordHasher, others ...PasswordHasher) *Chain {
+	return &Chain{current: current, others: others}
+}
+
+// Hash returns the encoded hash of the password computed by the current hasher.
+func (c *Chain) Hash(password string) (string, error) {
+	return c.current.Hash(password)
+}
+
+// Verify checks the password with the hasher which has produced the encoded hash.
+func (c *Chain) Verify(password, encoded string) (bool, error) {
+	h := c.hasherFor(encoded)
+	if h == nil {
+		return false, ErrUnknownHashFormat
+	}
+	return h.Verify(password, encoded)
+}
+
+// Matches returns true if one of the hashers recognizes the encoded hash.
+func (c *Chain) Matches(encoded string) bool {
+	return c.hasherFor(encoded) != nil
+}
+
+// NeedsRehash returns true if the encoded hash has not been produced by the current hasher
+// or if its parameters are outdated.
+func (c *Chain) NeedsRehash(encoded string) bool {
+	if !c.current.Matches(encoded) {
+		return true
+	}
+	return c.current.NeedsRehash(encoded)
+}
+
+// hasherFor returns the hasher which recognizes the encoded hash or nil.
+func (c *Chain) hasherFor(encoded string) PasswordHasher {
+	if c.current.Matches(encoded) {
+		return c.current
+	}
+	for _, h := range c.others {
+		if h.Matches(encoded) {
+			return h
+		}
+	}
+	return nil
+}
+
+// Dummy verifies passwords against the hash of a password nobody knows.
+// It is used when a user does not exist, so that the response time of a login
+// does not reveal whether the username exists.
+type Dummy struct {
+	hasher PasswordHasher
+	once   sync.Once
+	hash   string
+}
+
+// NewDummy returns a new Dummy. The hash is computed with the hasher on first use.
+func NewDummy(hasher PasswordHasher) *Dummy {
+	return &Dummy{hasher: hasher}
+}
+
+// Verify verifies the password as the hasher would for an existing user, the result is always false.
+func (d *Dummy) Verify(password string) {
+	d.once.Do(func() {
+		hash, err := d.hasher.Hash("dummy password which is never matched")
+		if err == nil {
+			d.hash = hash
+		}
+	})
+	if d.hash != "" {
+		_, _ = d.hasher.Verify(password, d.hash)
+	}
+}
+
+// constantTimeEqual compares two byte slices in constant time.
+func constantTimeEqual(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
@@ -0,0 +1,98 @@
+package password
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBcryptHasher(t *testing.T) {
+	h := NewBcrypt(4)
+
+	hash, err := h.Hash("00000000")
+	assert.Nil(t, err)
+	assert.True(t, h.Matches(hash))
+	assert.False(t, h.NeedsRehash(hash))
+
+	ok, err := h.Verify("00000000", hash)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = h.Verify("11111111", hash)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.True(t, NewBcrypt(5).NeedsRehash(hash))
+}
+
+func TestArgon2idHasher(t *testing.T) {
+	h := NewArgon2id(1024, 1, 1)
+
+	hash, err := h.Hash("00000000")
+	assert.Nil(t, err)
+	assert.Regexp(t, `^\$argon2id\$v=19\$m=1024,t=1,p=1\$[A-Za-z0-9+/]+\$[A-Za-z0-9+/]+$`, hash)
+	assert.True(t, h.Matches(hash))
+	assert.False(t, h.NeedsRehash(hash))
+
+	ok, err := h.Verify("00000000", hash)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = h.Verify("11111111", hash)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.True(t, NewArgon2id(2048, 1, 1).NeedsRehash(hash))
+
+	_, err = h.Verify("00000000", "$argon2id$v=19$m=1024$salt$hash")
+	assert.ErrorIs(t, err, ErrInvalidHash)
+}
+
+func TestChainMigratesLegacyHashes(t *testing.T) {
+	digest := sha512.Sum512([]byte("00000000"))
+	legacy := hex.EncodeToString(digest[:])
+
+	h := NewChain(NewArgon2id(1024, 1, 1), NewBcrypt(4), NewSHA512())
+
+	ok, err := h.Verify("00000000", legacy)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.True(t, h.NeedsRehash(legacy))
+
+	ok, err = h.Verify("11111111", legacy)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	hash, err := h.Hash("00000000")
+	assert.Nil(t, err)
+	assert.False(t, h.NeedsRehash(hash))
+
+	bcryptHash, _ := NewBcrypt(4).Hash("00000000")
+	ok, err = h.Verify("00000000", bcryptHash)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.True(t, h.NeedsRehash(bcryptHash))
+
+	_, err = h.Verify("00000000", "unknown")
+	assert.ErrorIs(t, err, ErrUnknownHashFormat)
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{Algorithm: "bcrypt"})
+	assert.Nil(t, err)
+
+	_, err = New(Config{Algorithm: "argon2id"})
+	assert.Nil(t, err)
+
+	_, err = New(Config{Algorithm: "md5"})
+	assert.NotNil(t, err)
+}
+
+func TestDummy(t *testing.T) {
+	d := NewDummy(NewBcrypt(4))
+	d.Verify("00000000")
+	assert.True(t, NewBcrypt(4).Matches(d.hash))
+	assert.False(t, NewBcrypt(4).NeedsRehash(d.hash), "the dummy hash must cost as much as the stored ones")
+}
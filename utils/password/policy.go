@@ -0,0 +1,107 @@
+package password
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/fabienbellanger/echo-boilerplate/utils"
+)
+
+// policyField is the field name of the policy errors.
+const policyField = "Password"
+
+// DefaultMinLength is the minimum length used when the policy does not set a positive one.
+const DefaultMinLength = 8
+
+// Policy represents the rules new passwords must follow.
+type Policy struct {
+	MinLength      int // In characters (DefaultMinLength if not positive)
+	MaxLength      int // In characters (0 for no limit)
+	RequireLower   bool
+	RequireUpper   bool
+	RequireDigit   bool
+	RequireSpecial bool
+	HistorySize    int          // Number of last passwords, including the current one, which cannot be reused
+	Breached       BreachedList // Optional list of breached passwords
+}
+
+// Validate checks a new password against the policy and returns an error per broken rule.
+// The history is checked by the user store.
+func (p Policy) Validate(password, username string) ([]*utils.ValidatorError, error) {
+	var errors []*utils.ValidatorError
+	fail := func(tag, value string) {
+		errors = append(errors, &utils.ValidatorError{
+			FailedField: policyField,
+			Tag:         tag,
+			Value:       value,
+		})
+	}
+
+	minLength := p.MinLength
+	if minLength <= 0 {
+		minLength = DefaultMinLength
+	}
+	length := utf8.RuneCountInString(password)
+	if length < minLength {
+		fail("min", strconv.Itoa(minLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		fail("max", strconv.Itoa(p.MaxLength))
+	}
+
+	var lower, upper, digit, special bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			special = true
+		}
+	}
+	if p.RequireLower && !lower {
+		fail("lowercase", "")
+	}
+	if p.RequireUpper && !upper {
+		fail("uppercase", "")
+	}
+	if p.RequireDigit && !digit {
+		fail("digit", "")
+	}
+	if p.RequireSpecial && !special {
+		fail("special", "")
+	}
+
+	if username != "" {
+		local, _, _ := strings.Cut(username, "@")
+		if strings.EqualFold(password, username) || strings.EqualFold(password, local) {
+			fail("nefield", "Username")
+		}
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return nil, err
+		}
+		if breached {
+			fail("breached", "")
+		}
+	}
+
+	return errors, nil
+}
+
+// ReusedError returns the error of a password found in the history.
+func (p Policy) ReusedError() []*utils.ValidatorError {
+	return []*utils.ValidatorError{{
+		FailedField: policyField,
+		Tag:         "reused",
+		Value:       strconv.Itoa(p.HistorySize),
+	}}
+}
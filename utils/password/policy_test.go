@@ -0,0 +1,68 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func policyTags(t *testing.T, p Policy, password, username string) []string {
+	errors, err := p.Validate(password, username)
+	assert.Nil(t, err)
+
+	tags := make([]string, 0, len(errors))
+	for _, e := range errors {
+		tags = append(tags, e.Tag)
+	}
+	return tags
+}
+
+func TestPolicyValidate(t *testing.T) {
+	p := Policy{MinLength: 8, MaxLength: 16, RequireLower: true, RequireUpper: true, RequireDigit: true, RequireSpecial: true}
+
+	assert.Empty(t, policyTags(t, p, "Passw0rd!", "john@example.com"))
+	assert.Empty(t, policyTags(t, p, "Éléphant1 rose", "john@example.com"))
+	assert.Equal(t, []string{"min", "uppercase", "digit", "special"}, policyTags(t, p, "short", ""))
+	assert.Equal(t, []string{"max"}, policyTags(t, p, "Passw0rd!Passw0rd!", ""))
+
+	p = Policy{MinLength: 4}
+	assert.Equal(t, []string{"nefield"}, policyTags(t, p, "John@Example.com", "john@example.com"))
+	assert.Equal(t, []string{"nefield"}, policyTags(t, p, "john", "john@example.com"))
+
+	// Unset minimum length
+	p = Policy{}
+	assert.Equal(t, []string{"min"}, policyTags(t, p, "1234567", ""))
+	assert.Empty(t, policyTags(t, p, "12345678", ""))
+}
+
+func TestBreachedList(t *testing.T) {
+	// SHA-1 of "password"
+	const hash = "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8"
+
+	list, err := ParseBreachedList(strings.NewReader(hash + ":3861493\n"))
+	assert.Nil(t, err)
+
+	p := Policy{MinLength: 8, Breached: list}
+	assert.Equal(t, []string{"breached"}, policyTags(t, p, "password", ""))
+	assert.Empty(t, policyTags(t, p, "correct horse battery staple", ""))
+
+	_, err = ParseBreachedList(strings.NewReader("not a hash\n"))
+	assert.NotNil(t, err)
+
+	// Directory of k-anonymity ranges
+	dir := t.TempDir()
+	err = os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte("0018A45C4D1DEF81644B54AB7F969B88D65:1\n"+hash[5:]+":3861493\n"), 0600)
+	assert.Nil(t, err)
+
+	list, err = LoadBreachedList(dir)
+	assert.Nil(t, err)
+	breached, err := list.Contains("password")
+	assert.Nil(t, err)
+	assert.True(t, breached)
+	breached, err = list.Contains("correct horse battery staple")
+	assert.Nil(t, err)
+	assert.False(t, breached)
+}
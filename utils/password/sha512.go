@@ -0,0 +1,41 @@
+package password
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+)
+
+// SHA512Hasher verifies legacy unsalted SHA-512 hex digests.
+// It must only be used to migrate existing hashes, never to create new ones.
+type SHA512Hasher struct{}
+
+// NewSHA512 returns a new SHA512Hasher.
+func NewSHA512() *SHA512Hasher {
+	return &SHA512Hasher{}
+}
+
+// Hash is not supported for legacy hashes.
+func (h *SHA512Hasher) Hash(password string) (string, error) {
+	return "", errors.New("SHA-512 hashing is deprecated")
+}
+
+// Verify checks if the password matches the SHA-512 hex digest.
+func (h *SHA512Hasher) Verify(password, encoded string) (bool, error) {
+	digest := sha512.Sum512([]byte(password))
+	return constantTimeEqual([]byte(hex.EncodeToString(digest[:])), []byte(encoded)), nil
+}
+
+// Matches returns true for 128 characters hex strings.
+func (h *SHA512Hasher) Matches(encoded string) bool {
+	if len(encoded) != sha512.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(encoded)
+	return err == nil
+}
+
+// NeedsRehash always returns true.
+func (h *SHA512Hasher) NeedsRehash(encoded string) bool {
+	return true
+}
@@ -0,0 +1,97 @@
+package patch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		doc, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+	}
+
+	for _, tt := range tests {
+		doc := decode(t, tt.doc)
+		result, err := Merge(doc, []byte(tt.patch))
+		assert.Nil(t, err)
+		assert.Equal(t, decode(t, tt.expected), result, tt.patch)
+		assert.Equal(t, decode(t, tt.doc), doc, "the document must not be modified")
+	}
+
+	_, err := Merge(nil, []byte(`{`))
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		doc, patch, expected string
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc"]}]`, `{"foo":["bar",["abc"]]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`, `{"a":{"b":1},"c":{"b":2}}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"remove","path":"/~1"}]`, `{"~1":10}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":null}]`, `{"foo":"bar","child":null}`},
+	}
+
+	for _, tt := range tests {
+		doc := decode(t, tt.doc)
+		result, err := Apply(doc, []byte(tt.patch))
+		assert.Nil(t, err, tt.patch)
+		assert.Equal(t, decode(t, tt.expected), result, tt.patch)
+		assert.Equal(t, decode(t, tt.doc), doc, "the document must not be modified")
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	doc := decode(t, `{"foo":"bar","list":[1,2]}`)
+	tests := []struct {
+		patch string
+		err   error
+	}{
+		{`{}`, ErrInvalidPatch},
+		{`[{"op":"unknown","path":"/foo"}]`, ErrInvalidPatch},
+		{`[{"op":"add","path":"/baz"}]`, ErrInvalidPatch},
+		{`[{"op":"add","path":"baz","value":1}]`, ErrInvalidPatch},
+		{`[{"op":"add","path":"/baz/bat","value":"qux"}]`, ErrInvalidPatch},
+		{`[{"op":"add","path":"/list/3","value":3}]`, ErrInvalidPatch},
+		{`[{"op":"add","path":"/list/01","value":3}]`, ErrInvalidPatch},
+		{`[{"op":"remove","path":"/baz"}]`, ErrInvalidPatch},
+		{`[{"op":"replace","path":"/baz","value":1}]`, ErrInvalidPatch},
+		{`[{"op":"move","from":"/list","path":"/list/0"}]`, ErrInvalidPatch},
+		{`[{"op":"test","path":"/foo","value":"baz"}]`, ErrTestFailed},
+		{`[{"op":"replace","path":"/foo","value":"baz"},{"op":"test","path":"/list/0","value":"1"}]`, ErrTestFailed},
+	}
+
+	for _, tt := range tests {
+		_, err := Apply(doc, []byte(tt.patch))
+		assert.ErrorIs(t, err, tt.err, tt.patch)
+	}
+	assert.Equal(t, decode(t, `{"foo":"bar","list":[1,2]}`), doc)
+}
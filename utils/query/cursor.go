@@ -0,0 +1,99 @@
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a cursor is malformed or has been built for another sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor is the encoded content of a cursor.
+// Values are typed, so that dates are compared as dates by all the databases.
+type cursor struct {
+	Sort   string        `json:"s"`
+	Values []cursorValue `json:"v"`
+}
+
+type cursorValue struct {
+	Type  string `json:"t"` // s (string) | i (integer) | t (time)
+	Value string `json:"v"`
+}
+
+// EncodeCursor returns the cursor pointing after an item whose sort values are given.
+// Values must be strings, integers or dates.
+func EncodeCursor(sort []Sort, values []interface{}) (string, error) {
+	c := cursor{Sort: sortKey(sort)}
+	for _, value := range values {
+		var v cursorValue
+		switch value := value.(type) {
+		case string:
+			v = cursorValue{Type: "s", Value: value}
+		case int, int64, uint, uint64:
+			v = cursorValue{Type: "i", Value: fmt.Sprint(value)}
+		case time.Time:
+			v = cursorValue{Type: "t", Value: value.Format(time.RFC3339Nano)}
+		default:
+			return "", fmt.Errorf("unsupported cursor value type %T", value)
+		}
+		c.Values = append(c.Values, v)
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeCursor returns the sort values of a cursor built for the sort.
+func DecodeCursor(s string, sort []Sort) ([]interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != sortKey(sort) || len(c.Values) != len(sort) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]interface{}, len(c.Values))
+	for i, v := range c.Values {
+		switch v.Type {
+		case "s":
+			values[i] = v.Value
+		case "i":
+			var n int64
+			if _, err := fmt.Sscan(v.Value, &n); err != nil {
+				return nil, ErrInvalidCursor
+			}
+			values[i] = n
+		case "t":
+			t, err := time.Parse(time.RFC3339Nano, v.Value)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			values[i] = t
+		default:
+			return nil, ErrInvalidCursor
+		}
+	}
+	return values, nil
+}
+
+// sortKey returns the sort as in a query string.
+func sortKey(sort []Sort) string {
+	fields := make([]string, len(sort))
+	for i, s := range sort {
+		fields[i] = s.String()
+	}
+	return strings.Join(fields, ",")
+}
@@ -0,0 +1,45 @@
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Links returns the RFC 8288 Link header of a list.
+// With a cursor, only the next link is set, using nextCursor (empty on the last page).
+// With pages, the previous, next, first and last links are set.
+func Links(u url.URL, params Params, total int64, nextCursor string) string {
+	var links []string
+	link := func(rel, key, value string) {
+		values := u.Query()
+		values.Del("page")
+		values.Del("cursor")
+		values.Set(key, value)
+		u.RawQuery = values.Encode()
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel))
+	}
+
+	if params.After != nil {
+		if nextCursor != "" {
+			link("next", "cursor", nextCursor)
+		}
+		return strings.Join(links, ", ")
+	}
+
+	lastPage := int((total + int64(params.Limit) - 1) / int64(params.Limit))
+	if lastPage < 1 {
+		lastPage = 1
+	}
+	if params.Page > 1 {
+		link("prev", "page", strconv.Itoa(params.Page-1))
+	}
+	if params.Page < lastPage {
+		link("next", "page", strconv.Itoa(params.Page+1))
+	}
+	link("first", "page", "1")
+	link("last", "page", strconv.Itoa(lastPage))
+
+	return strings.Join(links, ", ")
+}
@@ -0,0 +1,190 @@
+// Package query parses the pagination, sorting and filtering parameters of list endpoints.
+//
+// Lists are paginated either by page (?page=2&limit=20) or by cursor (?cursor=...&limit=20).
+// Cursors are opaque and point after the last item of a page: they are stable when items are
+// added or removed, and do not need an OFFSET. A cursor can only be used with the sort it was built for.
+//
+// Sorts are comma separated fields, descending when prefixed by "-" (Ex.: ?sort=lastname,-created_at).
+package query
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fabienbellanger/echo-boilerplate/utils"
+)
+
+// FilterType is the type of a filter value.
+type FilterType int
+
+// Filter types
+const (
+	FilterString FilterType = iota
+	FilterTime              // RFC 3339 date
+)
+
+// Config lists the parameters accepted by an endpoint.
+type Config struct {
+	Sortable     []string              // Fields which can be sorted
+	DefaultSort  []Sort                // Sort when none is requested
+	Tiebreaker   string                // Unique field added to all sorts, so that cursors are deterministic
+	Filters      map[string]FilterType // Filter name => type
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// Sort is a sort field and its direction.
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// String returns the sort as in a query string.
+func (s Sort) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+// Params are the parsed parameters of a list request.
+type Params struct {
+	Page    int
+	Limit   int
+	After   []interface{} // Sort values of the last item of the previous page, if a cursor is used
+	Sort    []Sort        // Including the tiebreaker
+	Filters map[string]interface{}
+}
+
+// Offset returns the offset of the page.
+func (p Params) Offset() int {
+	return (p.Page - 1) * p.Limit
+}
+
+// FetchLimit returns the number of items that stores fetch for the page:
+// one more than the limit, so that the extra item tells that there is a next page.
+func (p Params) FetchLimit() int {
+	return p.Limit + 1
+}
+
+// HasMore returns true if n items fetched with FetchLimit mean that there is a next page.
+// The extra item must not be returned.
+func (p Params) HasMore(n int) bool {
+	return n > p.Limit
+}
+
+// Parse parses and validates the list parameters of a query string.
+func Parse(values url.Values, config Config) (Params, []*utils.ValidatorError) {
+	var errors []*utils.ValidatorError
+	params := Params{
+		Page:    1,
+		Limit:   config.DefaultLimit,
+		Filters: make(map[string]interface{}),
+	}
+
+	// Pagination
+	// ----------
+	if s := values.Get("page"); s != "" {
+		page, err := strconv.Atoi(s)
+		if err != nil || page < 1 {
+			errors = append(errors, &utils.ValidatorError{FailedField: "page", Tag: "min", Value: "1"})
+		} else {
+			params.Page = page
+		}
+	}
+	if s := values.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > config.MaxLimit {
+			errors = append(errors, &utils.ValidatorError{FailedField: "limit", Tag: "max", Value: strconv.Itoa(config.MaxLimit)})
+		} else {
+			params.Limit = limit
+		}
+	}
+
+	// Sort
+	// ----
+	params.Sort = config.DefaultSort
+	if s := values.Get("sort"); s != "" {
+		sort, err := parseSort(s, config.Sortable)
+		if err != nil {
+			errors = append(errors, err)
+		} else {
+			params.Sort = sort
+		}
+	}
+	if config.Tiebreaker != "" && !hasField(params.Sort, config.Tiebreaker) {
+		params.Sort = append(append([]Sort{}, params.Sort...), Sort{Field: config.Tiebreaker})
+	}
+
+	// Cursor
+	// ------
+	if s := values.Get("cursor"); s != "" {
+		after, err := DecodeCursor(s, params.Sort)
+		if err != nil {
+			errors = append(errors, &utils.ValidatorError{FailedField: "cursor", Tag: "cursor"})
+		} else {
+			params.After = after
+		}
+	}
+
+	// Filters
+	// -------
+	for name, filterType := range config.Filters {
+		s := values.Get(name)
+		if s == "" {
+			continue
+		}
+
+		switch filterType {
+		case FilterTime:
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				errors = append(errors, &utils.ValidatorError{FailedField: name, Tag: "datetime", Value: time.RFC3339})
+				continue
+			}
+			params.Filters[name] = t
+		default:
+			params.Filters[name] = s
+		}
+	}
+
+	return params, errors
+}
+
+// parseSort parses comma separated sort fields.
+func parseSort(s string, sortable []string) ([]Sort, *utils.ValidatorError) {
+	var sort []Sort
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		desc := strings.HasPrefix(field, "-")
+		field = strings.TrimPrefix(field, "-")
+
+		if !contains(sortable, field) || hasField(sort, field) {
+			return nil, &utils.ValidatorError{FailedField: "sort", Tag: "oneof", Value: strings.Join(sortable, " ")}
+		}
+		sort = append(sort, Sort{Field: field, Desc: desc})
+	}
+	return sort, nil
+}
+
+// hasField returns true if a sort contains the field.
+func hasField(sort []Sort, field string) bool {
+	for _, s := range sort {
+		if s.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// contains returns true if the list contains the value.
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
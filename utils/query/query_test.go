@@ -0,0 +1,105 @@
+package query
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testConfig = Config{
+	Sortable:    []string{"lastname", "created_at"},
+	DefaultSort: []Sort{{Field: "lastname"}},
+	Tiebreaker:  "id",
+	Filters: map[string]FilterType{
+		"username":      FilterString,
+		"created_after": FilterTime,
+	},
+	DefaultLimit: 20,
+	MaxLimit:     100,
+}
+
+func TestParse(t *testing.T) {
+	values, _ := url.ParseQuery("page=3&limit=10&sort=-created_at,lastname&username=john&created_after=2022-01-01T00:00:00Z&unknown=1")
+	params, errors := Parse(values, testConfig)
+	assert.Nil(t, errors)
+	assert.Equal(t, 3, params.Page)
+	assert.Equal(t, 10, params.Limit)
+	assert.Equal(t, 20, params.Offset())
+	assert.Equal(t, []Sort{{Field: "created_at", Desc: true}, {Field: "lastname"}, {Field: "id"}}, params.Sort)
+	assert.Equal(t, map[string]interface{}{
+		"username":      "john",
+		"created_after": time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+	}, params.Filters)
+
+	params, errors = Parse(url.Values{}, testConfig)
+	assert.Nil(t, errors)
+	assert.Equal(t, 1, params.Page)
+	assert.Equal(t, 20, params.Limit)
+	assert.Equal(t, []Sort{{Field: "lastname"}, {Field: "id"}}, params.Sort)
+	assert.Equal(t, []Sort{{Field: "lastname"}}, testConfig.DefaultSort)
+}
+
+func TestParseErrors(t *testing.T) {
+	values, _ := url.ParseQuery("page=0&limit=1000&sort=password&created_after=yesterday&cursor=abc")
+	_, errors := Parse(values, testConfig)
+
+	fields := make([]string, len(errors))
+	for i, err := range errors {
+		fields[i] = err.FailedField
+	}
+	assert.ElementsMatch(t, []string{"page", "limit", "sort", "created_after", "cursor"}, fields)
+
+	values, _ = url.ParseQuery("sort=lastname,-lastname")
+	_, errors = Parse(values, testConfig)
+	assert.Len(t, errors, 1)
+}
+
+func TestParamsFetchLimit(t *testing.T) {
+	params := Params{Page: 3, Limit: 10}
+	assert.Equal(t, 20, params.Offset())
+	assert.Equal(t, 11, params.FetchLimit())
+	assert.False(t, params.HasMore(10))
+	assert.True(t, params.HasMore(11))
+}
+
+func TestCursor(t *testing.T) {
+	sort := []Sort{{Field: "lastname"}, {Field: "created_at", Desc: true}, {Field: "id"}}
+	createdAt := time.Date(2022, 10, 18, 9, 30, 0, 123000000, time.UTC)
+
+	cursor, err := EncodeCursor(sort, []interface{}{"Doe", createdAt, int64(42)})
+	assert.Nil(t, err)
+
+	values, err := DecodeCursor(cursor, sort)
+	assert.Nil(t, err)
+	assert.Equal(t, "Doe", values[0])
+	assert.True(t, createdAt.Equal(values[1].(time.Time)))
+	assert.Equal(t, int64(42), values[2])
+
+	// A cursor cannot be used with another sort
+	_, err = DecodeCursor(cursor, sort[1:])
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = DecodeCursor("not a cursor", sort)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = EncodeCursor(sort, []interface{}{1.5})
+	assert.NotNil(t, err)
+}
+
+func TestLinks(t *testing.T) {
+	u, _ := url.Parse("/api/v1/users?limit=10&page=2&sort=lastname")
+
+	links := Links(*u, Params{Page: 2, Limit: 10}, 35, "next")
+	assert.Equal(t, `</api/v1/users?limit=10&page=1&sort=lastname>; rel="prev", `+
+		`</api/v1/users?limit=10&page=3&sort=lastname>; rel="next", `+
+		`</api/v1/users?limit=10&page=1&sort=lastname>; rel="first", `+
+		`</api/v1/users?limit=10&page=4&sort=lastname>; rel="last"`, links)
+
+	links = Links(*u, Params{Page: 1, Limit: 10, After: []interface{}{"Doe"}}, 35, "next")
+	assert.Equal(t, `</api/v1/users?cursor=next&limit=10&sort=lastname>; rel="next"`, links)
+
+	links = Links(*u, Params{Page: 1, Limit: 10, After: []interface{}{"Doe"}}, 35, "")
+	assert.Empty(t, links)
+}
@@ -0,0 +1,54 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B test vectors (SHA1), truncated to 6 digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	cases := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for timestamp, expected := range cases {
+		code, err := Code(secret, Step(time.Unix(timestamp, 0)))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, code, timestamp)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.Nil(t, err)
+
+	now := time.Now()
+	code, _ := Code(secret, Step(now))
+
+	step, ok := Validate(secret, code, now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	_, ok = Validate(secret, code, now.Add(Period*time.Second), 1)
+	assert.True(t, ok)
+
+	_, ok = Validate(secret, code, now.Add(3*Period*time.Second), 1)
+	assert.False(t, ok)
+
+	_, ok = Validate(secret, "12345", now, 1)
+	assert.False(t, ok)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("Echo Boilerplate", "test@gmail.com", "JBSWY3DPEHPK3PXP")
+	assert.Equal(t, "otpauth://totp/Echo%20Boilerplate:test@gmail.com?algorithm=SHA1&digits=6&issuer=Echo+Boilerplate&period=30&secret=JBSWY3DPEHPK3PXP", uri)
+}